@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AlertRule struct {
+	Name         string  `yaml:"name"`
+	Metric       string  `yaml:"metric"`     // Ruta en el JSON de SystemStats, ej. "metrics.memory.swap_used_percent"
+	Comparator   string  `yaml:"comparator"` // >, >=, <, <=, ==, !=
+	Threshold    float64 `yaml:"threshold"`
+	Hysteresis   float64 `yaml:"hysteresis"`
+	SustainedFor uint    `yaml:"sustained_for"` // Segundos
+	Severity     string  `yaml:"severity"`
+}
+
+type AlertEvent struct {
+	Rule     string `json:"rule"`
+	Metric   string `json:"metric"`
+	Severity string `json:"severity"`
+	State    string `json:"state"` // firing | resolved
+	// Stale indica que la alerta se resolvió porque la métrica dejó de
+	// existir (collector deshabilitado, servicio quitado); Value no aplica.
+	Stale     bool      `json:"stale,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+	Timestamp time.Time `json:"timestamp"`
+	AgentID   string    `json:"agent_id"`
+	Hostname  string    `json:"hostname"`
+	IP        string    `json:"ip"`
+}
+
+type alertState struct {
+	rule          AlertRule
+	firing        bool
+	breachedSince time.Time
+	firingSince   time.Time
+}
+
+// alertManager evalúa las reglas de alerta sobre cada muestra de SystemStats
+// y envía los cambios de estado al servidor sin bloquear el muestreo.
+type alertManager struct {
+	config Config
+	states []*alertState
+	events chan AlertEvent
+	spool  *spool
+}
+
+func newAlertManager(config Config) *alertManager {
+	m := &alertManager{
+		config: config,
+		events: make(chan AlertEvent, 100),
+		spool:  newSpool(config, "alerts"),
+	}
+	for _, rule := range config.Alerts {
+		if !validComparator(rule.Comparator) {
+			statsLog().Warn("Regla de alerta ignorada: comparador inválido", "rule", rule.Name, "comparator", rule.Comparator)
+			continue
+		}
+		m.states = append(m.states, &alertState{rule: rule})
+	}
+	if len(m.states) > 0 {
+		safeGoRoutine("alert sender", m.sendLoop)
+	}
+	return m
+}
+
+// Evaluate compara la muestra contra cada regla. Una alerta se dispara cuando
+// la condición se cumple durante sustained_for y se resuelve cuando el valor
+// vuelve más allá del umbral menos la histéresis, o con stale si la métrica
+// deja de aparecer en la muestra.
+func (m *alertManager) Evaluate(stats SystemStats) {
+	if len(m.states) == 0 {
+		return
+	}
+
+	values, err := flattenStats(stats)
+	if err != nil {
+		statsLog().Error("Error al preparar métricas para alertas", "error", err)
+		return
+	}
+
+	now := stats.Timestamp
+	for _, st := range m.states {
+		value, ok := values[st.rule.Metric]
+		if !ok {
+			st.breachedSince = time.Time{}
+			if st.firing {
+				st.firing = false
+				m.emit(st, "resolved", 0, now, true)
+			}
+			continue
+		}
+
+		if !st.firing {
+			if !compare(value, st.rule.Comparator, st.rule.Threshold) {
+				st.breachedSince = time.Time{}
+				continue
+			}
+			if st.breachedSince.IsZero() {
+				st.breachedSince = now
+			}
+			if now.Sub(st.breachedSince) >= time.Duration(st.rule.SustainedFor)*time.Second {
+				st.firing = true
+				st.firingSince = now
+				m.emit(st, "firing", value, now, false)
+			}
+			continue
+		}
+
+		if compare(value, st.rule.Comparator, resolveThreshold(st.rule)) {
+			continue
+		}
+		st.firing = false
+		st.breachedSince = time.Time{}
+		m.emit(st, "resolved", value, now, false)
+	}
+}
+
+func (m *alertManager) emit(st *alertState, state string, value float64, now time.Time, stale bool) {
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
+	event := AlertEvent{
+		Rule:      st.rule.Name,
+		Metric:    st.rule.Metric,
+		Severity:  st.rule.Severity,
+		State:     state,
+		Stale:     stale,
+		Value:     value,
+		Threshold: st.rule.Threshold,
+		Since:     st.firingSince,
+		Timestamp: now,
+		AgentID:   agentID,
+		Hostname:  hostname,
+		IP:        ip,
+	}
+	statsLog().Warn("Alerta", "rule", event.Rule, "state", state, "metric", event.Metric, "value", value)
+
+	select {
+	case m.events <- event:
+	default:
+		payload, _ := marshalReport(event)
+		m.spool.Append(payload)
+	}
+}
+
+// sendLoop envía los eventos al servidor. Los que fallan se guardan en el
+// spool y se reenvían antes del siguiente evento que logre enviarse.
+func (m *alertManager) sendLoop() {
+	url := fmt.Sprintf("%s/api/%s/log/alerts", m.config.ServerURL, m.config.ServerVersion)
+	send := func(payload []byte) error { return postJSON(url, payload) }
+	retry := time.NewTicker(time.Minute)
+	defer retry.Stop()
+
+	for {
+		select {
+		case event := <-m.events:
+			payload, err := marshalReport(event)
+			if err != nil {
+				statsLog().Error("Error al serializar alerta", "rule", event.Rule, "error", err)
+				continue
+			}
+			m.spool.Flush(send)
+			if err := send(payload); err != nil {
+				statsLog().Warn("Error al enviar alerta, se guarda en spool", "rule", event.Rule, "error", err)
+				m.spool.Append(payload)
+			}
+		case <-retry.C:
+			m.spool.Flush(send)
+		}
+	}
+}
+
+func validComparator(c string) bool {
+	switch c {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func compare(value float64, comparator string, threshold float64) bool {
+	switch comparator {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// resolveThreshold desplaza el umbral según la histéresis para que una métrica
+// que oscila alrededor del umbral no dispare y resuelva en cada muestra.
+func resolveThreshold(rule AlertRule) float64 {
+	switch rule.Comparator {
+	case ">", ">=":
+		return rule.Threshold - rule.Hysteresis
+	case "<", "<=":
+		return rule.Threshold + rule.Hysteresis
+	}
+	return rule.Threshold
+}
+
+// flattenStats convierte la muestra en un mapa de rutas con punto a valores
+// numéricos, usando los nombres del JSON que recibe el servidor. Los
+// elementos de listas se indexan por su campo "name" si lo tienen, o por su
+// posición.
+func flattenStats(stats SystemStats) (map[string]float64, error) {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64)
+	flattenValue("", tree, values)
+	return values, nil
+}
+
+func flattenValue(prefix string, v interface{}, out map[string]float64) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch t := v.(type) {
+	case float64:
+		out[prefix] = t
+	case bool:
+		if t {
+			out[prefix] = 1
+		} else {
+			out[prefix] = 0
+		}
+	case map[string]interface{}:
+		for k, child := range t {
+			flattenValue(join(k), child, out)
+		}
+	case []interface{}:
+		for i, child := range t {
+			key := strconv.Itoa(i)
+			if obj, ok := child.(map[string]interface{}); ok {
+				if name, ok := obj["name"].(string); ok && name != "" {
+					key = strings.ReplaceAll(name, ".", "_")
+				}
+			}
+			flattenValue(join(key), child, out)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// newTestAlertManager arma el manager sin sendLoop, para leer los eventos
+// del canal. events es la capacidad del canal antes de usar el spool.
+func newTestAlertManager(t *testing.T, events int, rules ...AlertRule) *alertManager {
+	t.Helper()
+	m := &alertManager{
+		events: make(chan AlertEvent, events),
+		spool:  newSpool(Config{DataDir: t.TempDir()}, "alerts"),
+	}
+	for _, rule := range rules {
+		m.states = append(m.states, &alertState{rule: rule})
+	}
+	return m
+}
+
+func swapStats(at time.Time, value interface{}) SystemStats {
+	stats := SystemStats{Timestamp: at, Metrics: map[string]interface{}{}}
+	if value != nil {
+		stats.Metrics["memory"] = map[string]interface{}{"swap_used_percent": value}
+	}
+	return stats
+}
+
+func drainAlertEvents(m *alertManager) []AlertEvent {
+	var events []AlertEvent
+	for {
+		select {
+		case e := <-m.events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestAlertEvaluate(t *testing.T) {
+	rule := AlertRule{
+		Name: "high_swap", Metric: "metrics.memory.swap_used_percent",
+		Comparator: ">", Threshold: 80, Hysteresis: 5, SustainedFor: 60, Severity: "warning",
+	}
+	m := newTestAlertManager(t, 10, rule)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name  string
+		at    int // Segundos desde start
+		value interface{}
+		want  string // Estado del evento, vacío si no hay
+		stale bool
+	}{
+		{"empieza la violación", 0, 85.0, "", false},
+		{"todavía no alcanza sustained_for", 30, 90.0, "", false},
+		{"se cumple sustained_for", 60, 82.0, "firing", false},
+		{"bajo el umbral pero dentro de la histéresis", 70, 78.0, "", false},
+		{"sale de la histéresis", 80, 74.0, "resolved", false},
+		{"vuelve a violarse", 90, 85.0, "", false},
+		{"se corta antes de sustained_for", 100, 70.0, "", false},
+		{"nueva violación", 110, 85.0, "", false},
+		{"dispara de nuevo", 170, 85.0, "firing", false},
+		{"la métrica desaparece", 180, nil, "resolved", true},
+		{"la métrica vuelve", 190, 90.0, "", false},
+		{"sustained_for cuenta desde que volvió", 240, 90.0, "", false},
+		{"dispara otra vez", 250, 90.0, "firing", false},
+	}
+	for _, s := range steps {
+		at := start.Add(time.Duration(s.at) * time.Second)
+		m.Evaluate(swapStats(at, s.value))
+		events := drainAlertEvents(m)
+		if s.want == "" {
+			if len(events) != 0 {
+				t.Errorf("%s: eventos inesperados %+v", s.name, events)
+			}
+			continue
+		}
+		if len(events) != 1 {
+			t.Errorf("%s: %d eventos, se esperaba %s", s.name, len(events), s.want)
+			continue
+		}
+		e := events[0]
+		if e.State != s.want || e.Stale != s.stale || !e.Timestamp.Equal(at) || e.Rule != "high_swap" || e.Threshold != 80 {
+			t.Errorf("%s: evento %+v", s.name, e)
+		}
+		if s.want == "firing" && !e.Since.Equal(at) {
+			t.Errorf("%s: since %v", s.name, e.Since)
+		}
+	}
+}
+
+func TestAlertHysteresisBelow(t *testing.T) {
+	rule := AlertRule{Name: "low_disk", Metric: "disk_usage", Comparator: "<", Threshold: 100, Hysteresis: 10}
+	m := newTestAlertManager(t, 10, rule)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value uint64
+		want  string
+	}{
+		{150, ""},
+		{50, "firing"}, // sustained_for 0 dispara en la primera muestra
+		{105, ""},      // dentro de la histéresis (< 110)
+		{110, "resolved"},
+		{99, "firing"},
+	}
+	for i, tt := range tests {
+		m.Evaluate(SystemStats{Timestamp: start.Add(time.Duration(i) * time.Second), DiskUsage: tt.value})
+		events := drainAlertEvents(m)
+		got := ""
+		if len(events) == 1 {
+			got = events[0].State
+		}
+		if len(events) > 1 || got != tt.want {
+			t.Errorf("disk_usage=%d: eventos %+v, want %q", tt.value, events, tt.want)
+		}
+	}
+}
+
+// Con el canal lleno los eventos van al spool para reenviarse después.
+func TestAlertSpoolFallback(t *testing.T) {
+	m := newTestAlertManager(t, 1,
+		AlertRule{Name: "a", Metric: "cpu_percent", Comparator: ">", Threshold: 10},
+		AlertRule{Name: "b", Metric: "cpu_percent", Comparator: ">", Threshold: 20},
+	)
+	m.Evaluate(SystemStats{Timestamp: time.Now(), CPUPercent: 50})
+
+	if events := drainAlertEvents(m); len(events) != 1 || events[0].Rule != "a" {
+		t.Fatalf("eventos en el canal %+v", events)
+	}
+	spooled := m.spool.Peek()
+	if len(spooled) != 1 {
+		t.Fatalf("%d eventos en el spool", len(spooled))
+	}
+	var e AlertEvent
+	if err := json.Unmarshal(spooled[0], &e); err != nil || e.Rule != "b" || e.State != "firing" || e.Value != 50 {
+		t.Fatalf("evento del spool %s (%v)", spooled[0], err)
+	}
+}
+
+func TestNewAlertManagerSkipsInvalidComparator(t *testing.T) {
+	m := newAlertManager(Config{DataDir: t.TempDir(), Alerts: []AlertRule{{Name: "x", Metric: "cpu_percent", Comparator: "=>"}}})
+	if len(m.states) != 0 {
+		t.Fatalf("se aceptó una regla con comparador inválido")
+	}
+}
+
+func TestFlattenStats(t *testing.T) {
+	stats := SystemStats{
+		CPUPercent: 12.5,
+		DiskUsage:  1024,
+		Services:   []ServiceConfig{{Name: "W3SVC", AutoStartIfStopped: true}, {Name: "app.worker"}},
+		Metrics: map[string]interface{}{
+			"memory": map[string]interface{}{"swap_used_percent": 40.0, "label": "texto"},
+			"disks":  []interface{}{map[string]interface{}{"name": "C:", "free": 10}, map[string]interface{}{"free": 20}},
+			"ok":     true,
+		},
+	}
+	values, err := flattenStats(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"cpu_percent":                               12.5,
+		"disk_usage":                                1024,
+		"services.W3SVC.auto_start_if_stopped":      1,
+		"services.app_worker.auto_start_if_stopped": 0,
+		"metrics.memory.swap_used_percent":          40,
+		"metrics.disks.C:.free":                     10,
+		"metrics.disks.1.free":                      20,
+		"metrics.ok":                                1,
+	}
+	for key, v := range want {
+		if got, ok := values[key]; !ok || got != v {
+			t.Errorf("%s = %v (presente %v), want %v", key, got, ok, v)
+		}
+	}
+	if _, ok := values["metrics.memory.label"]; ok {
+		t.Errorf("los strings no deben aparecer como métricas")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		value      float64
+		comparator string
+		threshold  float64
+		want       bool
+	}{
+		{5, ">", 4, true}, {4, ">", 4, false}, {4, ">=", 4, true},
+		{3, "<", 4, true}, {4, "<=", 4, true}, {4, "==", 4, true},
+		{4, "!=", 4, false}, {4, "=>", 4, false},
+	}
+	for _, tt := range tests {
+		if got := compare(tt.value, tt.comparator, tt.threshold); got != tt.want {
+			t.Errorf("%v %s %v = %v", tt.value, tt.comparator, tt.threshold, got)
+		}
+	}
+}
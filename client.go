@@ -5,30 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
-	"net"
 	"net/http"
 	"os"
-	"os/exec"
-	"strings"
 	"time"
-
-	"golang.org/x/sys/windows/svc/mgr"
 )
 
 type ServiceStatus struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+	Reasons []Reason `json:"reasons,omitempty"`
+}
+
+// addReason agrega un motivo y rehace Error con el texto de todos.
+func (s *ServiceStatus) addReason(r Reason) {
+	s.Reasons = append(s.Reasons, r)
+	s.Error = joinReasons(s.Reasons)
 }
 
 type ServiceLog struct {
+	AgentID     string    `json:"agent_id"`
 	Hostname    string    `json:"hostname"`
 	IP          string    `json:"ip"`
 	ServiceName string    `json:"service_name"`
 	Status      string    `json:"status"`
 	Timestamp   time.Time `json:"timestamp"`
 	Error       string    `json:"error,omitempty"`
+	Reasons     []Reason  `json:"reasons,omitempty"`
 }
 
 type ServiceEventLog struct {
@@ -36,6 +39,7 @@ type ServiceEventLog struct {
 	Timestamp   time.Time `json:"timestamp"`
 	Message     string    `json:"message"`
 	Level       string    `json:"level"`
+	AgentID     string    `json:"agent_id"`
 	Hostname    string    `json:"hostname"`
 	IP          string    `json:"ip"`
 }
@@ -47,23 +51,31 @@ type ServerResponse struct {
 type AutoStartAlert struct {
 	ServiceName string    `json:"service_name"`
 	Timestamp   time.Time `json:"timestamp"`
+	Code        string    `json:"code"`
 	Message     string    `json:"message"`
+	AgentID     string    `json:"agent_id"`
 	Hostname    string    `json:"hostname"`
 	IP          string    `json:"ip"`
 }
 
-// LogErrorToFile guarda errores y payloads en client.log
-func LogErrorToFile(err error, payload []byte) {
-	f, fileErr := os.OpenFile("client.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if fileErr != nil {
-		log.Println("Error al abrir client.log:", fileErr)
-		return
+// postJSON envía un payload JSON al servidor y devuelve error si la respuesta
+// no es 200.
+// httpClient limita cada envío al servidor, para que un servidor colgado no
+// bloquee los reportes ni los reenvíos de spool.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func postJSON(url string, payload []byte) error {
+	resp, err := httpClient.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
 	}
-	defer f.Close()
+	defer resp.Body.Close()
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logEntry := fmt.Sprintf("[%s] Error al enviar logs: %s\nPayload:\n%s\n\n", timestamp, err.Error(), string(payload))
-	f.WriteString(logEntry)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Respuesta HTTP: %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
 }
 
 func sendAutoStartAlert(serverURL, version, serviceName string) {
@@ -73,28 +85,21 @@ func sendAutoStartAlert(serverURL, version, serviceName string) {
 	alert := AutoStartAlert{
 		ServiceName: serviceName,
 		Timestamp:   time.Now(),
-		Message:     "El servicio fue iniciado automáticamente por el monitor.",
+		Code:        reasonServiceAutoStarted,
+		Message:     newReason(reasonServiceAutoStarted).Error(),
+		AgentID:     agentID,
 		Hostname:    hostname,
 		IP:          ip,
 	}
 
-	payload, err := json.Marshal(alert)
+	payload, err := marshalReport(alert)
 	if err != nil {
-		LogErrorToFile(err, payload)
+		servicesLog().Error("Error al serializar alerta de auto-inicio", "service", serviceName, "error", err)
 		return
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/api/%s/log/service-auto-start", serverURL, version), "application/json", bytes.NewBuffer(payload))
-	if err != nil {
-		LogErrorToFile(err, payload)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err := fmt.Errorf("Respuesta HTTP: %d: %s", resp.StatusCode, string(body))
-		LogErrorToFile(err, payload)
+	if err := postJSON(fmt.Sprintf("%s/api/%s/log/service-auto-start", serverURL, version), payload); err != nil {
+		logSendError(servicesLog(), "service_auto_start", err, payload)
 	}
 }
 
@@ -102,66 +107,43 @@ func sendAutoStartAlert(serverURL, version, serviceName string) {
 func checkServices(config Config) []ServiceStatus {
 	var results []ServiceStatus
 
-	m, err := mgr.Connect()
-	if err != nil {
-		log.Println("Error al conectar con el manejador de servicios:", err)
-		return results
-	}
-	defer m.Disconnect()
-
 	for _, cfg := range config.Services {
 		status := ServiceStatus{Name: cfg.Name}
-		svc, err := m.OpenService(cfg.Name)
+		state, err := queryServiceState(cfg.Name)
 		if err != nil {
-			status.Status = "not found"
-			status.Error = err.Error()
-		} else {
-			s, err := svc.Query()
-			if err != nil {
-				status.Status = "unknown"
-				status.Error = err.Error()
-			} else {
-				switch s.State {
-				case 1:
-					status.Status = "stopped"
-				case 4:
-					status.Status = "running"
-				default:
-					status.Status = fmt.Sprintf("state_%d", s.State)
-				}
+			reason := reasonOf(err, reasonServiceQueryFailed)
+			status.Status = "unknown"
+			if reason.Code == reasonServiceNotFound {
+				status.Status = "not found"
+			}
+			status.addReason(reason)
+			results = append(results, status)
+			continue
+		}
+		status.Status = state
 
-				// Verifica estado esperado vs real
-				if cfg.ExpectedStatus != "" && status.Status != cfg.ExpectedStatus {
-					// Guardamos el error pero NO cambiamos aún el status
-					status.Error = fmt.Sprintf("Estado actual '%s' difiere del esperado '%s'", status.Status, cfg.ExpectedStatus)
-
-					// Creamos una copia del status antes de actuar
-					results = append(results, status)
-
-					// Luego intentamos iniciar el servicio
-					if cfg.ExpectedStatus == "running" && status.Status == "stopped" && cfg.AutoStartIfStopped {
-						err := svc.Start()
-						if err != nil {
-							status.Error += fmt.Sprintf(" | Falló al iniciar: %s", err.Error())
-						} else {
-							status.Error += " | Servicio iniciado automáticamente."
-							status.Status = "running"
-							sendAutoStartAlert(config.ServerURL, config.ServerVersion, cfg.Name)
-						}
-
-						// Registramos el nuevo estado después de intentar iniciar
-						results = append(results, ServiceStatus{
-							Name:   cfg.Name,
-							Status: status.Status,
-							Error:  status.Error,
-						})
-
-						svc.Close()
-						continue // ya agregamos ambos estados, continuamos
-					}
+		// Verifica estado esperado vs real
+		if cfg.ExpectedStatus != "" && status.Status != cfg.ExpectedStatus {
+			// Guardamos el error pero NO cambiamos aún el status
+			status.addReason(newReason(reasonServiceStateMismatch, "actual", status.Status, "expected", cfg.ExpectedStatus))
+
+			// Creamos una copia del status antes de actuar
+			results = append(results, status)
+
+			// Luego intentamos iniciar el servicio
+			if cfg.ExpectedStatus == serviceRunning && status.Status == serviceStopped && cfg.AutoStartIfStopped {
+				if err := startService(cfg.Name); err != nil {
+					status.addReason(newReason(reasonServiceStartFailed, "error", err.Error()))
+				} else {
+					status.addReason(newReason(reasonServiceAutoStarted))
+					status.Status = serviceRunning
+					sendAutoStartAlert(config.ServerURL, config.ServerVersion, cfg.Name)
 				}
+
+				// Registramos el nuevo estado después de intentar iniciar
+				results = append(results, status)
+				continue // ya agregamos ambos estados, continuamos
 			}
-			svc.Close()
 		}
 		results = append(results, status)
 	}
@@ -169,88 +151,73 @@ func checkServices(config Config) []ServiceStatus {
 	return results
 }
 
-// getServiceEventLogs obtiene los logs recientes de eventos del servicio usando PowerShell
-func getServiceEventLogs(serviceName string, minutes int) ([]ServiceEventLog, error) {
-	psCommand := fmt.Sprintf(
-		`Get-WinEvent -LogName Application -FilterXPath "*[System[Provider[@Name='%s'] and TimeCreated[timediff(@SystemTime) <= %d]]]" | Format-List TimeCreated,Message,LevelDisplayName`,
-		serviceName, minutes*60000)
-
-	cmd := exec.Command("powershell", "-Command", psCommand)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		return nil, err
-	}
-
-	rawOutput := out.String()
-	eventsRaw := strings.Split(rawOutput, "\n\n")
-
-	hostname, _ := os.Hostname()
-	ip, _ := GetOutboundIP()
-
-	var events []ServiceEventLog
-	var currentEvent ServiceEventLog
-	for _, ev := range eventsRaw {
-		lines := strings.Split(ev, "\n")
-		currentEvent = ServiceEventLog{
-			ServiceName: serviceName,
-			Hostname:    hostname,
-			IP:          ip,
-		}
-
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "TimeCreated") {
-				parts := strings.SplitN(line, ":", 2)
-				if len(parts) == 2 {
-					timestampStr := strings.TrimSpace(parts[1])
-					t, err := time.Parse("1/2/2006 3:04:05 PM", timestampStr)
-					if err == nil {
-						currentEvent.Timestamp = t
-					} else {
-						// Intentar otro formato común
-						t2, err2 := time.Parse(time.RFC3339, timestampStr)
-						if err2 == nil {
-							currentEvent.Timestamp = t2
-						}
-					}
-				}
-			} else if strings.HasPrefix(line, "Message") {
-				parts := strings.SplitN(line, ":", 2)
-				if len(parts) == 2 {
-					currentEvent.Message = strings.TrimSpace(parts[1])
-				}
-			} else if strings.HasPrefix(line, "LevelDisplayName") {
-				parts := strings.SplitN(line, ":", 2)
-				if len(parts) == 2 {
-					currentEvent.Level = strings.TrimSpace(parts[1])
-				}
-			}
-		}
+// reportNow permite adelantar el siguiente reporte sin esperar report_interval.
+var reportNow = make(chan struct{}, 1)
 
-		if currentEvent.Message != "" {
-			events = append(events, currentEvent)
-		}
+// triggerReport solicita un reporte inmediato. Si ya hay uno pendiente no hace
+// nada.
+func triggerReport() {
+	select {
+	case reportNow <- struct{}{}:
+	default:
 	}
-
-	return events, nil
 }
 
-func GetOutboundIP() (string, error) {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil {
-		return "", err
+// printJobsToConsole muestra en consola los trabajos que reporta el monitor
+// de impresoras.
+func printJobsToConsole(printJobs []PrinterIssueReport) {
+	for _, job := range printJobs {
+		fmt.Printf("🖨️ %s - 📄 %s - 👤 %s - 🚦 %s: 0x%X %v %s\n",
+			job.PrinterName, redactValue("document", job.Document), redactValue("user", job.User), job.Event, job.StatusCode, job.StatusFlags, job.StatusText)
 	}
-	defer conn.Close()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String(), nil
 }
 
 // runClientLoop ejecuta el cliente en bucle
 func runClientLoop() {
 	config := readConfig()
+	configureLogging(config)
+	configureLocale(config)
+	configureIPDiscovery(config)
+	configurePrivacy(config)
+	initAgentIdentity(config)
+	configurePrinterBackend(config)
+	startPrintAccounting(config)
+	// El monitor de impresoras arranca después de startPrintAccounting, que
+	// asigna printAccounting. En consola además muestra los trabajos.
+	showJobs := func([]PrinterIssueReport) {}
+	if !runningAsService {
+		showJobs = printJobsToConsole
+	}
+	safeGoRoutine("printer monitor", func() {
+		runPrinterMonitor(config, showJobs)
+	})
+	// Las alertas locales se evalúan en el muestreo, en consola y como
+	// servicio.
+	safeGoRoutine("system stats sampler", func() {
+		runStatsSampler(config, statsSamples)
+	})
+	// WebSocket de estadísticas y comandos remotos, en consola y como
+	// servicio.
+	commandAudit = newCommandAudit(config)
+	safeGoRoutine("system stats websocket", func() {
+		startSystemStatsWebSocket(config)
+	})
+	// Impresoras de red por SNMP, en consola y como servicio.
+	if len(config.NetworkPrinters.Printers) > 0 {
+		safeGoRoutine("network printer monitor", func() {
+			runNetworkPrinterMonitor(config)
+		})
+	}
+	safeGoRoutine("inventory reporter", func() {
+		runInventoryReporter(config)
+	})
+	safeGoRoutine("software inventory reporter", func() {
+		runSoftwareInventoryReporter(config)
+	})
+	serviceTransitions = newSpool(config, "service-transitions")
+	safeGoRoutine("service watcher", func() {
+		runServiceWatcher(config)
+	})
 	for {
 		var logs []ServiceLog
 		var eventLogs []ServiceEventLog
@@ -275,49 +242,72 @@ func runClientLoop() {
 			}
 
 			logs = append(logs, ServiceLog{
+				AgentID:     agentID,
 				Hostname:    hostname,
 				IP:          ip,
 				ServiceName: s.Name,
 				Status:      s.Status,
 				Timestamp:   timestamp,
 				Error:       s.Error,
+				Reasons:     s.Reasons,
 			})
 
 			// Obtener logs recientes del servicio si está configurado
 			if svcCfg != nil && svcCfg.FetchEventLogs {
 				evLogs, err := getServiceEventLogs(s.Name, config.EventLogMinutes)
 				if err != nil {
-					log.Printf("Error al obtener logs de eventos para %s: %v\n", s.Name, err)
+					servicesLog().Warn("Error al obtener logs de eventos", "service", s.Name, "error", err)
 				} else {
 					eventLogs = append(eventLogs, evLogs...)
 				}
 			}
 		}
 
+		// Transiciones registradas por las notificaciones desde el último
+		// reporte; se borran solo si el servidor confirma.
+		transitions := serviceTransitions.Peek()
+		rawTransitions := make([]json.RawMessage, len(transitions))
+		for i, t := range transitions {
+			rawTransitions[i] = t
+		}
+
 		// Enviamos ambos logs en un solo payload
 		payloadMap := map[string]interface{}{
-			"service_statuses": logs,
-			"event_logs":       eventLogs,
+			"agent_id":            agentID,
+			"ips":                 GetLocalIPs(),
+			"service_statuses":    logs,
+			"event_logs":          eventLogs,
+			"service_transitions": rawTransitions,
 		}
 
-		payload, _ := json.Marshal(payloadMap)
+		payload, _ := marshalReport(payloadMap)
 
-		resp, err := http.Post(fmt.Sprintf("%s/api/%s/log/report", config.ServerURL, config.ServerVersion), "application/json", bytes.NewBuffer(payload))
+		resp, err := httpClient.Post(fmt.Sprintf("%s/api/%s/log/report", config.ServerURL, config.ServerVersion), "application/json", bytes.NewBuffer(payload))
 		if err != nil {
-			log.Println("Error al enviar logs:", err)
-			LogErrorToFile(err, payload)
+			logSendError(reportLog(), "service_report", err, payload)
 		} else {
 			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				serviceTransitions.Discard(transitions)
+			}
 			var response ServerResponse
 			body, _ := ioutil.ReadAll(resp.Body)
 			json.Unmarshal(body, &response)
 			if response.UpdateConfig != nil {
-				log.Println("Configuración actualizada desde el servidor.")
-				writeConfig(*response.UpdateConfig)
-				config = *response.UpdateConfig
+				reportLog().Info("Configuración actualizada desde el servidor.")
+				config = applyServerConfig(config, *response.UpdateConfig)
+				writeConfig(config)
+				configureLogging(config)
+				configureLocale(config)
+				configureIPDiscovery(config)
+				configurePrivacy(config)
+				configurePrinterBackend(config)
 			}
 		}
 
-		time.Sleep(time.Duration(config.ReportInterval) * time.Second)
+		select {
+		case <-time.After(time.Duration(config.ReportInterval) * time.Second):
+		case <-reportNow:
+		}
 	}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Collector obtiene un grupo de métricas que se agrega a SystemStats bajo su
+// nombre. Las implementaciones pueden guardar estado entre llamadas para
+// calcular tasas.
+type Collector interface {
+	Name() string
+	Collect() (interface{}, error)
+}
+
+type CollectorConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Interval uint `yaml:"interval"` // Segundos, 0 = en cada muestra
+}
+
+type collectorFactory func(config Config) Collector
+
+type collectorRegistration struct {
+	factory  collectorFactory
+	defaults CollectorConfig
+}
+
+var collectorRegistry = map[string]collectorRegistration{}
+
+// registerCollector agrega un collector al registro con su configuración por
+// defecto, que se usa cuando config.yaml no lo menciona.
+func registerCollector(name string, defaults CollectorConfig, factory collectorFactory) {
+	if _, exists := collectorRegistry[name]; exists {
+		log.Fatalf("Collector '%s' registrado dos veces", name)
+	}
+	collectorRegistry[name] = collectorRegistration{factory: factory, defaults: defaults}
+}
+
+type scheduledCollector struct {
+	collector Collector
+	interval  time.Duration
+	lastRun   time.Time
+}
+
+// collectorRunner ejecuta los collectores habilitados respetando el
+// intervalo de cada uno.
+type collectorRunner struct {
+	collectors []*scheduledCollector
+}
+
+func newCollectorRunner(config Config) *collectorRunner {
+	names := make([]string, 0, len(collectorRegistry))
+	for name := range collectorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for name := range config.Collectors {
+		if _, ok := collectorRegistry[name]; !ok {
+			statsLog().Warn("Collector desconocido en la configuración", "collector", name)
+		}
+	}
+
+	// top_processes.enabled e interval siguen valiendo si no hay
+	// collectors.top_processes.
+	legacy, hasLegacy := config.Processes.legacyCollectorConfig(collectorRegistry["top_processes"].defaults)
+	if hasLegacy {
+		statsLog().Warn("top_processes.enabled y top_processes.interval están obsoletos, usar collectors.top_processes")
+	}
+
+	runner := &collectorRunner{}
+	for _, name := range names {
+		reg := collectorRegistry[name]
+		cfg, ok := config.Collectors[name]
+		if !ok {
+			cfg = reg.defaults
+			if name == "top_processes" && hasLegacy {
+				cfg = legacy
+			}
+		}
+		if !cfg.Enabled {
+			continue
+		}
+		runner.collectors = append(runner.collectors, &scheduledCollector{
+			collector: reg.factory(config),
+			interval:  time.Duration(cfg.Interval) * time.Second,
+		})
+	}
+	return runner
+}
+
+// Collect ejecuta los collectores cuyo intervalo ya venció y devuelve sus
+// resultados indexados por nombre.
+func (r *collectorRunner) Collect() map[string]interface{} {
+	now := time.Now()
+	metrics := make(map[string]interface{})
+	for _, sc := range r.collectors {
+		if !sc.lastRun.IsZero() && now.Sub(sc.lastRun) < sc.interval {
+			continue
+		}
+		sc.lastRun = now
+
+		value, err := sc.collector.Collect()
+		if err != nil {
+			statsLog().Error("Error en collector", "collector", sc.collector.Name(), "error", err)
+			continue
+		}
+		if value != nil {
+			metrics[sc.collector.Name()] = value
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+func init() {
+	registerCollector("cpu", CollectorConfig{Enabled: true}, func(Config) Collector { return &cpuCollector{} })
+	registerCollector("memory", CollectorConfig{Enabled: true}, func(Config) Collector { return &memoryCollector{} })
+	registerCollector("disk_io", CollectorConfig{Enabled: true}, func(Config) Collector { return newDiskIOCollector() })
+	registerCollector("uptime", CollectorConfig{Enabled: true, Interval: 60}, func(Config) Collector { return &uptimeCollector{} })
+	registerCollector("users", CollectorConfig{Enabled: true, Interval: 60}, func(Config) Collector { return &usersCollector{} })
+}
+
+// --- cpu ---
+
+type CPUMetrics struct {
+	PerCore               []float64 `json:"per_core"`
+	Load1                 float64   `json:"load1"`
+	Load5                 float64   `json:"load5"`
+	Load15                float64   `json:"load15"`
+	ContextSwitchesPerSec float64   `json:"context_switches_per_sec,omitempty"`
+}
+
+type cpuCollector struct {
+	prevCtxt int
+	prevTime time.Time
+}
+
+func (c *cpuCollector) Name() string { return "cpu" }
+
+// Collect devuelve el uso por núcleo y la carga promedio. En Windows la carga
+// es la emulación de gopsutil sobre la cola del procesador y los cambios de
+// contexto se obtienen con el collector pdh (\System\Context Switches/sec).
+func (c *cpuCollector) Collect() (interface{}, error) {
+	perCore, err := cpu.Percent(0, true)
+	if err != nil {
+		return nil, err
+	}
+	metrics := CPUMetrics{PerCore: perCore}
+
+	if avg, err := load.Avg(); err == nil {
+		metrics.Load1, metrics.Load5, metrics.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+
+	if misc, err := load.Misc(); err == nil {
+		now := time.Now()
+		if !c.prevTime.IsZero() && misc.Ctxt >= c.prevCtxt {
+			metrics.ContextSwitchesPerSec = float64(misc.Ctxt-c.prevCtxt) / now.Sub(c.prevTime).Seconds()
+		}
+		c.prevCtxt, c.prevTime = misc.Ctxt, now
+	}
+
+	return metrics, nil
+}
+
+// --- memory ---
+
+type MemoryMetrics struct {
+	CommitTotal     uint64  `json:"commit_total"`
+	CommitLimit     uint64  `json:"commit_limit"`
+	CommitPeak      uint64  `json:"commit_peak"`
+	Cache           uint64  `json:"cache"`
+	KernelPaged     uint64  `json:"kernel_paged"`
+	KernelNonpaged  uint64  `json:"kernel_nonpaged"`
+	SwapTotal       uint64  `json:"swap_total"`
+	SwapUsed        uint64  `json:"swap_used"`
+	SwapUsedPercent float64 `json:"swap_used_percent"`
+}
+
+type memoryCollector struct{}
+
+func (c *memoryCollector) Name() string { return "memory" }
+
+// --- disk_io ---
+
+type DiskIOMetrics struct {
+	Name             string  `json:"name"`
+	ReadBytesPerSec  float64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec float64 `json:"write_bytes_per_sec"`
+	ReadsPerSec      float64 `json:"reads_per_sec"`
+	WritesPerSec     float64 `json:"writes_per_sec"`
+	IopsInProgress   uint64  `json:"iops_in_progress"`
+}
+
+type diskIOCollector struct {
+	prev     map[string]disk.IOCountersStat
+	prevTime time.Time
+}
+
+func newDiskIOCollector() *diskIOCollector {
+	return &diskIOCollector{prev: make(map[string]disk.IOCountersStat)}
+}
+
+func (c *diskIOCollector) Name() string { return "disk_io" }
+
+// Collect devuelve las tasas de lectura y escritura por disco desde la
+// muestra anterior.
+func (c *diskIOCollector) Collect() (interface{}, error) {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(c.prevTime).Seconds()
+
+	var metrics []DiskIOMetrics
+	for name, cur := range counters {
+		m := DiskIOMetrics{Name: name, IopsInProgress: cur.IopsInProgress}
+		if prev, ok := c.prev[name]; ok && elapsed > 0 {
+			m.ReadBytesPerSec = counterRate(prev.ReadBytes, cur.ReadBytes, elapsed)
+			m.WriteBytesPerSec = counterRate(prev.WriteBytes, cur.WriteBytes, elapsed)
+			m.ReadsPerSec = counterRate(prev.ReadCount, cur.ReadCount, elapsed)
+			m.WritesPerSec = counterRate(prev.WriteCount, cur.WriteCount, elapsed)
+		}
+		metrics = append(metrics, m)
+	}
+
+	c.prev = counters
+	c.prevTime = now
+	return metrics, nil
+}
+
+// --- uptime ---
+
+type UptimeMetrics struct {
+	BootTime      time.Time `json:"boot_time"`
+	UptimeSeconds uint64    `json:"uptime_seconds"`
+}
+
+type uptimeCollector struct{}
+
+func (c *uptimeCollector) Name() string { return "uptime" }
+
+func (c *uptimeCollector) Collect() (interface{}, error) {
+	boot, err := host.BootTime()
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := host.Uptime()
+	if err != nil {
+		return nil, err
+	}
+	return UptimeMetrics{BootTime: time.Unix(int64(boot), 0), UptimeSeconds: uptime}, nil
+}
+
+// --- users ---
+
+type LoggedInUser struct {
+	User      string `json:"user"`
+	Domain    string `json:"domain,omitempty"`
+	SessionID uint32 `json:"session_id"`
+	Station   string `json:"station"`
+	State     string `json:"state"`
+}
+
+type usersCollector struct{}
+
+func (c *usersCollector) Name() string { return "users" }
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package main
+
+import (
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Collect devuelve los mismos campos que en Windows a partir de
+// /proc/meminfo: el commit es Committed_AS y CommitLimit, y el pico no existe.
+func (c *memoryCollector) Collect() (interface{}, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	metrics := MemoryMetrics{
+		CommitTotal:    vm.CommittedAS,
+		CommitLimit:    vm.CommitLimit,
+		Cache:          vm.Cached,
+		KernelPaged:    vm.Sreclaimable,
+		KernelNonpaged: vm.Sunreclaim,
+		SwapTotal:      vm.SwapTotal,
+		SwapUsed:       vm.SwapTotal - vm.SwapFree,
+	}
+	if metrics.SwapTotal > 0 {
+		metrics.SwapUsedPercent = float64(metrics.SwapUsed) / float64(metrics.SwapTotal) * 100
+	}
+	return metrics, nil
+}
+
+// Collect devuelve las sesiones de utmp. No hay estado de sesión, así que
+// todas se informan como active.
+func (c *usersCollector) Collect() (interface{}, error) {
+	stats, err := host.Users()
+	if err != nil {
+		return nil, err
+	}
+	users := []LoggedInUser{}
+	for _, s := range stats {
+		users = append(users, LoggedInUser{
+			User:    s.User,
+			Station: s.Terminal,
+			State:   "active",
+		})
+	}
+	return users, nil
+}
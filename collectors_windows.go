@@ -0,0 +1,121 @@
+package main
+
+import (
+	"unsafe"
+
+	"github.com/shirou/gopsutil/v3/mem"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	psapi                          = windows.NewLazySystemDLL("psapi.dll")
+	procGetPerformanceInfo         = psapi.NewProc("GetPerformanceInfo")
+	wtsapi32                       = windows.NewLazySystemDLL("wtsapi32.dll")
+	procWTSQuerySessionInformation = wtsapi32.NewProc("WTSQuerySessionInformationW")
+)
+
+const (
+	wtsUserName   = 5
+	wtsDomainName = 7
+)
+
+// performanceInformation corresponde a PERFORMANCE_INFORMATION de psapi.
+type performanceInformation struct {
+	cb                uint32
+	commitTotal       uintptr
+	commitLimit       uintptr
+	commitPeak        uintptr
+	physicalTotal     uintptr
+	physicalAvailable uintptr
+	systemCache       uintptr
+	kernelTotal       uintptr
+	kernelPaged       uintptr
+	kernelNonpaged    uintptr
+	pageSize          uintptr
+	handleCount       uint32
+	processCount      uint32
+	threadCount       uint32
+}
+
+// Collect devuelve commit, caché y memoria del kernel en bytes, y el uso de
+// los archivos de paginación como swap.
+func (c *memoryCollector) Collect() (interface{}, error) {
+	var info performanceInformation
+	info.cb = uint32(unsafe.Sizeof(info))
+	ret, _, err := procGetPerformanceInfo.Call(uintptr(unsafe.Pointer(&info)), uintptr(info.cb))
+	if ret == 0 {
+		return nil, err
+	}
+
+	page := uint64(info.pageSize)
+	metrics := MemoryMetrics{
+		CommitTotal:    uint64(info.commitTotal) * page,
+		CommitLimit:    uint64(info.commitLimit) * page,
+		CommitPeak:     uint64(info.commitPeak) * page,
+		Cache:          uint64(info.systemCache) * page,
+		KernelPaged:    uint64(info.kernelPaged) * page,
+		KernelNonpaged: uint64(info.kernelNonpaged) * page,
+	}
+
+	if devices, err := mem.SwapDevices(); err == nil {
+		for _, d := range devices {
+			metrics.SwapTotal += d.UsedBytes + d.FreeBytes
+			metrics.SwapUsed += d.UsedBytes
+		}
+		if metrics.SwapTotal > 0 {
+			metrics.SwapUsedPercent = float64(metrics.SwapUsed) / float64(metrics.SwapTotal) * 100
+		}
+	}
+
+	return metrics, nil
+}
+
+// Collect enumera las sesiones de Terminal Services que tienen un usuario
+// asociado (consola y RDP).
+func (c *usersCollector) Collect() (interface{}, error) {
+	var sessions *windows.WTS_SESSION_INFO
+	var count uint32
+	if err := windows.WTSEnumerateSessions(0, 0, 1, &sessions, &count); err != nil {
+		return nil, err
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(sessions)))
+
+	users := []LoggedInUser{}
+	for _, s := range unsafe.Slice(sessions, count) {
+		user := wtsSessionString(s.SessionID, wtsUserName)
+		if user == "" {
+			continue
+		}
+		state := "other"
+		switch s.State {
+		case windows.WTSActive:
+			state = "active"
+		case windows.WTSDisconnected:
+			state = "disconnected"
+		case windows.WTSIdle:
+			state = "idle"
+		}
+		users = append(users, LoggedInUser{
+			User:      user,
+			Domain:    wtsSessionString(s.SessionID, wtsDomainName),
+			SessionID: s.SessionID,
+			Station:   windows.UTF16PtrToString(s.WindowStationName),
+			State:     state,
+		})
+	}
+	return users, nil
+}
+
+func wtsSessionString(sessionID uint32, infoClass uintptr) string {
+	var buf *uint16
+	var size uint32
+	ret, _, _ := procWTSQuerySessionInformation.Call(
+		0, uintptr(sessionID), infoClass,
+		uintptr(unsafe.Pointer(&buf)), uintptr(unsafe.Pointer(&size)),
+	)
+	if ret == 0 || buf == nil {
+		return ""
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(buf)))
+	return windows.UTF16PtrToString(buf)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type CommandsConfig struct {
+	Secret  string   `yaml:"secret"`
+	MaxAge  uint     `yaml:"max_age"` // Segundos
+	Allowed []string `yaml:"allowed"`
+}
+
+// CommandRequest es el payload de un mensaje "command". La firma es
+// HMAC-SHA256 con commands.secret sobre el ID del mensaje, el comando,
+// issued_at, nonce y los bytes exactos de args, separados por "\n".
+// IssuedAt se guarda tal como llegó porque es el texto que firmó el servidor.
+type CommandRequest struct {
+	Command   string          `json:"command"`
+	Args      json.RawMessage `json:"args,omitempty"`
+	IssuedAt  string          `json:"issued_at"`
+	Nonce     string          `json:"nonce"`
+	Signature string          `json:"signature"`
+}
+
+type CommandResult struct {
+	Command    string      `json:"command"`
+	Status     string      `json:"status"` // ok | error | rejected
+	Output     interface{} `json:"output,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Reason     *Reason     `json:"reason,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+}
+
+type CommandAudit struct {
+	ID         string          `json:"id"`
+	Command    string          `json:"command"`
+	Args       json.RawMessage `json:"args,omitempty"`
+	IssuedAt   string          `json:"issued_at"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Reason     *Reason         `json:"reason,omitempty"`
+	AgentID    string          `json:"agent_id"`
+	Hostname   string          `json:"hostname"`
+	IP         string          `json:"ip"`
+}
+
+type commandArgs struct {
+	Service string `json:"service"`
+	Minutes int    `json:"minutes"`
+	Printer string `json:"printer"`
+	JobID   uint32 `json:"job_id"`
+}
+
+type commandFunc func(config Config, args commandArgs) (interface{}, error)
+
+var commandFuncs = map[string]commandFunc{
+	"start_service":    cmdStartService,
+	"stop_service":     cmdStopService,
+	"restart_service":  cmdRestartService,
+	"run_check":        cmdRunCheck,
+	"fetch_event_logs": cmdFetchEventLogs,
+	"cancel_print_job": cmdCancelPrintJob,
+	"report_now":       cmdReportNow,
+}
+
+func init() {
+	registerWSHandler("command", handleCommand)
+}
+
+// commandAudit guarda los registros de auditoría en el directorio de datos.
+// Se inicializa en runClientLoop antes de abrir el WebSocket.
+var commandAudit *spool
+
+// seenNonces evita que un comando firmado se ejecute dos veces.
+var seenNonces = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+func handleCommand(s *wsSession, msg WSMessage) {
+	received := time.Now()
+	var req CommandRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		result := CommandResult{Status: "rejected", StartedAt: received, FinishedAt: received}
+		result.setReason(newReason(reasonCommandInvalidPayload, "error", err.Error()))
+		s.Reply(msg, "command_result", result)
+		return
+	}
+
+	if err := authorizeCommand(s.config, msg.ID, req, received); err != nil {
+		reason := reasonOf(err, reasonCommandFailed)
+		slog.Warn("Comando rechazado", "command", req.Command, "id", msg.ID, "reason", reason.Code, "error", reason.Localized())
+		result := CommandResult{Command: req.Command, Status: "rejected", StartedAt: received, FinishedAt: time.Now()}
+		result.setReason(reason)
+		s.Reply(msg, "command_result", result)
+		sendCommandAudit(s, msg.ID, req, received, result)
+		return
+	}
+
+	// Los comandos pueden tardar (detener un servicio), no bloqueamos la lectura.
+	go func() {
+		result := runCommand(s.config, req)
+		s.Reply(msg, "command_result", result)
+		sendCommandAudit(s, msg.ID, req, received, result)
+	}()
+}
+
+// authorizeCommand verifica firma, vigencia, nonce y allowlist.
+func authorizeCommand(config Config, id string, req CommandRequest, now time.Time) error {
+	if config.Commands.Secret == "" {
+		return newReason(reasonCommandsDisabled)
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.Commands.Secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n", id, req.Command, req.IssuedAt, req.Nonce)
+	mac.Write(req.Args)
+	expected := mac.Sum(nil)
+	got, err := hex.DecodeString(req.Signature)
+	if err != nil || !hmac.Equal(expected, got) {
+		return newReason(reasonCommandSignatureInvalid)
+	}
+
+	issuedAt, err := time.Parse(time.RFC3339Nano, req.IssuedAt)
+	if err != nil {
+		return newReason(reasonCommandInvalidPayload, "error", err.Error())
+	}
+	maxAge := time.Duration(config.Commands.MaxAge) * time.Second
+	if maxAge == 0 {
+		maxAge = 5 * time.Minute
+	}
+	if now.Sub(issuedAt) > maxAge || issuedAt.Sub(now) > maxAge {
+		return newReason(reasonCommandExpired, "issued_at", req.IssuedAt)
+	}
+
+	seenNonces.Lock()
+	for nonce, seen := range seenNonces.m {
+		if now.Sub(seen) > 2*maxAge {
+			delete(seenNonces.m, nonce)
+		}
+	}
+	if _, dup := seenNonces.m[req.Nonce]; dup || req.Nonce == "" {
+		seenNonces.Unlock()
+		return newReason(reasonCommandNonceReused)
+	}
+	seenNonces.m[req.Nonce] = now
+	seenNonces.Unlock()
+
+	allowed := false
+	for _, a := range config.Commands.Allowed {
+		if a == req.Command {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return newReason(reasonCommandNotAllowed, "command", req.Command)
+	}
+	if _, ok := commandFuncs[req.Command]; !ok {
+		return newReason(reasonCommandUnknown, "command", req.Command)
+	}
+	return nil
+}
+
+// setReason guarda el motivo y su texto en Error, que los servidores
+// anteriores siguen leyendo.
+func (r *CommandResult) setReason(reason Reason) {
+	r.Reason = &reason
+	r.Error = reason.Error()
+}
+
+func runCommand(config Config, req CommandRequest) CommandResult {
+	result := CommandResult{Command: req.Command, StartedAt: time.Now()}
+
+	var args commandArgs
+	if len(req.Args) > 0 {
+		if err := json.Unmarshal(req.Args, &args); err != nil {
+			result.Status = "error"
+			result.setReason(newReason(reasonCommandInvalidArgs, "error", err.Error()))
+			result.FinishedAt = time.Now()
+			return result
+		}
+	}
+
+	slog.Info("Ejecutando comando remoto", "command", req.Command, "args", string(req.Args))
+	output, err := commandFuncs[req.Command](config, args)
+	result.FinishedAt = time.Now()
+	result.Output = output
+	if err != nil {
+		result.Status = "error"
+		result.setReason(reasonOf(err, reasonCommandFailed))
+	} else {
+		result.Status = "ok"
+	}
+	return result
+}
+
+// sendCommandAudit envía el registro de auditoría por el socket y lo guarda
+// también en el directorio de datos.
+func sendCommandAudit(s *wsSession, id string, req CommandRequest, received time.Time, result CommandResult) {
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
+	audit := CommandAudit{
+		ID:         id,
+		Command:    req.Command,
+		Args:       req.Args,
+		IssuedAt:   req.IssuedAt,
+		ReceivedAt: received,
+		Status:     result.Status,
+		Error:      result.Error,
+		Reason:     result.Reason,
+		AgentID:    agentID,
+		Hostname:   hostname,
+		IP:         ip,
+	}
+
+	if err := s.Send("audit", newMessageID(), audit); err != nil {
+		slog.Warn("No se pudo enviar auditoría de comando", "error", err)
+	}
+
+	payload, _ := json.Marshal(audit)
+	commandAudit.Append(payload)
+}
+
+// newCommandAudit crea el registro local de auditoría. Usa el mismo formato
+// y límite que los spools, pero nunca se reenvía: solo conserva los
+// maxSpoolEntries registros más recientes.
+func newCommandAudit(config Config) *spool {
+	return &spool{path: config.dataPath("command-audit.jsonl"), maxEntries: maxSpoolEntries}
+}
+
+// applyServerConfig aplica un update_config del servidor conservando la
+// sección commands local: una respuesta falsificada no puede cambiar el
+// secreto ni la lista de comandos permitidos.
+func applyServerConfig(current, update Config) Config {
+	if !reflect.DeepEqual(update.Commands, CommandsConfig{}) && !reflect.DeepEqual(current.Commands, update.Commands) {
+		slog.Warn("update_config intentó cambiar commands; se conserva la configuración local")
+	}
+	update.Commands = current.Commands
+	return update
+}
+
+// checkControllable exige que el servicio esté entre los monitoreados en
+// config.yaml.
+func checkControllable(config Config, name string) error {
+	for _, svcCfg := range config.Services {
+		if svcCfg.Name == name {
+			return nil
+		}
+	}
+	return newReason(reasonCommandServiceNotMonitored, "service", name)
+}
+
+func cmdStartService(config Config, args commandArgs) (interface{}, error) {
+	if err := checkControllable(config, args.Service); err != nil {
+		return nil, err
+	}
+	if err := startService(args.Service); err != nil {
+		return nil, err
+	}
+	return nil, waitServiceState(args.Service, serviceRunning)
+}
+
+func cmdStopService(config Config, args commandArgs) (interface{}, error) {
+	if err := checkControllable(config, args.Service); err != nil {
+		return nil, err
+	}
+	if err := stopService(args.Service); err != nil {
+		return nil, err
+	}
+	return nil, waitServiceState(args.Service, serviceStopped)
+}
+
+func cmdRestartService(config Config, args commandArgs) (interface{}, error) {
+	if err := checkControllable(config, args.Service); err != nil {
+		return nil, err
+	}
+	return nil, restartService(args.Service)
+}
+
+func cmdRunCheck(config Config, args commandArgs) (interface{}, error) {
+	return checkServices(config), nil
+}
+
+func cmdFetchEventLogs(config Config, args commandArgs) (interface{}, error) {
+	if err := checkControllable(config, args.Service); err != nil {
+		return nil, err
+	}
+	minutes := args.Minutes
+	if minutes <= 0 {
+		minutes = config.EventLogMinutes
+	}
+	return getServiceEventLogs(args.Service, minutes)
+}
+
+func cmdCancelPrintJob(config Config, args commandArgs) (interface{}, error) {
+	if args.Printer == "" || args.JobID == 0 {
+		return nil, newReason(reasonCommandMissingArgs, "args", "printer, job_id")
+	}
+	accountant.MarkDeleted(args.Printer, args.JobID)
+	return nil, currentPrinterBackend().ControlJob(args.Printer, args.JobID, jobControlDelete)
+}
+
+func cmdReportNow(config Config, args commandArgs) (interface{}, error) {
+	triggerReport()
+	return nil, nil
+}
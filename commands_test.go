@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testCommandSecret = "secreto-de-prueba"
+
+// signCommand firma como el servidor, sobre el texto exacto de cada campo.
+func signCommand(id string, req CommandRequest) CommandRequest {
+	mac := hmac.New(sha256.New, []byte(testCommandSecret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n", id, req.Command, req.IssuedAt, req.Nonce)
+	mac.Write(req.Args)
+	req.Signature = hex.EncodeToString(mac.Sum(nil))
+	return req
+}
+
+func TestAuthorizeCommand(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	config := Config{Commands: CommandsConfig{
+		Secret:  testCommandSecret,
+		MaxAge:  60,
+		Allowed: []string{"restart_service", "report_now"},
+	}}
+	args := json.RawMessage(`{"service":"Spooler"}`)
+
+	// El servidor firma issued_at tal como lo manda, con su propia
+	// precisión y zona; el agente no lo reformatea.
+	issued := "2024-05-01T08:59:30.120-03:00"
+
+	tests := []struct {
+		name   string
+		config Config
+		id     string
+		req    CommandRequest
+		tamper func(*CommandRequest)
+		want   string // código del motivo, vacío si se autoriza
+	}{
+		{"válido", config, "m1", CommandRequest{Command: "restart_service", Args: args, IssuedAt: issued, Nonce: "n1"}, nil, ""},
+		{"sin args", config, "m2", CommandRequest{Command: "report_now", IssuedAt: "2024-05-01T12:00:10Z", Nonce: "n2"}, nil, ""},
+		{"args alterados", config, "m3", CommandRequest{Command: "restart_service", Args: args, IssuedAt: issued, Nonce: "n3"},
+			func(r *CommandRequest) { r.Args = json.RawMessage(`{"service":"WinRM"}`) }, reasonCommandSignatureInvalid},
+		{"args reformateados", config, "m4", CommandRequest{Command: "restart_service", Args: args, IssuedAt: issued, Nonce: "n4"},
+			func(r *CommandRequest) { r.Args = json.RawMessage(`{"service": "Spooler"}`) }, reasonCommandSignatureInvalid},
+		{"issued_at reformateado", config, "m5", CommandRequest{Command: "restart_service", Args: args, IssuedAt: issued, Nonce: "n5"},
+			func(r *CommandRequest) { r.IssuedAt = "2024-05-01T11:59:30.12Z" }, reasonCommandSignatureInvalid},
+		{"comando cambiado", config, "m6", CommandRequest{Command: "report_now", IssuedAt: issued, Nonce: "n6"},
+			func(r *CommandRequest) { r.Command = "restart_service" }, reasonCommandSignatureInvalid},
+		{"firma no hexadecimal", config, "m7", CommandRequest{Command: "report_now", IssuedAt: issued, Nonce: "n7"},
+			func(r *CommandRequest) { r.Signature = "zz" }, reasonCommandSignatureInvalid},
+		{"nonce repetido", config, "m8", CommandRequest{Command: "report_now", IssuedAt: issued, Nonce: "n1"}, nil, reasonCommandNonceReused},
+		{"sin nonce", config, "m9", CommandRequest{Command: "report_now", IssuedAt: issued}, nil, reasonCommandNonceReused},
+		{"vencido", config, "m10", CommandRequest{Command: "report_now", IssuedAt: "2024-05-01T11:58:59Z", Nonce: "n10"}, nil, reasonCommandExpired},
+		{"en el futuro", config, "m11", CommandRequest{Command: "report_now", IssuedAt: "2024-05-01T12:01:01Z", Nonce: "n11"}, nil, reasonCommandExpired},
+		{"issued_at inválido", config, "m12", CommandRequest{Command: "report_now", IssuedAt: "ayer", Nonce: "n12"}, nil, reasonCommandInvalidPayload},
+		{"no permitido", config, "m13", CommandRequest{Command: "stop_service", IssuedAt: issued, Nonce: "n13"}, nil, reasonCommandNotAllowed},
+		{"permitido pero desconocido", Config{Commands: CommandsConfig{Secret: testCommandSecret, Allowed: []string{"format_disk"}}},
+			"m14", CommandRequest{Command: "format_disk", IssuedAt: issued, Nonce: "n14"}, nil, reasonCommandUnknown},
+		{"sin secreto", Config{Commands: CommandsConfig{Allowed: []string{"report_now"}}},
+			"m15", CommandRequest{Command: "report_now", IssuedAt: issued, Nonce: "n15"}, nil, reasonCommandsDisabled},
+	}
+	for _, tt := range tests {
+		req := signCommand(tt.id, tt.req)
+		if tt.tamper != nil {
+			tt.tamper(&req)
+		}
+		err := authorizeCommand(tt.config, tt.id, req, now)
+		got := ""
+		if err != nil {
+			got = reasonOf(err, "").Code
+		}
+		if got != tt.want {
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// Un comando rechazado por firma no consume el nonce, ni uno firmado para
+// otro mensaje; uno autorizado sí.
+func TestAuthorizeCommandNonceOnlyAfterSignature(t *testing.T) {
+	now := time.Now().UTC()
+	config := Config{Commands: CommandsConfig{Secret: testCommandSecret, Allowed: []string{"report_now"}}}
+	req := signCommand("x1", CommandRequest{Command: "report_now", IssuedAt: now.Format(time.RFC3339Nano), Nonce: "nonce-unico"})
+
+	bad := req
+	bad.Signature = hex.EncodeToString(make([]byte, 32))
+	if err := authorizeCommand(config, "x1", bad, now); reasonOf(err, "").Code != reasonCommandSignatureInvalid {
+		t.Fatalf("firma falsa: %v", err)
+	}
+	if err := authorizeCommand(config, "x2", req, now); reasonOf(err, "").Code != reasonCommandSignatureInvalid {
+		t.Fatalf("firma de otro ID de mensaje: %v", err)
+	}
+	if err := authorizeCommand(config, "x1", req, now); err != nil {
+		t.Fatalf("primer uso: %v", err)
+	}
+	if err := authorizeCommand(config, "x1", req, now); reasonOf(err, "").Code != reasonCommandNonceReused {
+		t.Fatalf("replay: %v", err)
+	}
+}
+
+func TestServiceCommandsRequireMonitoredService(t *testing.T) {
+	config := Config{Services: []ServiceConfig{{Name: "Spooler"}}}
+	cmds := map[string]commandFunc{
+		"start_service":    cmdStartService,
+		"stop_service":     cmdStopService,
+		"restart_service":  cmdRestartService,
+		"fetch_event_logs": cmdFetchEventLogs,
+	}
+	for name, cmd := range cmds {
+		_, err := cmd(config, commandArgs{Service: "WinRM"})
+		if r := reasonOf(err, ""); r.Code != reasonCommandServiceNotMonitored || r.Params["service"] != "WinRM" {
+			t.Errorf("%s con un servicio no listado: %v", name, err)
+		}
+	}
+}
+
+func TestCancelPrintJobRequiresArgs(t *testing.T) {
+	_, err := cmdCancelPrintJob(Config{}, commandArgs{Printer: "HP"})
+	if reasonOf(err, "").Code != reasonCommandMissingArgs {
+		t.Fatalf("sin job_id: %v", err)
+	}
+}
+
+// update_config no puede cambiar ni borrar la sección commands local.
+func TestApplyServerConfigKeepsCommands(t *testing.T) {
+	local := Config{ServerURL: "http://viejo", Commands: CommandsConfig{Secret: "local", Allowed: []string{"restart_service"}}}
+	for _, remote := range []CommandsConfig{
+		{},
+		{Secret: "atacante", Allowed: []string{"start_service", "stop_service"}},
+	} {
+		got := applyServerConfig(local, Config{ServerURL: "http://nuevo", Commands: remote})
+		if got.ServerURL != "http://nuevo" {
+			t.Errorf("server_url = %q, se esperaba la del servidor", got.ServerURL)
+		}
+		if !reflect.DeepEqual(got.Commands, local.Commands) {
+			t.Errorf("commands = %+v, se esperaba %+v", got.Commands, local.Commands)
+		}
+	}
+}
@@ -3,18 +3,39 @@ package main
 import (
 	"io/ioutil"
 	"log"
+	"log/slog"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	ServerURL       string          `yaml:"server_url"`
-	ServerVersion   string          `yaml:"server_version"`
-	ReportInterval  uint            `yaml:"report_interval"`
-	MonitorInterval uint            `yaml:"monitor_interval"`
-	Services        []ServiceConfig `yaml:"services"`
-	EventLogMinutes int             `yaml:"event_log_minutes"`
+	ServerURL         string                     `yaml:"server_url"`
+	ServerVersion     string                     `yaml:"server_version"`
+	ReportInterval    uint                       `yaml:"report_interval"`
+	MonitorInterval   uint                       `yaml:"monitor_interval"`
+	Services          []ServiceConfig            `yaml:"services"`
+	EventLogMinutes   int                        `yaml:"event_log_minutes"`
+	Processes         ProcessConfig              `yaml:"top_processes"`
+	Collectors        map[string]CollectorConfig `yaml:"collectors"`
+	PDH               PDHConfig                  `yaml:"pdh"`
+	Alerts            []AlertRule                `yaml:"alerts"`
+	DataDir           string                     `yaml:"data_dir"`
+	Commands          CommandsConfig             `yaml:"commands"`
+	AgentIDSource     string                     `yaml:"agent_id_source"` // random | machine
+	PreferredSubnet   string                     `yaml:"preferred_subnet"`
+	IPRefreshInterval uint                       `yaml:"ip_refresh_interval"` // Segundos
+	InventoryInterval uint                       `yaml:"inventory_interval"`  // Segundos
+	StuckJobs         StuckJobsConfig            `yaml:"stuck_jobs"`
+	Printers          PrintersConfig             `yaml:"printers"`
+	PrintAccounting   PrintAccountingConfig      `yaml:"print_accounting"`
+	PrinterBackend    string                     `yaml:"printer_backend"` // winspool | ipp
+	IPP               IPPConfig                  `yaml:"ipp"`
+	NetworkPrinters   NetworkPrintersConfig      `yaml:"network_printers"`
+	Privacy           PrivacyConfig              `yaml:"privacy"`
+	Logging           LoggingConfig              `yaml:"logging"`
+	Locale            string                     `yaml:"locale"` // es | en, para log y consola
 }
 
 func (c *Config) ServerURLNoProtocol() string {
@@ -28,6 +49,16 @@ func (c *Config) ServerURLNoProtocol() string {
 	return url
 }
 
+// dataPath devuelve la ruta de un archivo dentro del directorio de datos del
+// agente (spools y estado local).
+func (c *Config) dataPath(name string) string {
+	dir := c.DataDir
+	if dir == "" {
+		dir = "data"
+	}
+	return filepath.Join(dir, name)
+}
+
 type ServiceConfig struct {
 	Name               string `yaml:"name" json:"name"`
 	ExpectedStatus     string `yaml:"expected_status" json:"expected_status"`
@@ -51,11 +82,11 @@ func readConfig() Config {
 func writeConfig(newConfig Config) {
 	data, err := yaml.Marshal(newConfig)
 	if err != nil {
-		log.Println("Error al serializar nueva configuración:", err)
+		slog.Error("Error al serializar nueva configuración", "error", err)
 		return
 	}
 	err = ioutil.WriteFile("config.yaml", data, 0644)
 	if err != nil {
-		log.Println("Error al guardar nueva configuración:", err)
+		slog.Error("Error al guardar nueva configuración", "error", err)
 	}
 }
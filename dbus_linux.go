@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Cliente D-Bus mínimo: lo justo para llamar métodos con argumentos string y
+// recibir señales. Implementa el formato de la especificación de D-Bus sin
+// depender de libdbus.
+
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+
+	defaultSystemBus = "/run/dbus/system_bus_socket"
+	dbusMaxMessage   = 128 << 20
+)
+
+type dbusMessage struct {
+	Type        byte
+	Serial      uint32
+	Path        string
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Sender      string
+	Signature   string
+	Body        []interface{}
+}
+
+type dbusConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	serial uint32
+}
+
+// dialSystemBus se conecta al bus del sistema y se autentica con EXTERNAL
+// (el UID del proceso).
+func dialSystemBus() (*dbusConn, error) {
+	path := defaultSystemBus
+	if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); strings.HasPrefix(addr, "unix:path=") {
+		path = strings.SplitN(strings.TrimPrefix(addr, "unix:path="), ",", 2)[0]
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	c := &dbusConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		conn.Close()
+		return nil, fmt.Errorf("autenticación D-Bus rechazada: %s", strings.TrimSpace(line))
+	}
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Hello: %v", err)
+	}
+	return c, nil
+}
+
+func (c *dbusConn) Close() error { return c.conn.Close() }
+
+// Call invoca un método con argumentos string y espera la respuesta. Las
+// señales que llegan mientras tanto se descartan, así que las llamadas se
+// hacen antes de empezar a leer señales.
+func (c *dbusConn) Call(dest, path, iface, member string, args ...string) (*dbusMessage, error) {
+	c.serial++
+	serial := c.serial
+	if _, err := c.conn.Write(encodeDBusCall(serial, dest, path, iface, member, args)); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := c.Read()
+		if err != nil {
+			return nil, err
+		}
+		if msg.ReplySerial != serial {
+			continue
+		}
+		if msg.Type == dbusError {
+			text := ""
+			if len(msg.Body) > 0 {
+				text, _ = msg.Body[0].(string)
+			}
+			return nil, fmt.Errorf("%s: %s", msg.ErrorName, text)
+		}
+		return msg, nil
+	}
+}
+
+// --- Codificación ---
+
+// dbusEncoder siempre escribe little-endian ('l' en el encabezado).
+type dbusEncoder struct {
+	buf []byte
+}
+
+func (e *dbusEncoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(append(e.buf, s...), 0)
+}
+
+func (e *dbusEncoder) signature(s string) {
+	e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+}
+
+// headerField escribe un campo del encabezado: struct (byte, variant).
+func (e *dbusEncoder) headerField(code byte, sig, value string) {
+	e.align(8)
+	e.buf = append(e.buf, code)
+	e.signature(sig)
+	if sig == "g" {
+		e.signature(value)
+	} else {
+		e.string(value)
+	}
+}
+
+func encodeDBusCall(serial uint32, dest, path, iface, member string, args []string) []byte {
+	body := &dbusEncoder{}
+	for _, a := range args {
+		body.string(a)
+	}
+
+	e := &dbusEncoder{}
+	e.buf = append(e.buf, 'l', dbusMethodCall, 0, 1)
+	e.uint32(uint32(len(body.buf)))
+	e.uint32(serial)
+	e.uint32(0) // Longitud del arreglo de campos, se completa después.
+	start := len(e.buf)
+	e.headerField(dbusFieldPath, "o", path)
+	e.headerField(dbusFieldDestination, "s", dest)
+	if iface != "" {
+		e.headerField(dbusFieldInterface, "s", iface)
+	}
+	e.headerField(dbusFieldMember, "s", member)
+	if len(args) > 0 {
+		e.headerField(dbusFieldSignature, "g", strings.Repeat("s", len(args)))
+	}
+	binary.LittleEndian.PutUint32(e.buf[12:], uint32(len(e.buf)-start))
+	e.align(8)
+	return append(e.buf, body.buf...)
+}
+
+// --- Decodificación ---
+
+type dbusDecoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (d *dbusDecoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (d *dbusDecoder) take(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// value decodifica un valor de un tipo completo y devuelve la firma que
+// queda.
+func (d *dbusDecoder) value(sig string) (interface{}, string, error) {
+	if sig == "" {
+		return nil, "", fmt.Errorf("firma D-Bus vacía")
+	}
+	switch sig[0] {
+	case 'y':
+		b, err := d.take(1)
+		if err != nil {
+			return nil, "", err
+		}
+		return b[0], sig[1:], nil
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, "", err
+		}
+		b, err := d.take(2)
+		if err != nil {
+			return nil, "", err
+		}
+		return d.order.Uint16(b), sig[1:], nil
+	case 'b', 'i', 'u', 'h':
+		if err := d.align(4); err != nil {
+			return nil, "", err
+		}
+		b, err := d.take(4)
+		if err != nil {
+			return nil, "", err
+		}
+		v := d.order.Uint32(b)
+		if sig[0] == 'b' {
+			return v != 0, sig[1:], nil
+		}
+		return v, sig[1:], nil
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, "", err
+		}
+		b, err := d.take(8)
+		if err != nil {
+			return nil, "", err
+		}
+		return d.order.Uint64(b), sig[1:], nil
+	case 's', 'o':
+		if err := d.align(4); err != nil {
+			return nil, "", err
+		}
+		b, err := d.take(4)
+		if err != nil {
+			return nil, "", err
+		}
+		s, err := d.take(int(d.order.Uint32(b)) + 1)
+		if err != nil {
+			return nil, "", err
+		}
+		return string(s[:len(s)-1]), sig[1:], nil
+	case 'g':
+		b, err := d.take(1)
+		if err != nil {
+			return nil, "", err
+		}
+		s, err := d.take(int(b[0]) + 1)
+		if err != nil {
+			return nil, "", err
+		}
+		return string(s[:len(s)-1]), sig[1:], nil
+	case 'v':
+		inner, _, err := d.value("g")
+		if err != nil {
+			return nil, "", err
+		}
+		v, _, err := d.value(inner.(string))
+		return v, sig[1:], err
+	case 'a':
+		return d.array(sig)
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		if err := d.align(8); err != nil {
+			return nil, "", err
+		}
+		rest := sig[1:]
+		var fields []interface{}
+		for rest != "" && rest[0] != closing {
+			v, r, err := d.value(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			fields = append(fields, v)
+			rest = r
+		}
+		if rest == "" {
+			return nil, "", fmt.Errorf("firma D-Bus inválida")
+		}
+		return fields, rest[1:], nil
+	}
+	return nil, "", fmt.Errorf("tipo D-Bus no soportado '%c'", sig[0])
+}
+
+// array decodifica "a…". Los diccionarios a{sv}, a{ss}, etc. se devuelven
+// como map[string]interface{}.
+func (d *dbusDecoder) array(sig string) (interface{}, string, error) {
+	if err := d.align(4); err != nil {
+		return nil, "", err
+	}
+	b, err := d.take(4)
+	if err != nil {
+		return nil, "", err
+	}
+	length := int(d.order.Uint32(b))
+
+	elem, rest, err := splitDBusType(sig[1:])
+	if err != nil {
+		return nil, "", err
+	}
+	if err := d.align(dbusAlignment(elem[0])); err != nil {
+		return nil, "", err
+	}
+	end := d.pos + length
+	if end > len(d.buf) {
+		return nil, "", io.ErrUnexpectedEOF
+	}
+
+	if elem[0] == '{' {
+		dict := make(map[string]interface{})
+		for d.pos < end {
+			v, _, err := d.value(elem)
+			if err != nil {
+				return nil, "", err
+			}
+			entry := v.([]interface{})
+			if len(entry) == 2 {
+				dict[fmt.Sprint(entry[0])] = entry[1]
+			}
+		}
+		return dict, rest, nil
+	}
+	var items []interface{}
+	for d.pos < end {
+		v, _, err := d.value(elem)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, v)
+	}
+	return items, rest, nil
+}
+
+// splitDBusType separa el primer tipo completo de una firma.
+func splitDBusType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", fmt.Errorf("firma D-Bus incompleta")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, rest, err := splitDBusType(sig[1:])
+		return "a" + elem, rest, err
+	case '(', '{':
+		depth := 0
+		for i := 0; i < len(sig); i++ {
+			switch sig[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+				if depth == 0 {
+					return sig[:i+1], sig[i+1:], nil
+				}
+			}
+		}
+		return "", "", fmt.Errorf("firma D-Bus inválida '%s'", sig)
+	}
+	return sig[:1], sig[1:], nil
+}
+
+func dbusAlignment(t byte) int {
+	switch t {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// Read lee el próximo mensaje del bus.
+func (c *dbusConn) Read() (*dbusMessage, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, fixed); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fixed[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLen := int(order.Uint32(fixed[4:]))
+	fieldsLen := int(order.Uint32(fixed[12:]))
+	headerLen := 16 + fieldsLen
+	if headerLen%8 != 0 {
+		headerLen += 8 - headerLen%8
+	}
+	if headerLen+bodyLen > dbusMaxMessage {
+		return nil, fmt.Errorf("mensaje D-Bus demasiado grande")
+	}
+
+	buf := make([]byte, headerLen+bodyLen)
+	copy(buf, fixed)
+	if _, err := io.ReadFull(c.reader, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	msg := &dbusMessage{Type: fixed[1], Serial: order.Uint32(fixed[8:])}
+	d := &dbusDecoder{buf: buf[:16+fieldsLen], pos: 12, order: order}
+	fields, _, err := d.value("a(yv)")
+	if err != nil {
+		return nil, fmt.Errorf("encabezado D-Bus: %v", err)
+	}
+	for _, f := range fields.([]interface{}) {
+		field := f.([]interface{})
+		code, _ := field[0].(byte)
+		switch code {
+		case dbusFieldPath:
+			msg.Path, _ = field[1].(string)
+		case dbusFieldInterface:
+			msg.Interface, _ = field[1].(string)
+		case dbusFieldMember:
+			msg.Member, _ = field[1].(string)
+		case dbusFieldErrorName:
+			msg.ErrorName, _ = field[1].(string)
+		case dbusFieldReplySerial:
+			msg.ReplySerial, _ = field[1].(uint32)
+		case dbusFieldSender:
+			msg.Sender, _ = field[1].(string)
+		case dbusFieldSignature:
+			msg.Signature, _ = field[1].(string)
+		}
+	}
+
+	if msg.Signature != "" {
+		// El cuerpo empieza alineado a 8, así que las posiciones relativas
+		// al cuerpo respetan la misma alineación.
+		d := &dbusDecoder{buf: buf[headerLen:], order: order}
+		rest := msg.Signature
+		for rest != "" {
+			var v interface{}
+			if v, rest, err = d.value(rest); err != nil {
+				return nil, fmt.Errorf("cuerpo D-Bus: %v", err)
+			}
+			msg.Body = append(msg.Body, v)
+		}
+	}
+	return msg, nil
+}
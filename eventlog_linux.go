@@ -0,0 +1,7 @@
+package main
+
+import "fmt"
+
+func newEventLogSink() (logSink, error) {
+	return nil, fmt.Errorf("el Visor de eventos solo existe en Windows")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log/slog"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogSource es el origen que registra install.exe en el registro de
+// Aplicación.
+const eventLogSource = "pirmon-client"
+
+// IDs de evento por componente, para poder filtrarlos en el Visor de
+// eventos. EventCreate admite del 1 al 1000.
+var eventLogIDs = map[string]uint32{
+	"report":   10,
+	"printer":  20,
+	"stats":    30,
+	"services": 40,
+}
+
+type eventLogSink struct {
+	log *eventlog.Log
+}
+
+func newEventLogSink() (logSink, error) {
+	l, err := eventlog.Open(eventLogSource)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogSink{log: l}, nil
+}
+
+func (s *eventLogSink) Write(rec logRecord) {
+	id, ok := eventLogIDs[rec.Component]
+	if !ok {
+		id = 1
+	}
+	text := rec.text()
+	switch {
+	case rec.Level >= slog.LevelError:
+		s.log.Error(id, text)
+	case rec.Level >= slog.LevelWarn:
+		s.log.Warning(id, text)
+	default:
+		s.log.Info(id, text)
+	}
+}
+
+func (s *eventLogSink) Close() {
+	s.log.Close()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	defaultLocale = "es"
+	// payloadLocale es el idioma del texto que acompaña a los códigos en los
+	// payloads. Es fijo para que el servidor reciba lo mismo sin importar el
+	// locale del agente.
+	payloadLocale = "es"
+)
+
+// Códigos de motivo que viajan en los payloads.
+const (
+	reasonServiceNotFound      = "service_not_found"
+	reasonServiceQueryFailed   = "service_query_failed"
+	reasonServiceStateMismatch = "service_state_mismatch"
+	reasonServiceStartFailed   = "service_start_failed"
+	reasonServiceAutoStarted   = "service_auto_started"
+
+	reasonCommandInvalidPayload      = "command_invalid_payload"
+	reasonCommandInvalidArgs         = "command_invalid_args"
+	reasonCommandsDisabled           = "commands_disabled"
+	reasonCommandSignatureInvalid    = "command_signature_invalid"
+	reasonCommandExpired             = "command_expired"
+	reasonCommandNonceReused         = "command_nonce_reused"
+	reasonCommandNotAllowed          = "command_not_allowed"
+	reasonCommandUnknown             = "command_unknown"
+	reasonCommandServiceNotMonitored = "command_service_not_monitored"
+	reasonCommandServiceStateTimeout = "command_service_state_timeout"
+	reasonCommandMissingArgs         = "command_missing_args"
+	reasonCommandFailed              = "command_failed"
+
+	reasonPrinterActionFailed      = "printer_action_failed"
+	reasonNetworkPrinterPollFailed = "network_printer_poll_failed"
+)
+
+// messageCatalog tiene las plantillas de los motivos, por código, y las
+// traducciones de los mensajes de log y consola, por su texto en español.
+type messageCatalog struct {
+	reasons  map[string]string
+	messages map[string]string
+}
+
+var catalogs = map[string]messageCatalog{
+	"es": {reasons: reasonsES},
+	"en": {reasons: reasonsEN, messages: messagesEN},
+}
+
+var currentLocale atomic.Value // string
+
+func locale() string {
+	if l, ok := currentLocale.Load().(string); ok {
+		return l
+	}
+	return defaultLocale
+}
+
+// configureLocale elige el catálogo para el log y la consola.
+func configureLocale(config Config) {
+	l := strings.ToLower(config.Locale)
+	if l == "" {
+		l = defaultLocale
+	}
+	if _, ok := catalogs[l]; !ok {
+		currentLocale.Store(defaultLocale)
+		slog.Warn("Locale sin catálogo, se usa el predeterminado", "locale", config.Locale, "used", defaultLocale)
+		return
+	}
+	currentLocale.Store(l)
+}
+
+// tr traduce un mensaje de log o consola. Los mensajes se escriben en
+// español en el código y ese texto es la clave del catálogo; si no hay
+// traducción se devuelve tal cual.
+func tr(msg string) string {
+	if t, ok := catalogs[locale()].messages[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Reason es un motivo legible por máquina. El servidor debería usar Code y
+// Params; el texto se arma con el catálogo.
+type Reason struct {
+	Code   string            `json:"code"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// newReason recibe los parámetros como pares clave, valor.
+func newReason(code string, params ...string) Reason {
+	r := Reason{Code: code}
+	if len(params) > 0 {
+		r.Params = make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			r.Params[params[i]] = params[i+1]
+		}
+	}
+	return r
+}
+
+// Error devuelve el texto en payloadLocale, para los campos error y message
+// que ya existían en los payloads.
+func (r Reason) Error() string {
+	return r.text(payloadLocale)
+}
+
+// Localized devuelve el texto en el locale configurado, para log y consola.
+func (r Reason) Localized() string {
+	return r.text(locale())
+}
+
+func (r Reason) text(l string) string {
+	tmpl, ok := catalogs[l].reasons[r.Code]
+	if !ok {
+		if tmpl, ok = catalogs[defaultLocale].reasons[r.Code]; !ok {
+			return r.Code
+		}
+	}
+	for k, v := range r.Params {
+		tmpl = strings.ReplaceAll(tmpl, "{"+k+"}", v)
+	}
+	return tmpl
+}
+
+// reasonOf devuelve el motivo de err; los errores que no son Reason se
+// envuelven con fallback.
+func reasonOf(err error, fallback string) Reason {
+	var r Reason
+	if errors.As(err, &r) {
+		return r
+	}
+	return newReason(fallback, "error", err.Error())
+}
+
+// joinReasons arma el texto de payload de varios motivos, separado por " | "
+// como el campo error de los servicios.
+func joinReasons(reasons []Reason) string {
+	texts := make([]string, len(reasons))
+	for i, r := range reasons {
+		texts[i] = r.Error()
+	}
+	return strings.Join(texts, " | ")
+}
+
+// localizeHandler traduce el mensaje de cada registro antes de pasarlo al
+// handler real. Los atributos no se tocan.
+type localizeHandler struct {
+	slog.Handler
+}
+
+func (h localizeHandler) Handle(ctx context.Context, r slog.Record) error {
+	if msg := tr(r.Message); msg != r.Message {
+		nr := slog.NewRecord(r.Time, r.Level, msg, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			nr.AddAttrs(a)
+			return true
+		})
+		r = nr
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h localizeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return localizeHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h localizeHandler) WithGroup(name string) slog.Handler {
+	return localizeHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// logMethods son los métodos de slog.Logger (y las funciones de slog) cuyo
+// primer argumento es el mensaje que traduce localizeHandler.
+var logMethods = map[string]bool{"Debug": true, "Info": true, "Warn": true, "Error": true}
+
+// catalogLiterals devuelve los mensajes literales que pasan por tr: los de
+// slog, los envueltos en tr() y los de logWarning, con su posición.
+func catalogLiterals(t *testing.T) map[string]string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	literals := make(map[string]string)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || file == "install.go" {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			var first ast.Expr
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if len(n.Args) == 0 {
+					return true
+				}
+				switch fun := n.Fun.(type) {
+				case *ast.Ident:
+					if fun.Name != "tr" {
+						return true
+					}
+				case *ast.SelectorExpr:
+					if !logMethods[fun.Sel.Name] {
+						return true
+					}
+				default:
+					return true
+				}
+				first = n.Args[0]
+			case *ast.CompositeLit:
+				if typ, ok := n.Type.(*ast.Ident); !ok || typ.Name != "logWarning" || len(n.Elts) == 0 {
+					return true
+				}
+				first = n.Elts[0]
+			default:
+				return true
+			}
+			lit, ok := first.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			msg, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			literals[msg] = fset.Position(lit.Pos()).String()
+			return true
+		})
+	}
+	return literals
+}
+
+// TestMessagesENCoverage falla si un mensaje de log o consola no tiene
+// traducción, o si el catálogo tiene claves que ya no usa el código: como la
+// clave es el texto en español, editar un mensaje pierde la traducción.
+func TestMessagesENCoverage(t *testing.T) {
+	literals := catalogLiterals(t)
+
+	var missing, unused []string
+	for msg, pos := range literals {
+		if _, ok := messagesEN[msg]; !ok {
+			missing = append(missing, pos+": "+strconv.Quote(msg))
+		}
+	}
+	for msg := range messagesEN {
+		if _, ok := literals[msg]; !ok {
+			unused = append(unused, strconv.Quote(msg))
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unused)
+	for _, m := range missing {
+		t.Errorf("sin traducción en messagesEN: %s", m)
+	}
+	for _, m := range unused {
+		t.Errorf("clave de messagesEN sin uso: %s", m)
+	}
+}
+
+func TestReasonCatalogs(t *testing.T) {
+	for code := range reasonsES {
+		if _, ok := reasonsEN[code]; !ok {
+			t.Errorf("motivo %s sin plantilla en inglés", code)
+		}
+	}
+	for code := range reasonsEN {
+		if _, ok := reasonsES[code]; !ok {
+			t.Errorf("motivo %s sin plantilla en español", code)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// agentVersion se reemplaza al compilar con -ldflags "-X main.agentVersion=...".
+var agentVersion = "dev"
+
+// agentID identifica a esta instalación del agente en todos los payloads. Se
+// inicializa con initAgentIdentity.
+var agentID string
+
+var identityOnce sync.Once
+
+type AgentRegistration struct {
+	AgentID      string    `json:"agent_id"`
+	Hostname     string    `json:"hostname"`
+	IP           string    `json:"ip"`
+	IPs          []string  `json:"ips"`
+	OS           string    `json:"os"`
+	Arch         string    `json:"arch"`
+	AgentVersion string    `json:"agent_version"`
+	StartedAt    time.Time `json:"started_at"`
+}
+
+// initAgentIdentity carga o genera el ID del agente y lo anuncia al servidor.
+// Solo tiene efecto la primera vez que se llama.
+func initAgentIdentity(config Config) {
+	identityOnce.Do(func() {
+		agentID = loadAgentID(config)
+		reportLog().Info("ID del agente", "agent_id", agentID)
+		safeGoRoutine("agent registration", func() {
+			registerAgent(config)
+		})
+	})
+}
+
+// loadAgentID lee el ID guardado en el directorio de datos o genera uno nuevo.
+// Con agent_id_source "machine" se deriva del MachineGuid de Windows (o de
+// /etc/machine-id), así reinstalar el agente conserva la identidad; con el
+// valor por defecto es aleatorio, lo que evita colisiones entre VMs clonadas.
+func loadAgentID(config Config) string {
+	path := config.dataPath("agent-id")
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id
+		}
+	}
+
+	var id string
+	if config.AgentIDSource == "machine" {
+		if machineID, err := readMachineID(); err == nil {
+			id = uuidV5(agentIDNamespace, machineID)
+		} else {
+			reportLog().Warn("No se pudo leer el ID de la máquina, se genera uno aleatorio", "error", err)
+		}
+	}
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = uuidFromBytes(b, 4)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		reportLog().Error("Error al crear directorio de datos", "error", err)
+	} else if err := os.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		reportLog().Error("Error al guardar ID del agente", "path", path, "error", err)
+	}
+	return id
+}
+
+// agentIDNamespace es el namespace de los IDs derivados del ID de la
+// máquina: el mismo equipo siempre da el mismo UUID.
+var agentIDNamespace = [16]byte{0x32, 0xa8, 0x26, 0x14, 0x11, 0xe5, 0x43, 0xfe, 0x82, 0x85, 0xe4, 0x8b, 0x9c, 0x6e, 0x9f, 0xd4}
+
+// uuidV5 arma un UUID v5 (RFC 4122, SHA-1 de namespace y nombre).
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	return uuidFromBytes(h.Sum(nil), 5)
+}
+
+// uuidFromBytes da formato de UUID (RFC 4122) de la versión indicada a los
+// primeros 16 bytes.
+func uuidFromBytes(b []byte, version byte) string {
+	u := make([]byte, 16)
+	copy(u, b)
+	u[6] = (u[6] & 0x0f) | version<<4
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// registerAgent anuncia el agente al servidor, reintentando hasta lograrlo.
+func registerAgent(config Config) {
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
+	reg := AgentRegistration{
+		AgentID:      agentID,
+		Hostname:     hostname,
+		IP:           ip,
+		IPs:          GetLocalIPs(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		AgentVersion: agentVersion,
+		StartedAt:    time.Now(),
+	}
+	payload, err := marshalReport(reg)
+	if err != nil {
+		reportLog().Error("Error al serializar registro del agente", "error", err)
+		return
+	}
+
+	url := fmt.Sprintf("%s/api/%s/agents/register", config.ServerURL, config.ServerVersion)
+	for attempt := 0; ; attempt++ {
+		err := postJSON(url, payload)
+		if err == nil {
+			reportLog().Info("Agente registrado en el servidor.")
+			return
+		}
+		delay := backoffDelay(attempt)
+		reportLog().Warn("Error al registrar el agente", "error", err, "retry_in", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// readMachineID devuelve el ID que genera systemd en la instalación.
+func readMachineID() (string, error) {
+	data, err := os.ReadFile("/etc/machine-id")
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", errors.New("/etc/machine-id vacío")
+	}
+	return id, nil
+}
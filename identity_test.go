@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUUIDV5(t *testing.T) {
+	dns := [16]byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	if got := uuidV5(dns, "www.example.com"); got != "2ed6657d-e927-568b-95e1-2665a8aea6a2" {
+		t.Errorf("uuidV5(DNS, www.example.com) = %s", got)
+	}
+	if got := uuidV5(agentIDNamespace, "4c4c4544-0042-3510-8050-b4c04f4e4b32"); got != "66ec26f6-1007-533e-b5d7-8a3e5bfe9e6a" {
+		t.Errorf("uuidV5(agentIDNamespace, ...) = %s", got)
+	}
+}
+
+func TestUUIDFromBytesRandom(t *testing.T) {
+	id := uuidFromBytes([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 4)
+	if id != "ffffffff-ffff-4fff-bfff-ffffffffffff" {
+		t.Errorf("uuidFromBytes = %s", id)
+	}
+	if parts := strings.Split(id, "-"); len(parts) != 5 {
+		t.Errorf("formato inválido %s", id)
+	}
+}
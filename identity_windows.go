@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// readMachineID devuelve el MachineGuid que genera la instalación de Windows.
+func readMachineID() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+	guid, _, err := k.GetStringValue("MachineGuid")
+	if err != nil {
+		return "", err
+	}
+	if guid == "" {
+		return "", errors.New("MachineGuid vacío")
+	}
+	return guid, nil
+}
@@ -1,3 +1,7 @@
+//go:build ignore
+
+// install.exe es un programa aparte con su propio main; se compila con
+// "go build install.go" y queda fuera de "go build ./...".
 package main
 
 import (
@@ -5,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"golang.org/x/sys/windows/svc/eventlog"
 	"golang.org/x/sys/windows/svc/mgr"
 )
 
@@ -37,5 +42,11 @@ func main() {
 	}
 	defer s.Close()
 
+	// Origen para los logs del agente en el registro de Aplicación. Si ya
+	// existe de una instalación anterior se deja como está.
+	if err := eventlog.InstallAsEventCreate("pirmon-client", eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		fmt.Println("Aviso: no se pudo registrar el origen del Visor de eventos:", err)
+	}
+
 	fmt.Println("Servicio instalado correctamente.")
 }
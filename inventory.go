@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+const defaultInventoryInterval = time.Hour
+
+type Inventory struct {
+	AgentID      string `json:"agent_id"`
+	Hostname     string `json:"hostname"`
+	AgentVersion string `json:"agent_version"`
+
+	OSName           string `json:"os_name"`
+	OSVersion        string `json:"os_version"`
+	OSBuild          string `json:"os_build,omitempty"`
+	OSDisplayVersion string `json:"os_display_version,omitempty"`
+	Kernel           string `json:"kernel"`
+	Architecture     string `json:"architecture"`
+
+	CPUModel        string          `json:"cpu_model"`
+	CPUCores        int             `json:"cpu_cores"`
+	CPULogicalCores int             `json:"cpu_logical_cores"`
+	MemoryTotal     uint64          `json:"memory_total"`
+	Disks           []InventoryDisk `json:"disks"`
+	NICs            []InventoryNIC  `json:"nics"`
+
+	Domain    string `json:"domain,omitempty"`
+	Workgroup string `json:"workgroup,omitempty"`
+	TimeZone  string `json:"time_zone"`
+}
+
+type InventoryDisk struct {
+	Device     string `json:"device"`
+	Mountpoint string `json:"mountpoint"`
+	Fstype     string `json:"fstype"`
+	Total      uint64 `json:"total"`
+}
+
+type InventoryNIC struct {
+	Name         string   `json:"name"`
+	HardwareAddr string   `json:"hardware_addr"`
+	MTU          int      `json:"mtu"`
+	Addresses    []string `json:"addresses"`
+}
+
+type InventoryReport struct {
+	Inventory
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"` // startup | changed
+}
+
+// gatherInventory arma el inventario con gopsutil y completa lo que depende
+// del sistema operativo con platformInventory.
+func gatherInventory() Inventory {
+	hostname, _ := os.Hostname()
+	inv := Inventory{
+		AgentID:      agentID,
+		Hostname:     hostname,
+		AgentVersion: agentVersion,
+		Architecture: runtime.GOARCH,
+	}
+
+	if info, err := host.Info(); err == nil {
+		inv.OSName = info.Platform
+		inv.OSVersion = info.PlatformVersion
+		inv.Kernel = info.KernelVersion
+		if info.KernelArch != "" {
+			inv.Architecture = info.KernelArch
+		}
+	} else {
+		reportLog().Warn("Error al obtener información del sistema", "error", err)
+	}
+
+	if infos, err := cpu.Info(); err == nil && len(infos) > 0 {
+		inv.CPUModel = infos[0].ModelName
+	}
+	inv.CPUCores, _ = cpu.Counts(false)
+	inv.CPULogicalCores, _ = cpu.Counts(true)
+
+	if vm, err := mem.VirtualMemory(); err == nil {
+		inv.MemoryTotal = vm.Total
+	}
+
+	if parts, err := disk.Partitions(false); err == nil {
+		for _, p := range parts {
+			d := InventoryDisk{Device: p.Device, Mountpoint: p.Mountpoint, Fstype: p.Fstype}
+			if usage, err := disk.Usage(p.Mountpoint); err == nil {
+				d.Total = usage.Total
+			}
+			inv.Disks = append(inv.Disks, d)
+		}
+	}
+
+	if ifaces, err := psnet.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			if iface.HardwareAddr == "" {
+				continue
+			}
+			nic := InventoryNIC{Name: iface.Name, HardwareAddr: iface.HardwareAddr, MTU: iface.MTU}
+			for _, addr := range iface.Addrs {
+				nic.Addresses = append(nic.Addresses, addr.Addr)
+			}
+			inv.NICs = append(inv.NICs, nic)
+		}
+	}
+
+	inv.TimeZone, _ = time.Now().Zone()
+	platformInventory(&inv)
+	return inv
+}
+
+func inventoryHash(inv Inventory) string {
+	data, _ := json.Marshal(inv)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// runInventoryReporter envía el inventario al iniciar y luego solo cuando
+// cambia. Si un envío falla, se reintenta en la siguiente revisión.
+func runInventoryReporter(config Config) {
+	interval := defaultInventoryInterval
+	if config.InventoryInterval > 0 {
+		interval = time.Duration(config.InventoryInterval) * time.Second
+	}
+	url := fmt.Sprintf("%s/api/%s/inventory", config.ServerURL, config.ServerVersion)
+
+	lastSent := ""
+	reason := "startup"
+	for {
+		inv := gatherInventory()
+		hash := inventoryHash(inv)
+		if hash != lastSent {
+			payload, err := marshalReport(InventoryReport{Inventory: inv, Timestamp: time.Now(), Reason: reason})
+			if err != nil {
+				reportLog().Error("Error al serializar inventario", "error", err)
+			} else if err := postJSON(url, payload); err != nil {
+				logSendError(reportLog(), "inventory", err, payload)
+			} else {
+				reportLog().Info("Inventario enviado", "reason", reason)
+				lastSent = hash
+				reason = "changed"
+			}
+		}
+		time.Sleep(interval)
+	}
+}
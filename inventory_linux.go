@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// platformInventory completa la versión comercial con PRETTY_NAME de
+// /etc/os-release y la zona horaria con el nombre IANA de /etc/localtime.
+func platformInventory(inv *Inventory) {
+	if f, err := os.Open("/etc/os-release"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+				inv.OSDisplayVersion = strings.Trim(value, `"'`)
+				break
+			}
+		}
+	}
+
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, zone, ok := strings.Cut(target, "/zoneinfo/"); ok {
+			inv.TimeZone = zone
+		}
+	}
+}
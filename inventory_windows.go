@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// platformInventory completa build, versión comercial, dominio o grupo de
+// trabajo y zona horaria con las APIs de Windows.
+func platformInventory(inv *Inventory) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err == nil {
+		defer k.Close()
+		build, _, _ := k.GetStringValue("CurrentBuildNumber")
+		if ubr, _, err := k.GetIntegerValue("UBR"); err == nil && build != "" {
+			build = fmt.Sprintf("%s.%d", build, ubr)
+		}
+		inv.OSBuild = build
+		inv.OSDisplayVersion, _, _ = k.GetStringValue("DisplayVersion")
+	}
+
+	var name *uint16
+	var bufType uint32
+	if err := windows.NetGetJoinInformation(nil, &name, &bufType); err == nil {
+		joined := windows.UTF16PtrToString(name)
+		windows.NetApiBufferFree((*byte)(unsafe.Pointer(name)))
+		switch bufType {
+		case windows.NetSetupDomainName:
+			inv.Domain = joined
+		case windows.NetSetupWorkgroupName:
+			inv.Workgroup = joined
+		}
+	}
+
+	var tzi windows.Timezoneinformation
+	if _, err := windows.GetTimeZoneInformation(&tzi); err == nil {
+		inv.TimeZone = windows.UTF16ToString(tzi.StandardName[:])
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const defaultIPRefreshInterval = 5 * time.Minute
+
+// ipCache guarda la IP principal del equipo y todas sus direcciones para no
+// recalcularlas en cada payload.
+type ipCache struct {
+	mu         sync.Mutex
+	serverAddr string
+	preferred  *net.IPNet
+	refresh    time.Duration
+	primary    string
+	all        []string
+	updated    time.Time
+}
+
+var localIPs = &ipCache{refresh: defaultIPRefreshInterval}
+
+// configureIPDiscovery indica hacia qué servidor se calcula la ruta y la
+// subred preferida para el respaldo por interfaces.
+func configureIPDiscovery(config Config) {
+	localIPs.mu.Lock()
+	defer localIPs.mu.Unlock()
+
+	localIPs.serverAddr = serverDialAddr(config.ServerURL)
+	localIPs.preferred = nil
+	if config.PreferredSubnet != "" {
+		_, subnet, err := net.ParseCIDR(config.PreferredSubnet)
+		if err != nil {
+			slog.Warn("preferred_subnet inválida", "subnet", config.PreferredSubnet, "error", err)
+		} else {
+			localIPs.preferred = subnet
+		}
+	}
+	localIPs.refresh = defaultIPRefreshInterval
+	if config.IPRefreshInterval > 0 {
+		localIPs.refresh = time.Duration(config.IPRefreshInterval) * time.Second
+	}
+	localIPs.updated = time.Time{}
+}
+
+// GetOutboundIP devuelve la IP local usada para llegar al servidor, o la
+// mejor candidata entre las interfaces si no hay ruta.
+func GetOutboundIP() (string, error) {
+	localIPs.mu.Lock()
+	defer localIPs.mu.Unlock()
+	localIPs.refreshIfStale()
+	if localIPs.primary == "" {
+		return "", fmt.Errorf("no se encontró una dirección IP local")
+	}
+	return localIPs.primary, nil
+}
+
+// GetLocalIPs devuelve todas las direcciones IPv4 e IPv6 de las interfaces
+// activas, sin loopback ni link-local.
+func GetLocalIPs() []string {
+	localIPs.mu.Lock()
+	defer localIPs.mu.Unlock()
+	localIPs.refreshIfStale()
+	return append([]string(nil), localIPs.all...)
+}
+
+func (c *ipCache) refreshIfStale() {
+	if !c.updated.IsZero() && time.Since(c.updated) < c.refresh {
+		return
+	}
+	c.updated = time.Now()
+
+	addrs := interfaceAddrs()
+	c.all = c.all[:0]
+	for _, a := range addrs {
+		c.all = append(c.all, a.IP.String())
+	}
+
+	if ip := routeIP(c.serverAddr); ip != "" {
+		c.primary = ip
+		return
+	}
+	c.primary = pickIP(addrs, c.preferred)
+}
+
+// routeIP obtiene la IP de origen que el sistema usaría para llegar a addr.
+// Un "dial" UDP no envía paquetes, solo resuelve la ruta.
+func routeIP(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// pickIP elige una dirección de la subred preferida si hay, si no la primera
+// IPv4 y por último la primera IPv6.
+func pickIP(addrs []*net.IPNet, preferred *net.IPNet) string {
+	if preferred != nil {
+		for _, a := range addrs {
+			if preferred.Contains(a.IP) {
+				return a.IP.String()
+			}
+		}
+	}
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return a.IP.String()
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0].IP.String()
+	}
+	return ""
+}
+
+func interfaceAddrs() []*net.IPNet {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		slog.Error("Error al enumerar interfaces", "error", err)
+		return nil
+	}
+
+	var result []*net.IPNet
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			result = append(result, ipNet)
+		}
+	}
+	return result
+}
+
+// serverDialAddr convierte server_url en host:puerto para calcular la ruta.
+func serverDialAddr(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultIPPURL = "http://localhost:631"
+
+type IPPConfig struct {
+	URL  string `yaml:"url"`  // Por defecto http://localhost:631
+	User string `yaml:"user"` // requesting-user-name
+}
+
+// Operaciones IPP (RFC 8011) y de CUPS.
+const (
+	ippCancelJob          = 0x0008
+	ippGetJobAttributes   = 0x0009
+	ippGetJobs            = 0x000A
+	ippGetPrinterAttrs    = 0x000B
+	ippHoldJob            = 0x000C
+	ippReleaseJob         = 0x000D
+	ippRestartJob         = 0x000E
+	ippCupsGetPrinters    = 0x4002
+	ippStatusErrorMinimum = 0x0400
+)
+
+// Etiquetas de grupo y de valor.
+const (
+	ippTagOperation       = 0x01
+	ippTagJob             = 0x02
+	ippTagEnd             = 0x03
+	ippTagPrinter         = 0x04
+	ippTagInteger         = 0x21
+	ippTagBoolean         = 0x22
+	ippTagEnum            = 0x23
+	ippTagBegCollection   = 0x34
+	ippTagEndCollection   = 0x37
+	ippTagName            = 0x42
+	ippTagKeyword         = 0x44
+	ippTagURI             = 0x45
+	ippTagCharset         = 0x47
+	ippTagNaturalLanguage = 0x48
+	ippMaxDelimiterTag    = 0x0F
+)
+
+const (
+	ippContentType         = "application/ipp"
+	ippDefaultUser         = "pirmon"
+	ippRequestTimeout      = 15 * time.Second
+	ippJobStateCanceled    = 7
+	ippJobStateAborted     = 8
+	ippJobStateCompleted   = 9
+	ippPrinterStateStopped = 5
+)
+
+// ippAttr es un atributo IPP con sus valores. Los enteros, enums y booleanos
+// se guardan como int32; el resto como string.
+type ippAttr struct {
+	tag    byte
+	name   string
+	values []interface{}
+}
+
+type ippRequest struct {
+	operation uint16
+	attrs     []ippAttr
+}
+
+func (r *ippRequest) add(tag byte, name string, values ...interface{}) {
+	r.attrs = append(r.attrs, ippAttr{tag: tag, name: name, values: values})
+}
+
+func (r *ippRequest) encode(requestID uint32) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{1, 1})
+	binary.Write(&buf, binary.BigEndian, r.operation)
+	binary.Write(&buf, binary.BigEndian, requestID)
+	buf.WriteByte(ippTagOperation)
+	for _, a := range r.attrs {
+		for i, v := range a.values {
+			buf.WriteByte(a.tag)
+			name := a.name
+			if i > 0 {
+				name = ""
+			}
+			binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+			buf.WriteString(name)
+			switch v := v.(type) {
+			case int32:
+				binary.Write(&buf, binary.BigEndian, uint16(4))
+				binary.Write(&buf, binary.BigEndian, v)
+			case bool:
+				binary.Write(&buf, binary.BigEndian, uint16(1))
+				if v {
+					buf.WriteByte(1)
+				} else {
+					buf.WriteByte(0)
+				}
+			case string:
+				binary.Write(&buf, binary.BigEndian, uint16(len(v)))
+				buf.WriteString(v)
+			}
+		}
+	}
+	buf.WriteByte(ippTagEnd)
+	return buf.Bytes()
+}
+
+// ippGroup es un grupo de atributos de la respuesta (un job o una impresora).
+type ippGroup struct {
+	tag   byte
+	attrs map[string]*ippAttr
+}
+
+func (g ippGroup) str(name string) string {
+	if a, ok := g.attrs[name]; ok && len(a.values) > 0 {
+		if s, ok := a.values[0].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func (g ippGroup) strs(name string) []string {
+	var out []string
+	if a, ok := g.attrs[name]; ok {
+		for _, v := range a.values {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func (g ippGroup) int(name string) int32 {
+	if a, ok := g.attrs[name]; ok && len(a.values) > 0 {
+		if n, ok := a.values[0].(int32); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+type ippResponse struct {
+	status uint16
+	groups []ippGroup
+}
+
+func (r ippResponse) groupsWithTag(tag byte) []ippGroup {
+	var out []ippGroup
+	for _, g := range r.groups {
+		if g.tag == tag {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// decodeIPPResponse interpreta una respuesta IPP. Las colecciones se saltean
+// porque el agente no pide atributos de ese tipo.
+func decodeIPPResponse(data []byte) (ippResponse, error) {
+	var resp ippResponse
+	if len(data) < 8 {
+		return resp, fmt.Errorf("respuesta IPP demasiado corta")
+	}
+	resp.status = binary.BigEndian.Uint16(data[2:4])
+	pos := 8
+
+	var group *ippGroup
+	var last *ippAttr
+	depth := 0
+	for pos < len(data) {
+		tag := data[pos]
+		pos++
+		if tag == ippTagEnd {
+			break
+		}
+		if tag <= ippMaxDelimiterTag {
+			resp.groups = append(resp.groups, ippGroup{tag: tag, attrs: make(map[string]*ippAttr)})
+			group = &resp.groups[len(resp.groups)-1]
+			last = nil
+			continue
+		}
+		if pos+2 > len(data) {
+			return resp, fmt.Errorf("respuesta IPP truncada")
+		}
+		nameLen := int(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+		if pos+nameLen+2 > len(data) {
+			return resp, fmt.Errorf("respuesta IPP truncada")
+		}
+		name := string(data[pos : pos+nameLen])
+		pos += nameLen
+		valueLen := int(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+		if pos+valueLen > len(data) {
+			return resp, fmt.Errorf("respuesta IPP truncada")
+		}
+		raw := data[pos : pos+valueLen]
+		pos += valueLen
+
+		switch {
+		case tag == ippTagBegCollection:
+			depth++
+			continue
+		case tag == ippTagEndCollection:
+			depth--
+			continue
+		case depth > 0 || group == nil:
+			continue
+		}
+
+		var value interface{}
+		switch tag {
+		case ippTagInteger, ippTagEnum:
+			if len(raw) == 4 {
+				value = int32(binary.BigEndian.Uint32(raw))
+			}
+		case ippTagBoolean:
+			if len(raw) == 1 {
+				value = int32(raw[0])
+			}
+		default:
+			value = string(raw)
+		}
+
+		if nameLen == 0 && last != nil {
+			last.values = append(last.values, value)
+			continue
+		}
+		last = &ippAttr{tag: tag, name: name, values: []interface{}{value}}
+		group.attrs[name] = last
+	}
+	return resp, nil
+}
+
+// ippBackend consulta un cupsd por IPP sobre HTTP.
+type ippBackend struct {
+	baseURL string
+	user    string
+	client  *http.Client
+
+	mu        sync.Mutex
+	requestID uint32
+	// pending guarda los trabajos no terminados del último sondeo, para
+	// averiguar cómo terminaron los que ya no aparecen.
+	pending map[string]map[uint32]bool
+}
+
+func newIPPBackend(config IPPConfig) *ippBackend {
+	base := strings.TrimRight(config.URL, "/")
+	if base == "" {
+		base = defaultIPPURL
+	}
+	user := config.User
+	if user == "" {
+		user = ippDefaultUser
+	}
+	return &ippBackend{
+		baseURL: base,
+		user:    user,
+		client:  &http.Client{Timeout: ippRequestTimeout},
+		pending: make(map[string]map[uint32]bool),
+	}
+}
+
+func (b *ippBackend) Name() string { return "ipp" }
+
+// EnsureRunning no hace nada: cupsd lo administra el sistema.
+func (b *ippBackend) EnsureRunning() error { return nil }
+
+func (b *ippBackend) RestartService() error {
+	return fmt.Errorf("el backend ipp no puede reiniciar cupsd")
+}
+
+// printerURI arma la URI ipp:// que CUPS espera en printer-uri.
+func (b *ippBackend) printerURI(printerName string) string {
+	uri := b.baseURL
+	switch {
+	case strings.HasPrefix(uri, "https://"):
+		uri = "ipps://" + strings.TrimPrefix(uri, "https://")
+	case strings.HasPrefix(uri, "http://"):
+		uri = "ipp://" + strings.TrimPrefix(uri, "http://")
+	}
+	return uri + "/printers/" + url.PathEscape(printerName)
+}
+
+func (b *ippBackend) newRequest(operation uint16) *ippRequest {
+	req := &ippRequest{operation: operation}
+	req.add(ippTagCharset, "attributes-charset", "utf-8")
+	req.add(ippTagNaturalLanguage, "attributes-natural-language", "en")
+	return req
+}
+
+func (b *ippBackend) do(path string, req *ippRequest) (ippResponse, error) {
+	b.mu.Lock()
+	b.requestID++
+	id := b.requestID
+	b.mu.Unlock()
+
+	httpResp, err := b.client.Post(b.baseURL+path, ippContentType, bytes.NewReader(req.encode(id)))
+	if err != nil {
+		return ippResponse{}, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return ippResponse{}, fmt.Errorf("IPP %s: HTTP %d", path, httpResp.StatusCode)
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return ippResponse{}, err
+	}
+	resp, err := decodeIPPResponse(data)
+	if err != nil {
+		return resp, err
+	}
+	if resp.status >= ippStatusErrorMinimum {
+		return resp, fmt.Errorf("IPP 0x%04X en %s: estado 0x%04X", req.operation, path, resp.status)
+	}
+	return resp, nil
+}
+
+func (b *ippBackend) ListPrinters() ([]string, error) {
+	req := b.newRequest(ippCupsGetPrinters)
+	req.add(ippTagKeyword, "requested-attributes", "printer-name")
+	resp, err := b.do("/", req)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, g := range resp.groupsWithTag(ippTagPrinter) {
+		if name := g.str("printer-name"); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ippPrinterReasons traduce printer-state-reasons a PRINTER_STATUS_*.
+var ippPrinterReasons = map[string]uint32{
+	"paused":              0x00000001,
+	"other":               0x00000002,
+	"media-jam":           0x00000008,
+	"media-empty":         0x00000010,
+	"media-needed":        0x00000010,
+	"offline":             0x00000080,
+	"shutdown":            0x00000080,
+	"output-area-full":    0x00000800,
+	"toner-low":           0x00020000,
+	"marker-supply-low":   0x00020000,
+	"toner-empty":         0x00040000,
+	"marker-supply-empty": 0x00040000,
+	"door-open":           0x00400000,
+	"cover-open":          0x00400000,
+	"interlock-open":      0x00400000,
+}
+
+// ippReasonStatus combina los reasons en una máscara. Los sufijos -report,
+// -warning y -error se quitan; cualquier reason con -error suma el bit error.
+func ippReasonStatus(reasons []string, table map[string]uint32) uint32 {
+	var status uint32
+	for _, r := range reasons {
+		if r == "none" {
+			continue
+		}
+		base := r
+		for _, suffix := range []string{"-report", "-warning", "-error"} {
+			if strings.HasSuffix(base, suffix) {
+				base = strings.TrimSuffix(base, suffix)
+				if suffix == "-error" {
+					status |= 0x00000002
+				}
+				break
+			}
+		}
+		status |= table[base]
+	}
+	return status
+}
+
+func (b *ippBackend) PrinterInfo(printerName string) (PrinterInfo, error) {
+	info := PrinterInfo{Name: printerName}
+	req := b.newRequest(ippGetPrinterAttrs)
+	req.add(ippTagURI, "printer-uri", b.printerURI(printerName))
+	req.add(ippTagName, "requesting-user-name", b.user)
+	req.add(ippTagKeyword, "requested-attributes",
+		"printer-state", "printer-state-reasons", "device-uri", "printer-make-and-model",
+		"queued-job-count", "printer-is-shared")
+	resp, err := b.do("/printers/"+url.PathEscape(printerName), req)
+	if err != nil {
+		return info, err
+	}
+	groups := resp.groupsWithTag(ippTagPrinter)
+	if len(groups) == 0 {
+		return info, fmt.Errorf("IPP no devolvió atributos para '%s'", printerName)
+	}
+	g := groups[0]
+
+	info.Port = g.str("device-uri")
+	info.Driver = g.str("printer-make-and-model")
+	info.QueuedJobs = uint32(g.int("queued-job-count"))
+	info.Status = ippReasonStatus(g.strs("printer-state-reasons"), ippPrinterReasons)
+	switch g.int("printer-state") {
+	case 4:
+		info.Status |= 0x00000400 // printing
+	case ippPrinterStateStopped:
+		info.Status |= 0x00000001 // paused
+	}
+	info.Attributes = 0x00000001 // queued
+	if g.int("printer-is-shared") != 0 {
+		info.Attributes |= 0x00000008
+	}
+	return info, nil
+}
+
+var ippJobAttributes = []interface{}{
+	"job-id", "job-name", "job-originating-user-name", "job-state", "job-state-reasons",
+	"job-state-message", "job-printer-state-message", "job-impressions",
+	"job-impressions-completed", "time-at-creation", "print-color-mode", "sides",
+}
+
+// ippJobStatus traduce job-state a JOB_STATUS_*.
+func ippJobStatus(state int32, reasons []string) uint32 {
+	var status uint32
+	switch state {
+	case 4: // pending-held
+		status = 0x00000001
+	case 5: // processing
+		status = 0x00000010
+	case 6: // processing-stopped
+		status = 0x00000200
+	case ippJobStateCanceled:
+		status = 0x00000100
+	case ippJobStateAborted:
+		status = 0x00000002 | 0x00000100
+	case ippJobStateCompleted:
+		status = 0x00000080 | 0x00001000
+	}
+	for _, r := range reasons {
+		switch r {
+		case "printer-stopped", "printer-stopped-partly":
+			status |= 0x00000020
+		case "job-hold-until-specified", "job-held-by-user":
+			status |= 0x00000001
+		}
+	}
+	return status
+}
+
+func ippJobFromGroup(printerName string, g ippGroup) PrintJob {
+	job := PrintJob{
+		PrinterName:  printerName,
+		JobID:        uint32(g.int("job-id")),
+		Document:     g.str("job-name"),
+		User:         g.str("job-originating-user-name"),
+		Status:       ippJobStatus(g.int("job-state"), g.strs("job-state-reasons")),
+		StatusText:   g.str("job-state-message"),
+		TotalPages:   uint32(g.int("job-impressions")),
+		PagesPrinted: uint32(g.int("job-impressions-completed")),
+	}
+	if job.StatusText == "" {
+		job.StatusText = g.str("job-printer-state-message")
+	}
+	if t := g.int("time-at-creation"); t > 0 {
+		job.Submitted = time.Unix(int64(t), 0).UTC()
+	}
+	switch g.str("print-color-mode") {
+	case "color":
+		job.Color = "color"
+	case "monochrome":
+		job.Color = "mono"
+	}
+	switch g.str("sides") {
+	case "one-sided":
+		job.Duplex = "simplex"
+	case "two-sided-long-edge":
+		job.Duplex = "long_edge"
+	case "two-sided-short-edge":
+		job.Duplex = "short_edge"
+	}
+	return job
+}
+
+// Jobs devuelve los trabajos pendientes. Los que desaparecieron desde el
+// sondeo anterior se incluyen una vez más con su estado final, así los
+// trackers pueden distinguir un trabajo impreso de uno cancelado.
+func (b *ippBackend) Jobs(printerName string) ([]PrintJob, error) {
+	req := b.newRequest(ippGetJobs)
+	req.add(ippTagURI, "printer-uri", b.printerURI(printerName))
+	req.add(ippTagName, "requesting-user-name", b.user)
+	req.add(ippTagKeyword, "which-jobs", "not-completed")
+	req.add(ippTagKeyword, "requested-attributes", ippJobAttributes...)
+	resp, err := b.do("/printers/"+url.PathEscape(printerName), req)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []PrintJob
+	current := make(map[uint32]bool)
+	for i, g := range resp.groupsWithTag(ippTagJob) {
+		job := ippJobFromGroup(printerName, g)
+		job.Position = uint32(i + 1)
+		jobs = append(jobs, job)
+		current[job.JobID] = true
+	}
+
+	b.mu.Lock()
+	prev := b.pending[printerName]
+	b.pending[printerName] = current
+	b.mu.Unlock()
+
+	for id := range prev {
+		if current[id] {
+			continue
+		}
+		job, err := b.jobAttributes(printerName, id)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (b *ippBackend) jobAttributes(printerName string, jobID uint32) (PrintJob, error) {
+	req := b.newRequest(ippGetJobAttributes)
+	req.add(ippTagURI, "printer-uri", b.printerURI(printerName))
+	req.add(ippTagInteger, "job-id", int32(jobID))
+	req.add(ippTagName, "requesting-user-name", b.user)
+	req.add(ippTagKeyword, "requested-attributes", ippJobAttributes...)
+	resp, err := b.do("/printers/"+url.PathEscape(printerName), req)
+	if err != nil {
+		return PrintJob{}, err
+	}
+	groups := resp.groupsWithTag(ippTagJob)
+	if len(groups) == 0 {
+		return PrintJob{}, fmt.Errorf("IPP no devolvió el trabajo %d", jobID)
+	}
+	return ippJobFromGroup(printerName, groups[0]), nil
+}
+
+func (b *ippBackend) ControlJob(printerName string, jobID uint32, command uint32) error {
+	var operation uint16
+	switch command {
+	case jobControlPause:
+		operation = ippHoldJob
+	case jobControlResume:
+		operation = ippReleaseJob
+	case jobControlCancel, jobControlDelete:
+		operation = ippCancelJob
+	case jobControlRestart:
+		operation = ippRestartJob
+	default:
+		return fmt.Errorf("comando de trabajo %d no soportado por ipp", command)
+	}
+	req := b.newRequest(operation)
+	req.add(ippTagURI, "printer-uri", b.printerURI(printerName))
+	req.add(ippTagInteger, "job-id", int32(jobID))
+	req.add(ippTagName, "requesting-user-name", b.user)
+	_, err := b.do("/jobs", req)
+	return err
+}
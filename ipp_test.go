@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ippTestAttr es un atributo de la respuesta del servidor de prueba. Los
+// valores int32 se codifican como integer o enum según tag.
+type ippTestAttr struct {
+	tag    byte
+	name   string
+	values []interface{}
+}
+
+type ippTestGroup struct {
+	tag   byte
+	attrs []ippTestAttr
+}
+
+func encodeIPPTestResponse(status uint16, requestID uint32, groups ...ippTestGroup) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{1, 1})
+	binary.Write(&buf, binary.BigEndian, status)
+	binary.Write(&buf, binary.BigEndian, requestID)
+	groups = append([]ippTestGroup{{tag: ippTagOperation, attrs: []ippTestAttr{
+		{ippTagCharset, "attributes-charset", []interface{}{"utf-8"}},
+		{ippTagNaturalLanguage, "attributes-natural-language", []interface{}{"en"}},
+	}}}, groups...)
+	for _, g := range groups {
+		buf.WriteByte(g.tag)
+		for _, a := range g.attrs {
+			for i, v := range a.values {
+				buf.WriteByte(a.tag)
+				name := a.name
+				if i > 0 {
+					name = ""
+				}
+				binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+				buf.WriteString(name)
+				switch v := v.(type) {
+				case int32:
+					binary.Write(&buf, binary.BigEndian, uint16(4))
+					binary.Write(&buf, binary.BigEndian, v)
+				case bool:
+					binary.Write(&buf, binary.BigEndian, uint16(1))
+					if v {
+						buf.WriteByte(1)
+					} else {
+						buf.WriteByte(0)
+					}
+				case string:
+					binary.Write(&buf, binary.BigEndian, uint16(len(v)))
+					buf.WriteString(v)
+				}
+			}
+		}
+	}
+	buf.WriteByte(ippTagEnd)
+	return buf.Bytes()
+}
+
+// fakeCUPS responde Get-Printer-Attributes, Get-Jobs, Get-Job-Attributes y
+// Cancel-Job con el estado que arma cada test.
+type fakeCUPS struct {
+	mu       sync.Mutex
+	printer  []ippTestAttr
+	jobs     map[int32][]ippTestAttr
+	active   []int32 // Trabajos que devuelve Get-Jobs, en orden
+	requests []ippGroup
+	paths    []string
+	ops      []uint16
+}
+
+func (f *fakeCUPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != ippContentType {
+		http.Error(w, "content-type", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	// La petición tiene el mismo formato que la respuesta, con la
+	// operación en el lugar del estado.
+	req, err := decodeIPPResponse(body)
+	if err != nil || len(req.groups) == 0 {
+		http.Error(w, "ipp", http.StatusBadRequest)
+		return
+	}
+	op := req.status
+	id := binary.BigEndian.Uint32(body[4:8])
+	attrs := req.groups[0]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, attrs)
+	f.paths = append(f.paths, r.URL.EscapedPath())
+	f.ops = append(f.ops, op)
+
+	var groups []ippTestGroup
+	status := uint16(0)
+	switch op {
+	case ippGetPrinterAttrs:
+		groups = append(groups, ippTestGroup{tag: ippTagPrinter, attrs: f.printer})
+	case ippGetJobs:
+		for _, jobID := range f.active {
+			groups = append(groups, ippTestGroup{tag: ippTagJob, attrs: f.jobs[jobID]})
+		}
+	case ippGetJobAttributes:
+		job, ok := f.jobs[attrs.int("job-id")]
+		if !ok {
+			status = 0x0406 // client-error-not-found
+			break
+		}
+		groups = append(groups, ippTestGroup{tag: ippTagJob, attrs: job})
+	case ippCancelJob:
+	default:
+		status = 0x0501 // server-error-operation-not-supported
+	}
+	w.Header().Set("Content-Type", ippContentType)
+	w.Write(encodeIPPTestResponse(status, id, groups...))
+}
+
+func testIPPJob(id int32, name string, state int32, extra ...ippTestAttr) []ippTestAttr {
+	return append([]ippTestAttr{
+		{ippTagInteger, "job-id", []interface{}{id}},
+		{ippTagName, "job-name", []interface{}{name}},
+		{ippTagName, "job-originating-user-name", []interface{}{"ana"}},
+		{ippTagEnum, "job-state", []interface{}{state}},
+		{ippTagInteger, "time-at-creation", []interface{}{int32(1700000000)}},
+	}, extra...)
+}
+
+func newTestIPPBackend(t *testing.T, cups *fakeCUPS) *ippBackend {
+	t.Helper()
+	srv := httptest.NewServer(cups)
+	t.Cleanup(srv.Close)
+	return newIPPBackend(IPPConfig{URL: srv.URL + "/", User: "tester"})
+}
+
+func TestIPPPrinterInfo(t *testing.T) {
+	cups := &fakeCUPS{printer: []ippTestAttr{
+		{ippTagEnum, "printer-state", []interface{}{int32(4)}},
+		{ippTagKeyword, "printer-state-reasons", []interface{}{"media-empty-error", "toner-low-report"}},
+		// Las colecciones se saltean sin perder los atributos siguientes.
+		{ippTagBegCollection, "media-col-default", []interface{}{""}},
+		{ippTagKeyword, "", []interface{}{"ignorado"}},
+		{ippTagEndCollection, "", []interface{}{""}},
+		{ippTagURI, "device-uri", []interface{}{"socket://10.0.0.5"}},
+		{ippTagName, "printer-make-and-model", []interface{}{"HP LaserJet"}},
+		{ippTagInteger, "queued-job-count", []interface{}{int32(2)}},
+		{ippTagBoolean, "printer-is-shared", []interface{}{true}},
+	}}
+	b := newTestIPPBackend(t, cups)
+
+	info, err := b.PrinterInfo("HP Laser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PrinterInfo{
+		Name:       "HP Laser",
+		Port:       "socket://10.0.0.5",
+		Driver:     "HP LaserJet",
+		QueuedJobs: 2,
+		// media-empty, error (por el sufijo -error), toner-low y printing.
+		Status:     0x00000010 | 0x00000002 | 0x00020000 | 0x00000400,
+		Attributes: 0x00000001 | 0x00000008,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("PrinterInfo:\n got %+v\nwant %+v", info, want)
+	}
+
+	if cups.paths[0] != "/printers/HP%20Laser" {
+		t.Errorf("path %q", cups.paths[0])
+	}
+	req := cups.requests[0]
+	if uri := req.str("printer-uri"); uri != "ipp://"+b.baseURL[len("http://"):]+"/printers/HP%20Laser" {
+		t.Errorf("printer-uri %q", uri)
+	}
+	if user := req.str("requesting-user-name"); user != "tester" {
+		t.Errorf("requesting-user-name %q", user)
+	}
+	if got := req.strs("requested-attributes"); len(got) != 6 {
+		t.Errorf("requested-attributes %v", got)
+	}
+}
+
+func TestIPPPrinterInfoStopped(t *testing.T) {
+	cups := &fakeCUPS{printer: []ippTestAttr{
+		{ippTagEnum, "printer-state", []interface{}{int32(ippPrinterStateStopped)}},
+		{ippTagKeyword, "printer-state-reasons", []interface{}{"none"}},
+	}}
+	info, err := newTestIPPBackend(t, cups).PrinterInfo("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != 0x00000001 || info.Attributes != 0x00000001 {
+		t.Fatalf("status 0x%X attributes 0x%X", info.Status, info.Attributes)
+	}
+}
+
+func TestIPPJobs(t *testing.T) {
+	cups := &fakeCUPS{jobs: map[int32][]ippTestAttr{
+		1: testIPPJob(1, "informe.pdf", 5,
+			ippTestAttr{ippTagInteger, "job-impressions", []interface{}{int32(3)}},
+			ippTestAttr{ippTagInteger, "job-impressions-completed", []interface{}{int32(1)}},
+			ippTestAttr{ippTagKeyword, "print-color-mode", []interface{}{"color"}},
+			ippTestAttr{ippTagKeyword, "sides", []interface{}{"two-sided-long-edge"}},
+			ippTestAttr{ippTagKeyword, "job-state-reasons", []interface{}{"job-printing", "printer-stopped-partly"}}),
+		2: testIPPJob(2, "foto.jpg", 4,
+			ippTestAttr{ippTagKeyword, "print-color-mode", []interface{}{"monochrome"}},
+			ippTestAttr{ippTagKeyword, "sides", []interface{}{"one-sided"}},
+			ippTestAttr{ippTagName, "job-printer-state-message", []interface{}{"esperando"}}),
+	}, active: []int32{1, 2}}
+	b := newTestIPPBackend(t, cups)
+
+	jobs, err := b.Jobs("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PrintJob{
+		{
+			PrinterName: "p", JobID: 1, Document: "informe.pdf", User: "ana",
+			Status:     0x00000010 | 0x00000020, // printing, paused por printer-stopped-partly
+			Position:   1,
+			TotalPages: 3, PagesPrinted: 1,
+			Color: "color", Duplex: "long_edge",
+			Submitted: time.Unix(1700000000, 0).UTC(),
+		},
+		{
+			PrinterName: "p", JobID: 2, Document: "foto.jpg", User: "ana",
+			Status:     0x00000001, // paused (pending-held)
+			StatusText: "esperando",
+			Position:   2,
+			Color:      "mono", Duplex: "simplex",
+			Submitted: time.Unix(1700000000, 0).UTC(),
+		},
+	}
+	if !reflect.DeepEqual(jobs, want) {
+		t.Fatalf("Jobs:\n got %+v\nwant %+v", jobs, want)
+	}
+	if which := cups.requests[0].str("which-jobs"); which != "not-completed" {
+		t.Errorf("which-jobs %q", which)
+	}
+}
+
+// TestIPPJobTransitions verifica que los trabajos que dejan de aparecer en
+// Get-Jobs se informen una vez más con su estado final.
+func TestIPPJobTransitions(t *testing.T) {
+	cups := &fakeCUPS{jobs: map[int32][]ippTestAttr{
+		1: testIPPJob(1, "a", 5),
+		2: testIPPJob(2, "b", 3),
+		3: testIPPJob(3, "c", 3),
+	}, active: []int32{1, 2, 3}}
+	b := newTestIPPBackend(t, cups)
+
+	if _, err := b.Jobs("p"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 se imprimió, 2 se canceló, 3 se abortó.
+	cups.mu.Lock()
+	cups.jobs[1] = testIPPJob(1, "a", ippJobStateCompleted)
+	cups.jobs[2] = testIPPJob(2, "b", ippJobStateCanceled)
+	cups.jobs[3] = testIPPJob(3, "c", ippJobStateAborted)
+	cups.active = nil
+	cups.mu.Unlock()
+
+	jobs, err := b.Jobs("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[uint32]uint32)
+	for _, j := range jobs {
+		got[j.JobID] = j.Status
+	}
+	want := map[uint32]uint32{
+		1: 0x00000080 | 0x00001000, // printed, complete
+		2: 0x00000100,              // deleted
+		3: 0x00000002 | 0x00000100, // error, deleted
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("estados finales %v, se esperaba %v", got, want)
+	}
+
+	// Ya informados, no vuelven a aparecer.
+	jobs, err = b.Jobs("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("el tercer sondeo devolvió %+v", jobs)
+	}
+}
+
+func TestIPPControlJob(t *testing.T) {
+	cups := &fakeCUPS{}
+	b := newTestIPPBackend(t, cups)
+
+	if err := b.ControlJob("p", 42, jobControlDelete); err != nil {
+		t.Fatal(err)
+	}
+	if cups.ops[0] != ippCancelJob || cups.paths[0] != "/jobs" {
+		t.Fatalf("operación 0x%04X en %q", cups.ops[0], cups.paths[0])
+	}
+	if id := cups.requests[0].int("job-id"); id != 42 {
+		t.Fatalf("job-id %d", id)
+	}
+
+	// El servidor rechaza Hold-Job: el estado de error IPP vuelve como error.
+	if err := b.ControlJob("p", 42, jobControlPause); err == nil {
+		t.Fatal("se esperaba error por el estado IPP")
+	}
+	if err := b.ControlJob("p", 42, 99); err == nil {
+		t.Fatal("se esperaba error por comando desconocido")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EventLogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Level   string `yaml:"level"` // Por defecto warn
+}
+
+// logSink recibe los registros ya aplanados. Lo implementan el Visor de
+// eventos y syslog.
+type logSink interface {
+	Write(rec logRecord)
+	Close()
+}
+
+// logRecord es un registro de slog con los grupos resueltos como
+// "grupo.clave" y el componente separado del resto de los atributos.
+type logRecord struct {
+	Time      time.Time
+	Level     slog.Level
+	Message   string
+	Component string
+	Attrs     []slog.Attr
+}
+
+func (rec *logRecord) add(a slog.Attr, prefix string) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			rec.add(ga, prefix)
+		}
+		return
+	}
+	key := prefix + a.Key
+	if key == "component" {
+		rec.Component = a.Value.String()
+		return
+	}
+	rec.Attrs = append(rec.Attrs, slog.Attr{Key: key, Value: a.Value})
+}
+
+func (rec logRecord) clone() logRecord {
+	rec.Attrs = append([]slog.Attr(nil), rec.Attrs...)
+	return rec
+}
+
+// text arma una línea "mensaje clave=valor" para los destinos sin campos
+// estructurados.
+func (rec logRecord) text() string {
+	var b strings.Builder
+	b.WriteString(rec.Message)
+	if rec.Component != "" {
+		b.WriteString(" component=" + rec.Component)
+	}
+	for _, a := range rec.Attrs {
+		b.WriteString(" " + a.Key + "=")
+		v := a.Value.String()
+		if strings.ContainsAny(v, " \"=\n") || v == "" {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// sinkHandler adapta un logSink a slog.Handler con su propio nivel mínimo.
+type sinkHandler struct {
+	sink  logSink
+	level slog.Level
+	base  logRecord
+	group string
+}
+
+func (h *sinkHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *sinkHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := h.base.clone()
+	rec.Time = r.Time
+	rec.Level = r.Level
+	rec.Message = r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		rec.add(a, h.group)
+		return true
+	})
+	h.sink.Write(rec)
+	return nil
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.base = h.base.clone()
+	for _, a := range attrs {
+		nh.base.add(a, h.group)
+	}
+	return &nh
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.group = h.group + name + "."
+	return &nh
+}
+
+// fanoutHandler reparte cada registro entre varios handlers; cada uno filtra
+// por su nivel.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// Destinos abiertos. configureLogging se llama cada vez que se lee la
+// configuración; si la sección no cambió se reutiliza la conexión.
+var (
+	eventLogOut    logSink
+	eventLogConfig EventLogConfig
+	syslogOut      logSink
+	syslogConfig   SyslogConfig
+)
+
+// configureLogSinks abre o cierra el Visor de eventos y syslog según la
+// configuración y devuelve sus handlers. Los errores se devuelven como
+// avisos para que configureLogging los escriba en el log principal.
+func configureLogSinks(cfg LoggingConfig) ([]slog.Handler, []logWarning) {
+	var handlers []slog.Handler
+	var warnings []logWarning
+
+	if eventLogOut != nil && (!cfg.EventLog.Enabled || cfg.EventLog != eventLogConfig) {
+		eventLogOut.Close()
+		eventLogOut = nil
+	}
+	if cfg.EventLog.Enabled {
+		if eventLogOut == nil {
+			sink, err := newEventLogSink()
+			if err != nil {
+				warnings = append(warnings, logWarning{"Visor de eventos no disponible", []any{"error", err}})
+			} else {
+				eventLogOut, eventLogConfig = sink, cfg.EventLog
+			}
+		}
+		if eventLogOut != nil {
+			level, ok := parseLogLevel(cfg.EventLog.Level, slog.LevelWarn)
+			if !ok {
+				warnings = append(warnings, invalidLevelWarning("logging.event_log.level", cfg.EventLog.Level, level))
+			}
+			handlers = append(handlers, &sinkHandler{sink: eventLogOut, level: level})
+		}
+	}
+
+	if syslogOut != nil && (!cfg.Syslog.Enabled || cfg.Syslog != syslogConfig) {
+		syslogOut.Close()
+		syslogOut = nil
+	}
+	if cfg.Syslog.Enabled {
+		if syslogOut == nil {
+			sink, err := newSyslogSink(cfg.Syslog)
+			if err != nil {
+				warnings = append(warnings, logWarning{"syslog no disponible", []any{"error", err}})
+			} else {
+				syslogOut, syslogConfig = sink, cfg.Syslog
+			}
+		}
+		if syslogOut != nil {
+			level, ok := parseLogLevel(cfg.Syslog.Level, slog.LevelInfo)
+			if !ok {
+				warnings = append(warnings, invalidLevelWarning("logging.syslog.level", cfg.Syslog.Level, level))
+			}
+			handlers = append(handlers, &sinkHandler{sink: syslogOut, level: level})
+		}
+	}
+	return handlers, warnings
+}
+
+// parseLogLevel acepta debug, info, warn y error; vacío o inválido devuelve
+// def, e inválido además ok=false.
+func parseLogLevel(s string, def slog.Level) (slog.Level, bool) {
+	if s == "" {
+		return def, true
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return def, false
+	}
+	return level, true
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type LoggingConfig struct {
+	Level       string         `yaml:"level"`        // debug | info | warn | error
+	Format      string         `yaml:"format"`       // text | json
+	File        string         `yaml:"file"`         // Por defecto <data_dir>/client.log
+	MaxSizeMB   int            `yaml:"max_size_mb"`  // Tamaño que dispara la rotación
+	RotateHours int            `yaml:"rotate_hours"` // Antigüedad que dispara la rotación
+	MaxBackups  int            `yaml:"max_backups"`  // Archivos rotados que se conservan
+	MaxAgeDays  int            `yaml:"max_age_days"` // Días que se conserva un archivo rotado
+	Console     *bool          `yaml:"console"`      // Por defecto sí, salvo como servicio
+	EventLog    EventLogConfig `yaml:"event_log"`
+	Syslog      SyslogConfig   `yaml:"syslog"`
+}
+
+const (
+	defaultLogMaxSizeMB  = 10
+	defaultLogMaxBackups = 5
+)
+
+// runningAsService se fija en main; como servicio no hay consola donde
+// escribir.
+var runningAsService bool
+
+var (
+	logLevel = new(slog.LevelVar)
+	// logFile es uno solo para todo el proceso: reconfigurar no puede dejar
+	// dos writers sobre el mismo archivo.
+	logFile = &rotatingFile{}
+)
+
+// Loggers por componente. Se piden en cada uso para que tomen el handler
+// vigente después de configureLogging.
+func reportLog() *slog.Logger   { return slog.Default().With("component", "report") }
+func printerLog() *slog.Logger  { return slog.Default().With("component", "printer") }
+func statsLog() *slog.Logger    { return slog.Default().With("component", "stats") }
+func servicesLog() *slog.Logger { return slog.Default().With("component", "services") }
+
+// configureLogging arma el logger global según la configuración. Se llama
+// cada vez que se lee config.yaml.
+func configureLogging(config Config) {
+	cfg := config.Logging
+
+	// Los avisos de configuración se escriben después de armar el handler,
+	// para que queden en el archivo.
+	var warnings []logWarning
+
+	level, ok := parseLogLevel(cfg.Level, slog.LevelInfo)
+	if !ok {
+		warnings = append(warnings, invalidLevelWarning("logging.level", cfg.Level, level))
+	}
+	logLevel.Set(level)
+
+	path := cfg.File
+	if path == "" {
+		path = config.dataPath("client.log")
+	}
+	maxSize := cfg.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSizeMB
+	}
+	maxBackups := cfg.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultLogMaxBackups
+	}
+	logFile.configure(path, int64(maxSize)<<20, time.Duration(cfg.RotateHours)*time.Hour,
+		maxBackups, time.Duration(cfg.MaxAgeDays)*24*time.Hour)
+
+	var out io.Writer = logFile
+	console := !runningAsService
+	if cfg.Console != nil {
+		console = *cfg.Console
+	}
+	if console {
+		out = io.MultiWriter(os.Stderr, logFile)
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	case "", "text":
+		handler = slog.NewTextHandler(out, opts)
+	default:
+		warnings = append(warnings, logWarning{"logging.format desconocido, se usa text", []any{"format", cfg.Format}})
+		handler = slog.NewTextHandler(out, opts)
+	}
+
+	sinks, sinkWarnings := configureLogSinks(cfg)
+	warnings = append(warnings, sinkWarnings...)
+	if len(sinks) > 0 {
+		handler = append(fanoutHandler{handler}, sinks...)
+	}
+
+	// SetDefault también redirige el paquete log al handler, con nivel info.
+	slog.SetDefault(slog.New(localizeHandler{handler}))
+	for _, w := range warnings {
+		slog.Warn(w.msg, w.args...)
+	}
+}
+
+// logWarning es un aviso de configuración que se escribe cuando el handler
+// nuevo ya está activo.
+type logWarning struct {
+	msg  string
+	args []any
+}
+
+func invalidLevelWarning(key, value string, used slog.Level) logWarning {
+	return logWarning{"Nivel de log inválido", []any{"key", key, "value", value, "used", used}}
+}
+
+// logPayloadPrefix es cuánto de un payload fallido se guarda en el log:
+// alcanza para identificar el reporte sin copiarlo entero a client.log.
+const logPayloadPrefix = 256
+
+// logSendError registra un envío fallido con el tamaño del payload y su
+// comienzo. kind identifica el endpoint.
+func logSendError(logger *slog.Logger, kind string, err error, payload []byte) {
+	logger.Error("Error al enviar al servidor", "payload_type", kind, "error", err, "payload_bytes", len(payload), "payload_prefix", payloadPrefix(payload))
+}
+
+// payloadPrefix corta el payload en logPayloadPrefix bytes sin partir un
+// carácter UTF-8.
+func payloadPrefix(payload []byte) string {
+	if len(payload) <= logPayloadPrefix {
+		return string(payload)
+	}
+	cut := logPayloadPrefix
+	for cut > 0 && !utf8.RuneStart(payload[cut]) {
+		cut--
+	}
+	return string(payload[:cut]) + "..."
+}
+
+// rotatingFile es un io.Writer que rota el archivo por tamaño o antigüedad y
+// borra los archivos rotados que exceden la retención.
+type rotatingFile struct {
+	path        string
+	maxSize     int64
+	rotateEvery time.Duration
+	maxBackups  int
+	maxAge      time.Duration
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// configure cambia los parámetros; si cambia la ruta, el próximo Write abre
+// el archivo nuevo.
+func (r *rotatingFile) configure(path string, maxSize int64, rotateEvery time.Duration, maxBackups int, maxAge time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil && path != r.path {
+		r.f.Close()
+		r.f = nil
+	}
+	r.path = path
+	r.maxSize = maxSize
+	r.rotateEvery = rotateEvery
+	r.maxBackups = maxBackups
+	r.maxAge = maxAge
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.due(len(p)) {
+		if err := r.rotate(); err != nil {
+			// Si no se puede rotar se sigue escribiendo en el mismo archivo
+			// antes que perder el mensaje.
+			fmt.Fprintf(os.Stderr, "Error al rotar %s: %v\n", r.path, err)
+		}
+		if r.f == nil {
+			return 0, os.ErrClosed
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) due(next int) bool {
+	if r.size > 0 && r.size+int64(next) > r.maxSize {
+		return true
+	}
+	return r.rotateEvery > 0 && time.Since(r.opened) > r.rotateEvery
+}
+
+// open abre el archivo existente para seguir agregando. Su antigüedad se
+// toma de la última modificación, que es lo más cercano a la creación que
+// hay en todas las plataformas.
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	r.opened = time.Now()
+	if r.size > 0 {
+		r.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + time.Now().Format("20060102-150405") + ext
+	renameErr := os.Rename(r.path, backup)
+	if err := r.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		// Se vuelve a intentar recién cuando se escriba otro maxSize, para no
+		// reintentar en cada mensaje.
+		r.size = 0
+		r.opened = time.Now()
+		return renameErr
+	}
+	r.prune()
+	return nil
+}
+
+// prune borra los archivos rotados más viejos que maxAge y los que sobran
+// de maxBackups, empezando por los más antiguos.
+func (r *rotatingFile) prune() {
+	ext := filepath.Ext(r.path)
+	backups, err := filepath.Glob(strings.TrimSuffix(r.path, ext) + "-*" + ext)
+	if err != nil {
+		return
+	}
+	// El nombre lleva la fecha, así que el orden alfabético es cronológico.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, name := range backups {
+		remove := i >= r.maxBackups
+		if !remove && r.maxAge > 0 {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > r.maxAge {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(name)
+		}
+	}
+}
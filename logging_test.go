@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLogSendErrorTruncatesPayload(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	// "ñ" ocupa dos bytes: el corte en 256 cae en medio de un carácter.
+	payload := []byte("{" + strings.Repeat("ñ", 300) + "}")
+	logSendError(logger, "printer", errors.New("timeout"), payload)
+
+	out := buf.String()
+	if !strings.Contains(out, "payload_bytes=602") {
+		t.Errorf("falta el tamaño: %s", out)
+	}
+	if strings.Contains(out, strings.Repeat("ñ", 200)) {
+		t.Errorf("el payload no se truncó: %s", out)
+	}
+
+	prefix := payloadPrefix(payload)
+	if !utf8.ValidString(prefix) || !strings.HasSuffix(prefix, "...") || len(prefix) > logPayloadPrefix+3 {
+		t.Errorf("prefijo %q", prefix)
+	}
+	if got := payloadPrefix([]byte(`{"a":1}`)); got != `{"a":1}` {
+		t.Errorf("payload corto = %q", got)
+	}
+}
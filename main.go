@@ -1,35 +1,21 @@
 package main
 
 import (
-	"fmt"
 	"log"
-	"time"
-
-	"golang.org/x/sys/windows/svc"
+	"log/slog"
 )
 
 // Ejecuta el cliente en modo consola (no como servicio de Windows).
 func runConsoleMode(config Config) {
-	log.Println("🖥️ Ejecutando en modo consola...")
-
-	// Monitoreo de impresoras
-	go safeGoRoutine("printer monitor", func() {
-		for {
-			printJobs := InitializePrinterDetection(config)
-			for _, job := range printJobs {
-				fmt.Printf("🖨️ %s - 📄 %s - 👤 %s - 🚦 Estado: 0x%X\n",
-					job.PrinterName, job.Document, job.User, job.StatusCode)
-			}
-			time.Sleep(30 * time.Second)
-		}
-	})
-
-	// Conexión al WebSocket
-	go safeGoRoutine("system stats websocket", func() {
-		startSystemStatsWebSocket(config)
-	})
-
-	// Bucle principal
+	configureLogging(config)
+	configureLocale(config)
+	slog.Info("Ejecutando en modo consola...")
+	configureIPDiscovery(config)
+	initAgentIdentity(config)
+	configurePrinterBackend(config)
+	configurePrivacy(config)
+
+	// Bucle principal; inicia también los monitores
 	runClientLoop()
 }
 
@@ -38,20 +24,21 @@ func safeGoRoutine(name string, fn func()) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("❌ Panic en goroutine '%s': %v", name, r)
+				slog.Error("Panic en goroutine", "goroutine", name, "panic", r)
 			}
 		}()
-		log.Printf("🚀 Iniciando goroutine '%s'...", name)
+		slog.Info("Iniciando goroutine", "goroutine", name)
 		fn()
 	}()
 }
 
 func main() {
-	isService, err := svc.IsWindowsService()
+	isService, err := detectService()
 	if err != nil {
 		log.Fatalf("❌ Error detectando si se ejecuta como servicio: %v", err)
 	}
 
+	runningAsService = isService
 	if isService {
 		runService("pirmon-client", false)
 	} else {
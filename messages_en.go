@@ -0,0 +1,143 @@
+package main
+
+var reasonsEN = map[string]string{
+	reasonServiceNotFound:      "Service not found: {error}",
+	reasonServiceQueryFailed:   "Could not query the service: {error}",
+	reasonServiceStateMismatch: "Current state '{actual}' differs from expected '{expected}'",
+	reasonServiceStartFailed:   "Failed to start: {error}",
+	reasonServiceAutoStarted:   "The service was started automatically by the monitor.",
+
+	reasonCommandInvalidPayload:      "invalid payload: {error}",
+	reasonCommandInvalidArgs:         "invalid args: {error}",
+	reasonCommandsDisabled:           "remote commands are disabled",
+	reasonCommandSignatureInvalid:    "invalid signature",
+	reasonCommandExpired:             "command expired (issued_at {issued_at})",
+	reasonCommandNonceReused:         "nonce already used",
+	reasonCommandNotAllowed:          "command '{command}' is not allowed",
+	reasonCommandUnknown:             "unknown command '{command}'",
+	reasonCommandServiceNotMonitored: "service '{service}' is not in the monitored services list",
+	reasonCommandServiceStateTimeout: "the service did not reach state {state} within {timeout}",
+	reasonCommandMissingArgs:         "{args} are required",
+	reasonCommandFailed:              "{error}",
+
+	reasonPrinterActionFailed:      "{error}",
+	reasonNetworkPrinterPollFailed: "{error}",
+}
+
+// messagesEN traduce los mensajes de log y consola. La clave es el texto en
+// español tal como aparece en el código.
+var messagesEN = map[string]string{
+	// Agente
+	"Ejecutando en modo consola...":                                "Running in console mode...",
+	"Iniciando goroutine":                                          "Starting goroutine",
+	"Panic en goroutine":                                           "Panic in goroutine",
+	"ID del agente":                                                "Agent ID",
+	"Agente registrado en el servidor.":                            "Agent registered with the server.",
+	"Error al registrar el agente":                                 "Error registering the agent",
+	"Error al serializar registro del agente":                      "Error serializing agent registration",
+	"No se pudo leer el ID de la máquina, se genera uno aleatorio": "Could not read the machine ID, generating a random one",
+	"Error al guardar ID del agente":                               "Error saving the agent ID",
+	"Error al crear directorio de datos":                           "Error creating the data directory",
+	"Configuración actualizada desde el servidor.":                 "Configuration updated from the server.",
+	"Error al serializar nueva configuración":                      "Error serializing the new configuration",
+	"Error al guardar nueva configuración":                         "Error saving the new configuration",
+	"Error al enviar al servidor":                                  "Error sending to the server",
+	"preferred_subnet inválida":                                    "Invalid preferred_subnet",
+	"Error al enumerar interfaces":                                 "Error enumerating interfaces",
+	"Locale sin catálogo, se usa el predeterminado":                "No catalog for locale, using the default",
+
+	// Logging
+	"Nivel de log inválido":                   "Invalid log level",
+	"logging.format desconocido, se usa text": "Unknown logging.format, using text",
+	"Visor de eventos no disponible":          "Event Log unavailable",
+	"syslog no disponible":                    "syslog unavailable",
+
+	// Privacidad
+	"Regla de privacidad: hash sin salt":      "Privacy rule: hash without salt",
+	"Regla de privacidad: patrón inválido":    "Privacy rule: invalid pattern",
+	"Regla de privacidad: acción desconocida": "Privacy rule: unknown action",
+
+	// Spool
+	"Spool lleno, se descartan entradas antiguas": "Spool full, dropping old entries",
+	"Entradas de spool reenviadas":                "Spool entries resent",
+	"Error al leer spool":                         "Error reading spool",
+	"Error al limpiar spool":                      "Error clearing spool",
+	"Error al crear directorio de spool":          "Error creating the spool directory",
+	"Error al escribir spool":                     "Error writing spool",
+
+	// Inventario
+	"Error al obtener información del sistema":           "Error reading system information",
+	"Error al serializar inventario":                     "Error serializing inventory",
+	"Inventario enviado":                                 "Inventory sent",
+	"Snapshot de software inválido, se enviará completo": "Invalid software snapshot, sending the full list",
+	"Error al guardar snapshot de software":              "Error saving the software snapshot",
+	"Error al listar software instalado":                 "Error listing installed software",
+
+	// Servicios
+	"Error al obtener logs de eventos":          "Error reading event logs",
+	"Error al serializar alerta de auto-inicio": "Error serializing auto-start alert",
+	"Cambio de estado de servicio":              "Service state change",
+	"Suscripción a servicios interrumpida":      "Service subscription interrupted",
+
+	// Stats y alertas
+	"Collector desconocido en la configuración":                                                     "Unknown collector in the configuration",
+	"top_processes.enabled y top_processes.interval están obsoletos, usar collectors.top_processes": "top_processes.enabled and top_processes.interval are deprecated, use collectors.top_processes",
+	"Error en collector":                            "Collector error",
+	"Error al abrir consulta PDH":                   "Error opening PDH query",
+	"Contador PDH inválido":                         "Invalid PDH counter",
+	"Error al obtener interfaces de red":            "Error reading network interfaces",
+	"Error al obtener contadores de red":            "Error reading network counters",
+	"Error al obtener conexiones TCP":               "Error reading TCP connections",
+	"Regla de alerta ignorada: comparador inválido": "Alert rule ignored: invalid comparator",
+	"Error al preparar métricas para alertas":       "Error preparing metrics for alerts",
+	"Alerta":                     "Alert",
+	"Error al serializar alerta": "Error serializing alert",
+	"Error al enviar alerta, se guarda en spool": "Error sending alert, saved to spool",
+	"No se pudo responder":                       "Could not reply",
+	"Error al conectar WebSocket":                "Error connecting WebSocket",
+	"WebSocket de stats del sistema conectado.":  "System stats WebSocket connected.",
+	"WebSocket cerrado":                          "WebSocket closed",
+	"Mensaje WebSocket inválido":                 "Invalid WebSocket message",
+	"Error al serializar mensaje":                "Error serializing message",
+
+	// Comandos remotos
+	"Comando rechazado":                      "Command rejected",
+	"Ejecutando comando remoto":              "Running remote command",
+	"No se pudo enviar auditoría de comando": "Could not send command audit",
+	"update_config intentó cambiar commands; se conserva la configuración local": "update_config tried to change commands; keeping the local configuration",
+
+	// Impresoras
+	"Backend de impresión":                                                                "Print backend",
+	"printer_backend desconocido, se usa el predeterminado":                               "Unknown printer_backend, using the default",
+	"printer_backend no disponible, se usa el predeterminado":                             "printer_backend not available, using the default",
+	"Patrón de impresora inválido":                                                        "Invalid printer pattern",
+	"Configuración incompleta: faltan ServerURL o ServerVersion.":                         "Incomplete configuration: ServerURL or ServerVersion missing.",
+	"Error serializando reporte de impresora":                                             "Error serializing printer report",
+	"Error al leer estado de impresora":                                                   "Error reading printer status",
+	"Cambio de estado":                                                                    "Status change",
+	"Error al leer la cola":                                                               "Error reading the queue",
+	"Trabajo trabado":                                                                     "Stuck job",
+	"Reiniciando el servicio 'Spooler'...":                                                "Restarting the 'Spooler' service...",
+	"El servicio 'Spooler' está detenido. Intentando iniciarlo...":                        "The 'Spooler' service is stopped. Trying to start it...",
+	"Servicio 'Spooler' está corriendo.":                                                  "The 'Spooler' service is running.",
+	"El servicio de impresión no está corriendo":                                          "The print service is not running",
+	"Error al leer nombres de impresoras":                                                 "Error reading printer names",
+	"No se encontraron impresoras instaladas.":                                            "No installed printers found.",
+	"poll_interval del override no se usa mientras haya notificaciones":                   "The override's poll_interval is not used while notifications work",
+	"Notificaciones de impresora no disponibles":                                          "Printer notifications unavailable",
+	"Se pasa a sondeo de impresoras":                                                      "Falling back to printer polling",
+	"Demasiadas impresoras para notificaciones, el resto se revisa en el sondeo completo": "Too many printers for notifications, the rest are checked by the full poll",
+	"Sin notificaciones para la impresora":                                                "No notifications for printer",
+	"Error al consultar impresora de red":                                                 "Error querying network printer",
+	"Evento de impresora de red":                                                          "Network printer event",
+	"Registro de impresión inválido, se descarta":                                         "Invalid print record, discarded",
+	"Error al enviar contabilidad de impresión":                                           "Error sending print accounting",
+	"Contabilidad de impresión enviada":                                                   "Print accounting sent",
+
+	// Consola
+	"🖨️ Impresora: %s\n":                                "🖨️ Printer: %s\n",
+	"   📄 Documento: %s\n":                              "   📄 Document: %s\n",
+	"   👤 Usuario: %s\n":                                "   👤 User: %s\n",
+	"   🛑 Estado: 0x%X %v %s\n":                         "   🛑 Status: 0x%X %v %s\n",
+	"   🚨 Hay un problema con el trabajo de impresión.": "   🚨 There is a problem with the print job.",
+}
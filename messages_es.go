@@ -0,0 +1,27 @@
+package main
+
+// reasonsES son las plantillas en español. También es el texto que llevan
+// los payloads (payloadLocale).
+var reasonsES = map[string]string{
+	reasonServiceNotFound:      "Servicio no encontrado: {error}",
+	reasonServiceQueryFailed:   "No se pudo consultar el servicio: {error}",
+	reasonServiceStateMismatch: "Estado actual '{actual}' difiere del esperado '{expected}'",
+	reasonServiceStartFailed:   "Falló al iniciar: {error}",
+	reasonServiceAutoStarted:   "El servicio fue iniciado automáticamente por el monitor.",
+
+	reasonCommandInvalidPayload:      "payload inválido: {error}",
+	reasonCommandInvalidArgs:         "args inválidos: {error}",
+	reasonCommandsDisabled:           "comandos remotos deshabilitados",
+	reasonCommandSignatureInvalid:    "firma inválida",
+	reasonCommandExpired:             "comando vencido (issued_at {issued_at})",
+	reasonCommandNonceReused:         "nonce repetido",
+	reasonCommandNotAllowed:          "comando '{command}' no permitido",
+	reasonCommandUnknown:             "comando '{command}' desconocido",
+	reasonCommandServiceNotMonitored: "el servicio '{service}' no está en la lista de servicios monitoreados",
+	reasonCommandServiceStateTimeout: "el servicio no llegó al estado {state} en {timeout}",
+	reasonCommandMissingArgs:         "se requieren {args}",
+	reasonCommandFailed:              "{error}",
+
+	reasonPrinterActionFailed:      "{error}",
+	reasonNetworkPrinterPollFailed: "{error}",
+}
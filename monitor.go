@@ -1,71 +1,73 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"os"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/ricochet2200/go-disk-usage/du"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
 type SystemStats struct {
-	Hostname    string          `json:"hostname"`
-	IP          string          `json:"ip"`
-	Timestamp   time.Time       `json:"timestamp"`
-	CPUPercent  float64         `json:"cpu_percent"`
-	MemoryUsed  uint64          `json:"memory_used"`
-	MemoryTotal uint64          `json:"memory_total"`
-	MemoryUsage float64         `json:"memory_usage_percent"`
-	DiskUsage   uint64          `json:"disk_usage"`
-	Services    []ServiceConfig `json:"services"`
+	AgentID     string                 `json:"agent_id"`
+	Hostname    string                 `json:"hostname"`
+	IP          string                 `json:"ip"`
+	IPs         []string               `json:"ips"`
+	Timestamp   time.Time              `json:"timestamp"`
+	CPUPercent  float64                `json:"cpu_percent"`
+	MemoryUsed  uint64                 `json:"memory_used"`
+	MemoryTotal uint64                 `json:"memory_total"`
+	MemoryUsage float64                `json:"memory_usage_percent"`
+	DiskUsage   uint64                 `json:"disk_usage"`
+	Services    []ServiceConfig        `json:"services"`
+	Metrics     map[string]interface{} `json:"metrics"`
 }
 
-func startSystemStatsWebSocket(config Config) {
-	for {
-		url := fmt.Sprintf("ws://%s/api/%s/ws/system-stats", config.ServerURLNoProtocol(), config.ServerVersion)
-		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-		if err != nil {
-			log.Println("Error al conectar WebSocket:", err)
-			time.Sleep(10 * time.Second)
-			continue
-		}
-		defer conn.Close()
+// statsSamples lleva la última muestra de runStatsSampler al WebSocket.
+var statsSamples = make(chan SystemStats, 1)
 
-		log.Println("WebSocket de stats del sistema conectado.")
+// runStatsSampler toma muestras cada monitor_interval y evalúa las alertas
+// aunque el WebSocket esté desconectado. Si nadie consume la muestra
+// anterior, se reemplaza por la nueva.
+func runStatsSampler(config Config, out chan SystemStats) {
+	collectors := newCollectorRunner(config)
+	alerts := newAlertManager(config)
+
+	for {
+		stats := sampleSystemStats(config, collectors)
+		alerts.Evaluate(stats)
 
-		for {
-			hostname, _ := os.Hostname()
-			ip, _ := GetOutboundIP()
+		select {
+		case <-out:
+		default:
+		}
+		out <- stats
 
-			cpuPercentages, _ := cpu.Percent(0, false)
-			memStats, _ := mem.VirtualMemory()
-			diskUsage := du.NewDiskUsage("C:\\").Available()
+		time.Sleep(time.Duration(config.MonitorInterval) * time.Millisecond)
+	}
+}
 
-			stats := SystemStats{
-				Hostname:    hostname,
-				IP:          ip,
-				Timestamp:   time.Now(),
-				CPUPercent:  cpuPercentages[0],
-				MemoryUsed:  memStats.Used,
-				MemoryTotal: memStats.Total,
-				MemoryUsage: memStats.UsedPercent,
-				DiskUsage:   diskUsage,
-				Services:    config.Services,
-			}
+func sampleSystemStats(config Config, collectors *collectorRunner) SystemStats {
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
 
-			payload, _ := json.Marshal(stats)
-			err = conn.WriteMessage(websocket.TextMessage, payload)
-			if err != nil {
-				log.Println("WebSocket cerrado:", err)
-				break
-			}
+	cpuPercentages, _ := cpu.Percent(0, false)
+	memStats, _ := mem.VirtualMemory()
+	diskUsage := du.NewDiskUsage("C:\\").Available()
 
-			time.Sleep(time.Duration(config.MonitorInterval) * time.Millisecond)
-		}
+	return SystemStats{
+		AgentID:     agentID,
+		Hostname:    hostname,
+		IP:          ip,
+		IPs:         GetLocalIPs(),
+		Timestamp:   time.Now(),
+		CPUPercent:  cpuPercentages[0],
+		MemoryUsed:  memStats.Used,
+		MemoryTotal: memStats.Total,
+		MemoryUsage: memStats.UsedPercent,
+		DiskUsage:   diskUsage,
+		Services:    config.Services,
+		Metrics:     collectors.Collect(),
 	}
 }
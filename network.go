@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+type InterfaceStats struct {
+	Name              string   `json:"name"`
+	Up                bool     `json:"up"`
+	MTU               int      `json:"mtu"`
+	HardwareAddr      string   `json:"hardware_addr,omitempty"`
+	Addresses         []string `json:"addresses"`
+	BytesSentPerSec   float64  `json:"bytes_sent_per_sec"`
+	BytesRecvPerSec   float64  `json:"bytes_recv_per_sec"`
+	PacketsSentPerSec float64  `json:"packets_sent_per_sec"`
+	PacketsRecvPerSec float64  `json:"packets_recv_per_sec"`
+	ErrInPerSec       float64  `json:"err_in_per_sec"`
+	ErrOutPerSec      float64  `json:"err_out_per_sec"`
+	DropInPerSec      float64  `json:"drop_in_per_sec"`
+	DropOutPerSec     float64  `json:"drop_out_per_sec"`
+}
+
+type NetworkStats struct {
+	Interfaces     []InterfaceStats `json:"interfaces"`
+	TCPConnections map[string]int   `json:"tcp_connections"`
+}
+
+func init() {
+	registerCollector("network", CollectorConfig{Enabled: true}, func(Config) Collector { return newNetworkCollector() })
+}
+
+// networkCollector guarda los contadores de la muestra anterior para calcular tasas.
+type networkCollector struct {
+	prev     map[string]psnet.IOCountersStat
+	prevTime time.Time
+}
+
+func newNetworkCollector() *networkCollector {
+	return &networkCollector{prev: make(map[string]psnet.IOCountersStat)}
+}
+
+func (n *networkCollector) Name() string { return "network" }
+
+// Collect obtiene el estado de las interfaces y las conexiones TCP. Las tasas
+// se calculan respecto a la muestra anterior, por lo que la primera llamada
+// las reporta en cero.
+func (n *networkCollector) Collect() (interface{}, error) {
+	stats := NetworkStats{TCPConnections: make(map[string]int)}
+	now := time.Now()
+
+	ifaces, err := psnet.Interfaces()
+	if err != nil {
+		statsLog().Error("Error al obtener interfaces de red", "error", err)
+	}
+
+	counters, err := psnet.IOCounters(true)
+	if err != nil {
+		statsLog().Error("Error al obtener contadores de red", "error", err)
+	}
+	current := make(map[string]psnet.IOCountersStat, len(counters))
+	for _, c := range counters {
+		current[c.Name] = c
+	}
+
+	elapsed := now.Sub(n.prevTime).Seconds()
+	for _, iface := range ifaces {
+		is := InterfaceStats{
+			Name:         iface.Name,
+			MTU:          iface.MTU,
+			HardwareAddr: iface.HardwareAddr,
+		}
+		for _, flag := range iface.Flags {
+			if strings.EqualFold(flag, "up") {
+				is.Up = true
+			}
+		}
+		for _, addr := range iface.Addrs {
+			is.Addresses = append(is.Addresses, addr.Addr)
+		}
+
+		cur, ok := current[iface.Name]
+		prev, hasPrev := n.prev[iface.Name]
+		if ok && hasPrev && elapsed > 0 {
+			is.BytesSentPerSec = counterRate(prev.BytesSent, cur.BytesSent, elapsed)
+			is.BytesRecvPerSec = counterRate(prev.BytesRecv, cur.BytesRecv, elapsed)
+			is.PacketsSentPerSec = counterRate(prev.PacketsSent, cur.PacketsSent, elapsed)
+			is.PacketsRecvPerSec = counterRate(prev.PacketsRecv, cur.PacketsRecv, elapsed)
+			is.ErrInPerSec = counterRate(prev.Errin, cur.Errin, elapsed)
+			is.ErrOutPerSec = counterRate(prev.Errout, cur.Errout, elapsed)
+			is.DropInPerSec = counterRate(prev.Dropin, cur.Dropin, elapsed)
+			is.DropOutPerSec = counterRate(prev.Dropout, cur.Dropout, elapsed)
+		}
+		stats.Interfaces = append(stats.Interfaces, is)
+	}
+
+	n.prev = current
+	n.prevTime = now
+
+	conns, err := psnet.Connections("tcp")
+	if err != nil {
+		statsLog().Error("Error al obtener conexiones TCP", "error", err)
+	}
+	for _, c := range conns {
+		stats.TCPConnections[c.Status]++
+	}
+
+	return stats, nil
+}
+
+// counterRate calcula la tasa por segundo entre dos lecturas de un contador.
+// Si el contador se reinició (la interfaz se reconectó) devuelve cero.
+func counterRate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNetworkPrinterInterval = 5 * time.Minute
+	defaultSNMPTimeout            = 5 * time.Second
+	defaultLowSupplyPercent       = 15
+)
+
+type NetworkPrintersConfig struct {
+	Interval         uint                   `yaml:"interval"`           // Segundos
+	Timeout          uint                   `yaml:"timeout"`            // Segundos por consulta SNMP
+	LowSupplyPercent int                    `yaml:"low_supply_percent"` // Umbral por defecto
+	Printers         []NetworkPrinterConfig `yaml:"printers"`
+}
+
+type NetworkPrinterConfig struct {
+	Name             string `yaml:"name"`
+	Address          string `yaml:"address"` // host o host:puerto, por defecto 161
+	Version          string `yaml:"version"` // 2c | 3
+	Community        string `yaml:"community"`
+	User             string `yaml:"user"`
+	AuthProtocol     string `yaml:"auth_protocol"` // MD5 | SHA
+	AuthPassword     string `yaml:"auth_password"`
+	PrivProtocol     string `yaml:"priv_protocol"` // AES
+	PrivPassword     string `yaml:"priv_password"`
+	LowSupplyPercent int    `yaml:"low_supply_percent"`
+}
+
+func (c NetworkPrinterConfig) displayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Address
+}
+
+// OIDs de HOST-RESOURCES-MIB y Printer-MIB (RFC 3805).
+const (
+	oidHrPrinterStatus      = "1.3.6.1.2.1.25.3.5.1.1"
+	oidHrPrinterErrorState  = "1.3.6.1.2.1.25.3.5.1.2"
+	oidPrtMarkerLifeCount   = "1.3.6.1.2.1.43.10.2.1.4"
+	oidPrtSupplyDescription = "1.3.6.1.2.1.43.11.1.1.6"
+	oidPrtSupplyMaxCapacity = "1.3.6.1.2.1.43.11.1.1.8"
+	oidPrtSupplyLevel       = "1.3.6.1.2.1.43.11.1.1.9"
+	oidPrtInputMaxCapacity  = "1.3.6.1.2.1.43.8.2.1.9"
+	oidPrtInputCurrentLevel = "1.3.6.1.2.1.43.8.2.1.10"
+	oidPrtInputName         = "1.3.6.1.2.1.43.8.2.1.13"
+	oidPrtAlertSeverity     = "1.3.6.1.2.1.43.18.1.1.2"
+	oidPrtAlertCode         = "1.3.6.1.2.1.43.18.1.1.7"
+	oidPrtAlertDescription  = "1.3.6.1.2.1.43.18.1.1.8"
+)
+
+var hrPrinterStatusNames = map[int64]string{
+	1: "other",
+	2: "unknown",
+	3: "idle",
+	4: "printing",
+	5: "warmup",
+}
+
+// Bits de hrPrinterDetectedErrorState; el bit 0 es el más significativo del
+// primer byte.
+var hrPrinterErrorNames = []string{
+	"low_paper", "no_paper", "low_toner", "no_toner", "door_open", "jammed",
+	"offline", "service_requested", "input_tray_missing", "output_tray_missing",
+	"marker_supply_missing", "output_near_full", "output_full", "input_tray_empty",
+	"overdue_prevent_maint",
+}
+
+var prtAlertSeverityNames = map[int64]string{
+	1: "other",
+	3: "critical",
+	4: "warning",
+	5: "warning_binary_change",
+}
+
+type PrinterSupply struct {
+	Index       string `json:"index"`
+	Description string `json:"description"`
+	Level       int64  `json:"level"`
+	MaxCapacity int64  `json:"max_capacity"`
+	Percent     int    `json:"percent"` // -1 si el equipo no informa un nivel medible
+}
+
+type PrinterTray struct {
+	Index       string `json:"index"`
+	Name        string `json:"name"`
+	Level       int64  `json:"level"`
+	MaxCapacity int64  `json:"max_capacity"`
+}
+
+type PrinterMIBAlert struct {
+	Index       string `json:"index"`
+	Severity    string `json:"severity"`
+	Code        int64  `json:"code"`
+	Description string `json:"description"`
+}
+
+// NetworkPrinterState es el resultado de un sondeo SNMP.
+type NetworkPrinterState struct {
+	Status    string            `json:"status"`
+	Errors    []string          `json:"errors"`
+	PageCount uint64            `json:"page_count"`
+	Supplies  []PrinterSupply   `json:"supplies"`
+	Trays     []PrinterTray     `json:"trays"`
+	Alerts    []PrinterMIBAlert `json:"alerts"`
+}
+
+type NetworkPrinterReport struct {
+	AgentID        string               `json:"agent_id"`
+	Event          string               `json:"event"` // network_printer_status | network_printer_unreachable | supply_low | supply_recovered | printer_alert | printer_alert_cleared
+	PrinterName    string               `json:"printer_name"`
+	Address        string               `json:"address"`
+	PreviousErrors []string             `json:"previous_errors,omitempty"`
+	Supply         *PrinterSupply       `json:"supply,omitempty"`
+	Alert          *PrinterMIBAlert     `json:"alert,omitempty"`
+	Error          string               `json:"error,omitempty"`
+	Reason         *Reason              `json:"reason,omitempty"`
+	State          *NetworkPrinterState `json:"state,omitempty"`
+	Timestamp      string               `json:"timestamp"`
+}
+
+// pollNetworkPrinter lee estado, consumibles, bandejas, contador y alertas.
+// Las tablas que el equipo no implementa quedan vacías.
+func pollNetworkPrinter(config NetworkPrinterConfig, timeout time.Duration) (*NetworkPrinterState, error) {
+	client, err := dialSNMP(config, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	state := &NetworkPrinterState{Status: "unknown", Errors: []string{}}
+	status, err := client.Walk(oidHrPrinterStatus)
+	if err != nil {
+		return nil, err
+	}
+	if len(status) > 0 {
+		if name, ok := hrPrinterStatusNames[status[0].Int()]; ok {
+			state.Status = name
+		}
+	}
+	if errs, _ := client.Walk(oidHrPrinterErrorState); len(errs) > 0 {
+		state.Errors = decodeErrorState([]byte(errs[0].String()))
+	}
+
+	counters, _ := client.Walk(oidPrtMarkerLifeCount)
+	for _, v := range counters {
+		state.PageCount += uint64(v.Int())
+	}
+
+	descriptions := walkColumn(client, oidPrtSupplyDescription)
+	maxCapacity := walkColumn(client, oidPrtSupplyMaxCapacity)
+	levels := walkColumn(client, oidPrtSupplyLevel)
+	for _, index := range sortedKeys(descriptions) {
+		supply := PrinterSupply{
+			Index:       index,
+			Description: descriptions[index].String(),
+			Level:       levels[index].Int(),
+			MaxCapacity: maxCapacity[index].Int(),
+			Percent:     -1,
+		}
+		// Los niveles negativos son -1 otro, -2 desconocido y -3 "queda algo".
+		if supply.MaxCapacity > 0 && supply.Level >= 0 {
+			supply.Percent = int(supply.Level * 100 / supply.MaxCapacity)
+		}
+		state.Supplies = append(state.Supplies, supply)
+	}
+
+	trayNames := walkColumn(client, oidPrtInputName)
+	trayMax := walkColumn(client, oidPrtInputMaxCapacity)
+	trayLevels := walkColumn(client, oidPrtInputCurrentLevel)
+	for _, index := range sortedKeys(trayLevels) {
+		state.Trays = append(state.Trays, PrinterTray{
+			Index:       index,
+			Name:        trayNames[index].String(),
+			Level:       trayLevels[index].Int(),
+			MaxCapacity: trayMax[index].Int(),
+		})
+	}
+
+	severities := walkColumn(client, oidPrtAlertSeverity)
+	codes := walkColumn(client, oidPrtAlertCode)
+	alertDescriptions := walkColumn(client, oidPrtAlertDescription)
+	for _, index := range sortedKeys(severities) {
+		severity, ok := prtAlertSeverityNames[severities[index].Int()]
+		if !ok {
+			severity = "other"
+		}
+		state.Alerts = append(state.Alerts, PrinterMIBAlert{
+			Index:       index,
+			Severity:    severity,
+			Code:        codes[index].Int(),
+			Description: alertDescriptions[index].String(),
+		})
+	}
+	return state, nil
+}
+
+// walkColumn recorre una columna de una tabla y la indexa por el sufijo del
+// OID. Un error deja la columna vacía.
+func walkColumn(client *snmpClient, column string) map[string]snmpVar {
+	vars, err := client.Walk(column)
+	if err != nil && len(vars) == 0 {
+		return nil
+	}
+	out := make(map[string]snmpVar, len(vars))
+	for _, v := range vars {
+		out[strings.TrimPrefix(v.OID, column+".")] = v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]snmpVar) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeErrorState(bits []byte) []string {
+	names := []string{}
+	for i, name := range hrPrinterErrorNames {
+		if i/8 < len(bits) && bits[i/8]&(0x80>>(i%8)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type networkPrinterMemory struct {
+	reachable bool
+	status    string
+	errors    []string
+	low       map[string]bool
+	alerts    map[string]PrinterMIBAlert
+}
+
+// networkPrinterTracker compara cada sondeo con el anterior y devuelve solo
+// los eventos nuevos.
+type networkPrinterTracker struct {
+	printers map[string]*networkPrinterMemory
+}
+
+func newNetworkPrinterTracker() *networkPrinterTracker {
+	return &networkPrinterTracker{printers: make(map[string]*networkPrinterMemory)}
+}
+
+// Observe registra un sondeo (state nil si falló) y devuelve los eventos.
+// El primer sondeo exitoso siempre genera un network_printer_status.
+func (t *networkPrinterTracker) Observe(config NetworkPrinterConfig, state *NetworkPrinterState, pollErr error, threshold int, now time.Time) []NetworkPrinterReport {
+	name := config.displayName()
+	base := NetworkPrinterReport{AgentID: agentID, PrinterName: name, Address: config.Address, Timestamp: now.Format(time.RFC3339)}
+	mem, seen := t.printers[name]
+
+	if pollErr != nil {
+		if seen && !mem.reachable {
+			return nil
+		}
+		if !seen {
+			mem = &networkPrinterMemory{low: map[string]bool{}, alerts: map[string]PrinterMIBAlert{}}
+			t.printers[name] = mem
+		}
+		mem.reachable = false
+		r := base
+		r.Event = "network_printer_unreachable"
+		reason := reasonOf(pollErr, reasonNetworkPrinterPollFailed)
+		r.Error = reason.Error()
+		r.Reason = &reason
+		return []NetworkPrinterReport{r}
+	}
+
+	var reports []NetworkPrinterReport
+	if !seen {
+		mem = &networkPrinterMemory{low: map[string]bool{}, alerts: map[string]PrinterMIBAlert{}}
+		t.printers[name] = mem
+	}
+	if !seen || !mem.reachable || mem.status != state.Status || strings.Join(mem.errors, ",") != strings.Join(state.Errors, ",") {
+		r := base
+		r.Event = "network_printer_status"
+		r.PreviousErrors = mem.errors
+		r.State = state
+		reports = append(reports, r)
+	}
+	mem.reachable = true
+	mem.status = state.Status
+	mem.errors = state.Errors
+
+	for i := range state.Supplies {
+		supply := state.Supplies[i]
+		if supply.Percent < 0 {
+			continue
+		}
+		low := supply.Percent <= threshold
+		if low == mem.low[supply.Index] {
+			continue
+		}
+		mem.low[supply.Index] = low
+		r := base
+		r.Supply = &supply
+		r.Event = "supply_low"
+		if !low {
+			r.Event = "supply_recovered"
+		}
+		reports = append(reports, r)
+	}
+
+	current := make(map[string]PrinterMIBAlert, len(state.Alerts))
+	for i := range state.Alerts {
+		alert := state.Alerts[i]
+		key := alertKey(alert)
+		current[key] = alert
+		if _, known := mem.alerts[key]; !known {
+			r := base
+			r.Event = "printer_alert"
+			r.Alert = &alert
+			reports = append(reports, r)
+		}
+	}
+	for key, alert := range mem.alerts {
+		if _, still := current[key]; !still {
+			alert := alert
+			r := base
+			r.Event = "printer_alert_cleared"
+			r.Alert = &alert
+			reports = append(reports, r)
+		}
+	}
+	mem.alerts = current
+	return reports
+}
+
+func alertKey(a PrinterMIBAlert) string {
+	return a.Index + "|" + a.Severity + "|" + a.Description
+}
+
+// runNetworkPrinterMonitor sondea por SNMP las impresoras de red configuradas
+// y envía los eventos a /log/printer.
+func runNetworkPrinterMonitor(config Config) {
+	cfg := config.NetworkPrinters
+	interval := defaultNetworkPrinterInterval
+	if cfg.Interval > 0 {
+		interval = time.Duration(cfg.Interval) * time.Second
+	}
+	timeout := defaultSNMPTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	tracker := newNetworkPrinterTracker()
+
+	for {
+		for _, p := range cfg.Printers {
+			threshold := cfg.LowSupplyPercent
+			if p.LowSupplyPercent > 0 {
+				threshold = p.LowSupplyPercent
+			}
+			if threshold <= 0 {
+				threshold = defaultLowSupplyPercent
+			}
+
+			state, err := pollNetworkPrinter(p, timeout)
+			if err != nil {
+				printerLog().Warn("Error al consultar impresora de red", "printer", p.displayName(), "address", p.Address, "error", err)
+			}
+			for _, report := range tracker.Observe(p, state, err, threshold, time.Now()) {
+				printerLog().Info("Evento de impresora de red", "printer", report.PrinterName, "event", report.Event)
+				sendPrinterPayload(config, report)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type PDHConfig struct {
+	Counters []PDHCounterConfig `yaml:"counters"`
+}
+
+type PDHCounterConfig struct {
+	Path string `yaml:"path"`
+	// Rate convierte un contador acumulativo en una tasa por segundo entre
+	// muestras. Los contadores que PDH ya entrega como tasa no lo necesitan.
+	Rate bool `yaml:"rate"`
+}
+
+// counterValue es el valor de una instancia de un contador. Instance queda
+// vacío cuando el contador no tiene instancias.
+type counterValue struct {
+	Instance string
+	Value    float64
+}
+
+// counterSource abstrae la lectura de contadores de rendimiento para poder
+// probar la lógica de muestreo sin PDH.
+type counterSource interface {
+	Add(path string) error
+	// Collect toma una muestra de todos los contadores agregados. Un contador
+	// sin datos válidos en esta muestra no aparece en el resultado.
+	Collect() (map[string][]counterValue, error)
+}
+
+type PDHSample struct {
+	Path     string  `json:"path"`
+	Instance string  `json:"instance,omitempty"`
+	Value    float64 `json:"value"`
+}
+
+func init() {
+	registerCollector("pdh", CollectorConfig{Enabled: false, Interval: 10}, func(config Config) Collector {
+		source, err := newPDHSource()
+		if err != nil {
+			statsLog().Error("Error al abrir consulta PDH", "error", err)
+			return &pdhCollector{err: err}
+		}
+		return newPDHCollector(config.PDH, source)
+	})
+}
+
+// pdhCollector reporta los contadores configurados en config.yaml.
+type pdhCollector struct {
+	source   counterSource
+	counters []PDHCounterConfig
+	prev     map[string]float64
+	prevTime time.Time
+	err      error
+}
+
+func newPDHCollector(config PDHConfig, source counterSource) *pdhCollector {
+	c := &pdhCollector{source: source, prev: make(map[string]float64)}
+	for _, counter := range config.Counters {
+		if err := source.Add(counter.Path); err != nil {
+			statsLog().Warn("Contador PDH inválido", "counter", counter.Path, "error", err)
+			continue
+		}
+		c.counters = append(c.counters, counter)
+	}
+	return c
+}
+
+func (c *pdhCollector) Name() string { return "pdh" }
+
+// Collect devuelve una muestra por instancia de cada contador. Los contadores
+// marcados como rate se omiten hasta tener una muestra previa.
+func (c *pdhCollector) Collect() (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.counters) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.source.Collect()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(c.prevTime).Seconds()
+
+	current := make(map[string]float64)
+	samples := []PDHSample{}
+	for _, counter := range c.counters {
+		for _, v := range values[counter.Path] {
+			sample := PDHSample{Path: counter.Path, Instance: v.Instance, Value: v.Value}
+			if counter.Rate {
+				key := counter.Path + "|" + v.Instance
+				current[key] = v.Value
+				prev, ok := c.prev[key]
+				if !ok || elapsed <= 0 || v.Value < prev {
+					continue
+				}
+				sample.Value = (v.Value - prev) / elapsed
+			}
+			samples = append(samples, sample)
+		}
+	}
+
+	c.prev = current
+	c.prevTime = now
+	return samples, nil
+}
+
+// pdhStatusError representa un código PDH_STATUS distinto de ERROR_SUCCESS.
+type pdhStatusError uint32
+
+func (e pdhStatusError) Error() string {
+	return fmt.Sprintf("PDH status 0x%08X", uint32(e))
+}
@@ -0,0 +1,9 @@
+package main
+
+import "errors"
+
+// newPDHSource falla fuera de Windows: PDH no tiene equivalente y el
+// collector pdh queda deshabilitado con el error.
+func newPDHSource() (counterSource, error) {
+	return nil, errors.New("PDH solo existe en Windows")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeCounterSource devuelve en cada Collect la siguiente muestra del guion.
+type fakeCounterSource struct {
+	invalid map[string]bool
+	added   []string
+	samples []map[string][]counterValue
+	err     error
+}
+
+func (s *fakeCounterSource) Add(path string) error {
+	if s.invalid[path] {
+		return pdhStatusError(0xC0000BC0)
+	}
+	s.added = append(s.added, path)
+	return nil
+}
+
+func (s *fakeCounterSource) Collect() (map[string][]counterValue, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.samples) == 0 {
+		return nil, nil
+	}
+	sample := s.samples[0]
+	s.samples = s.samples[1:]
+	return sample, nil
+}
+
+const (
+	pdhCPU   = `\Processor(*)\% Processor Time`
+	pdhBytes = `\Network Interface(*)\Bytes Total/sec`
+	pdhPages = `\Print Queue(_Total)\Total Pages Printed`
+)
+
+func collectPDH(t *testing.T, c *pdhCollector) []PDHSample {
+	t.Helper()
+	got, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got.([]PDHSample)
+}
+
+func TestPDHCollector(t *testing.T) {
+	source := &fakeCounterSource{
+		invalid: map[string]bool{`\No Existe\Nada`: true},
+		samples: []map[string][]counterValue{
+			{
+				pdhCPU:   {{"0", 12.5}, {"_Total", 10}},
+				pdhPages: {{"", 100}},
+			},
+			{
+				pdhCPU:   {{"0", 50}, {"_Total", 40}},
+				pdhPages: {{"", 130}},
+			},
+			{
+				// La cola se reinició y el contador de CPU no tuvo datos.
+				pdhPages: {{"", 5}},
+			},
+			{
+				pdhPages: {{"", 25}},
+			},
+		},
+	}
+	c := newPDHCollector(PDHConfig{Counters: []PDHCounterConfig{
+		{Path: pdhCPU},
+		{Path: `\No Existe\Nada`},
+		{Path: pdhPages, Rate: true},
+	}}, source)
+
+	if !reflect.DeepEqual(source.added, []string{pdhCPU, pdhPages}) {
+		t.Fatalf("contadores agregados %v", source.added)
+	}
+	if c.Name() != "pdh" {
+		t.Fatalf("Name = %q", c.Name())
+	}
+
+	// Primera muestra: el contador rate se omite hasta tener una previa.
+	want := []PDHSample{
+		{Path: pdhCPU, Instance: "0", Value: 12.5},
+		{Path: pdhCPU, Instance: "_Total", Value: 10},
+	}
+	if got := collectPDH(t, c); !reflect.DeepEqual(got, want) {
+		t.Fatalf("primera muestra %+v", got)
+	}
+
+	// Se simulan dos segundos entre muestras: (130-100)/2 = 15 por segundo.
+	c.prevTime = c.prevTime.Add(-2 * time.Second)
+	got := collectPDH(t, c)
+	if len(got) != 3 || got[0].Value != 50 || got[1].Value != 40 {
+		t.Fatalf("segunda muestra %+v", got)
+	}
+	if rate := got[2]; rate.Path != pdhPages || rate.Instance != "" || math.Abs(rate.Value-15) > 0.1 {
+		t.Fatalf("tasa %+v, se esperaba ~15", rate)
+	}
+
+	// Un contador que retrocede no genera una tasa negativa.
+	c.prevTime = c.prevTime.Add(-2 * time.Second)
+	if got := collectPDH(t, c); len(got) != 0 {
+		t.Fatalf("tercera muestra %+v", got)
+	}
+
+	// Y la muestra siguiente vuelve a calcular desde el valor reiniciado.
+	c.prevTime = c.prevTime.Add(-4 * time.Second)
+	got = collectPDH(t, c)
+	if len(got) != 1 || math.Abs(got[0].Value-5) > 0.1 {
+		t.Fatalf("cuarta muestra %+v, se esperaba ~5", got)
+	}
+}
+
+func TestPDHCollectorErrors(t *testing.T) {
+	sourceErr := pdhStatusError(0x800007D5)
+	if sourceErr.Error() != "PDH status 0x800007D5" {
+		t.Fatalf("Error = %q", sourceErr.Error())
+	}
+
+	c := newPDHCollector(PDHConfig{Counters: []PDHCounterConfig{{Path: pdhBytes}}}, &fakeCounterSource{err: sourceErr})
+	if _, err := c.Collect(); !errors.Is(err, sourceErr) {
+		t.Fatalf("Collect = %v, se esperaba el error de la fuente", err)
+	}
+
+	c = newPDHCollector(PDHConfig{}, &fakeCounterSource{})
+	if got, err := c.Collect(); got != nil || err != nil {
+		t.Fatalf("sin contadores: %v, %v", got, err)
+	}
+
+	openErr := errors.New("sin PDH")
+	c = &pdhCollector{err: openErr}
+	if _, err := c.Collect(); err != openErr {
+		t.Fatalf("Collect = %v, se esperaba el error de apertura", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	pdh                             = windows.NewLazySystemDLL("pdh.dll")
+	procPdhOpenQuery                = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounter        = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterArray = pdh.NewProc("PdhGetFormattedCounterArrayW")
+)
+
+const (
+	pdhFmtDouble   = 0x00000200
+	pdhFmtNoCap100 = 0x00008000
+	pdhMoreData    = 0x800007D2
+
+	pdhCStatusValidData = 0
+	pdhCStatusNewData   = 1
+)
+
+// pdhFmtCounterValueDouble corresponde a PDH_FMT_COUNTERVALUE con el valor
+// double alineado a 8 bytes también en 386.
+type pdhFmtCounterValueDouble struct {
+	CStatus     uint32
+	_           uint32
+	DoubleValue float64
+}
+
+type pdhFmtCounterValueItemDouble struct {
+	SzName   *uint16
+	_        [8 - unsafe.Sizeof(uintptr(0))]byte
+	FmtValue pdhFmtCounterValueDouble
+}
+
+// pdhSource lee contadores con la API de PDH. Usa los nombres en inglés para
+// que la configuración no dependa del idioma del sistema.
+type pdhSource struct {
+	query    uintptr
+	counters map[string]uintptr
+}
+
+func newPDHSource() (*pdhSource, error) {
+	var query uintptr
+	ret, _, _ := procPdhOpenQuery.Call(0, 0, uintptr(unsafe.Pointer(&query)))
+	if ret != 0 {
+		return nil, pdhStatusError(ret)
+	}
+	return &pdhSource{query: query, counters: make(map[string]uintptr)}, nil
+}
+
+func (s *pdhSource) Add(path string) error {
+	var counter uintptr
+	ret, _, _ := procPdhAddEnglishCounter.Call(
+		s.query,
+		uintptr(unsafe.Pointer(utf16Ptr(path))),
+		0,
+		uintptr(unsafe.Pointer(&counter)),
+	)
+	if ret != 0 {
+		return pdhStatusError(ret)
+	}
+	s.counters[path] = counter
+	return nil
+}
+
+func (s *pdhSource) Collect() (map[string][]counterValue, error) {
+	ret, _, _ := procPdhCollectQueryData.Call(s.query)
+	if ret != 0 {
+		return nil, pdhStatusError(ret)
+	}
+
+	values := make(map[string][]counterValue)
+	for path, counter := range s.counters {
+		// Los contadores de tasa no tienen datos hasta la segunda muestra.
+		if v, err := readCounterArray(counter); err == nil {
+			values[path] = v
+		}
+	}
+	return values, nil
+}
+
+// readCounterArray obtiene el valor de cada instancia de un contador. Para
+// rutas sin comodines devuelve un único elemento.
+func readCounterArray(counter uintptr) ([]counterValue, error) {
+	var size, count uint32
+	ret, _, _ := procPdhGetFormattedCounterArray.Call(
+		counter, pdhFmtDouble|pdhFmtNoCap100,
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), 0,
+	)
+	if ret != pdhMoreData {
+		return nil, pdhStatusError(ret)
+	}
+	if size == 0 || count == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+	ret, _, _ = procPdhGetFormattedCounterArray.Call(
+		counter, pdhFmtDouble|pdhFmtNoCap100,
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)),
+		uintptr(unsafe.Pointer(&buf[0])),
+	)
+	if ret != 0 {
+		return nil, pdhStatusError(ret)
+	}
+
+	items := unsafe.Slice((*pdhFmtCounterValueItemDouble)(unsafe.Pointer(&buf[0])), count)
+	values := make([]counterValue, 0, count)
+	for _, item := range items {
+		status := item.FmtValue.CStatus
+		if status != pdhCStatusValidData && status != pdhCStatusNewData {
+			continue
+		}
+		values = append(values, counterValue{
+			Instance: windows.UTF16PtrToString(item.SzName),
+			Value:    item.FmtValue.DoubleValue,
+		})
+	}
+	return values, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultPrintAccountingInterval = time.Hour
+
+type PrintAccountingConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	ReportInterval uint `yaml:"report_interval"` // Segundos
+}
+
+// PrintRecord es un trabajo terminado, tal como se guarda en disco hasta que
+// se incluye en un reporte de uso.
+type PrintRecord struct {
+	PrinterName string    `json:"printer_name"`
+	JobID       uint32    `json:"job_id"`
+	Document    string    `json:"document"`
+	User        string    `json:"user"`
+	Pages       uint32    `json:"pages"`
+	Color       string    `json:"color,omitempty"`
+	Duplex      string    `json:"duplex,omitempty"`
+	Submitted   time.Time `json:"submitted"`
+	Completed   time.Time `json:"completed"`
+}
+
+// PrintUsage suma los trabajos de una impresora o de un usuario.
+type PrintUsage struct {
+	Name       string `json:"name"`
+	Jobs       int    `json:"jobs"`
+	Pages      uint64 `json:"pages"`
+	ColorPages uint64 `json:"color_pages"`
+	DuplexJobs int    `json:"duplex_jobs"`
+}
+
+func (u *PrintUsage) add(r PrintRecord) {
+	u.Jobs++
+	u.Pages += uint64(r.Pages)
+	if r.Color == "color" {
+		u.ColorPages += uint64(r.Pages)
+	}
+	if r.Duplex == "long_edge" || r.Duplex == "short_edge" {
+		u.DuplexJobs++
+	}
+}
+
+type PrintUsageReport struct {
+	AgentID     string        `json:"agent_id"`
+	Hostname    string        `json:"hostname"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	ByPrinter   []PrintUsage  `json:"by_printer"`
+	ByUser      []PrintUsage  `json:"by_user"`
+	Jobs        []PrintRecord `json:"jobs"`
+}
+
+// buildPrintUsageReport agrupa los registros por impresora y por usuario.
+func buildPrintUsageReport(records []PrintRecord, now time.Time) PrintUsageReport {
+	hostname, _ := os.Hostname()
+	report := PrintUsageReport{AgentID: agentID, Hostname: hostname, PeriodEnd: now, Jobs: records}
+
+	byPrinter := make(map[string]*PrintUsage)
+	byUser := make(map[string]*PrintUsage)
+	for _, r := range records {
+		if report.PeriodStart.IsZero() || r.Completed.Before(report.PeriodStart) {
+			report.PeriodStart = r.Completed
+		}
+		if byPrinter[r.PrinterName] == nil {
+			byPrinter[r.PrinterName] = &PrintUsage{Name: r.PrinterName}
+		}
+		byPrinter[r.PrinterName].add(r)
+		if byUser[r.User] == nil {
+			byUser[r.User] = &PrintUsage{Name: r.User}
+		}
+		byUser[r.User].add(r)
+	}
+	report.ByPrinter = sortedUsage(byPrinter)
+	report.ByUser = sortedUsage(byUser)
+	return report
+}
+
+func sortedUsage(m map[string]*PrintUsage) []PrintUsage {
+	usage := make([]PrintUsage, 0, len(m))
+	for _, u := range m {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// jobPrintedMask indica que el trabajo terminó de imprimirse aunque siga en la
+// cola (impresoras con keep_printed_jobs).
+const jobPrintedMask = 0x00000080 | 0x00001000
+
+type accountedJob struct {
+	job      PrintJob
+	recorded bool
+}
+
+// printAccountant detecta los trabajos que terminaron entre sondeos. Un
+// trabajo cuenta como impreso cuando se lo vio con el estado printed o
+// cuando sale de la cola con todas sus páginas impresas. Los trabajos que
+// borró el propio agente no cuentan.
+type printAccountant struct {
+	mu       sync.Mutex
+	printers map[string]map[uint32]*accountedJob
+	deleted  map[string]map[uint32]bool
+}
+
+func newPrintAccountant() *printAccountant {
+	return &printAccountant{
+		printers: make(map[string]map[uint32]*accountedJob),
+		deleted:  make(map[string]map[uint32]bool),
+	}
+}
+
+// MarkDeleted registra que el agente borró el trabajo (comando o
+// remediación), para no contarlo como impreso cuando salga de la cola.
+func (a *printAccountant) MarkDeleted(printerName string, jobID uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.deleted[printerName] == nil {
+		a.deleted[printerName] = make(map[uint32]bool)
+	}
+	a.deleted[printerName][jobID] = true
+}
+
+// Observe devuelve los registros de los trabajos que terminaron desde el
+// sondeo anterior.
+func (a *printAccountant) Observe(printerName string, jobs []PrintJob, now time.Time) []PrintRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var records []PrintRecord
+	prev := a.printers[printerName]
+	deleted := a.deleted[printerName]
+	current := make(map[uint32]*accountedJob, len(jobs))
+
+	for _, job := range jobs {
+		st, ok := prev[job.JobID]
+		if !ok {
+			st = &accountedJob{}
+		}
+		st.job = job
+		if !st.recorded && !deleted[job.JobID] && job.Status&jobPrintedMask != 0 && job.Status&jobGoneDeletedMask == 0 {
+			records = append(records, newPrintRecord(job, now))
+			st.recorded = true
+		}
+		current[job.JobID] = st
+	}
+
+	for id, st := range prev {
+		if _, still := current[id]; still || st.recorded || deleted[id] || st.job.Status&jobGoneDeletedMask != 0 {
+			continue
+		}
+		if st.job.TotalPages > 0 && st.job.PagesPrinted >= st.job.TotalPages {
+			records = append(records, newPrintRecord(st.job, now))
+		}
+	}
+
+	// Las marcas de borrado solo hacen falta mientras el trabajo está en la
+	// cola.
+	for id := range deleted {
+		if _, still := current[id]; !still {
+			delete(deleted, id)
+		}
+	}
+
+	a.printers[printerName] = current
+	return records
+}
+
+// Forget descarta el estado de impresoras que ya no existen.
+func (a *printAccountant) Forget(present []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	forgetPrinters(a.printers, present)
+	forgetPrinters(a.deleted, present)
+}
+
+// newPrintRecord usa las páginas impresas si el driver las informa y si no el
+// total del documento.
+func newPrintRecord(job PrintJob, now time.Time) PrintRecord {
+	pages := job.PagesPrinted
+	if pages == 0 {
+		pages = job.TotalPages
+	}
+	return PrintRecord{
+		PrinterName: job.PrinterName,
+		JobID:       job.JobID,
+		Document:    job.Document,
+		User:        job.User,
+		Pages:       pages,
+		Color:       job.Color,
+		Duplex:      job.Duplex,
+		Submitted:   job.Submitted,
+		Completed:   now,
+	}
+}
+
+// printAccounting guarda los registros pendientes de reportar. Es nil si la
+// contabilidad está deshabilitada. No tiene límite de entradas: descartar
+// registros falsearía la facturación.
+var printAccounting *spool
+
+// startPrintAccounting abre el spool de registros e inicia el reporte
+// periódico si la contabilidad está habilitada.
+func startPrintAccounting(config Config) {
+	if !config.PrintAccounting.Enabled {
+		return
+	}
+	printAccounting = newUncappedSpool(config, "print-accounting")
+	safeGoRoutine("print accounting reporter", func() {
+		runPrintAccountingReporter(config)
+	})
+}
+
+// recordPrintedJobs persiste los registros para que no se pierdan si el
+// agente se reinicia antes del próximo reporte. Las reglas de privacidad se
+// aplican acá, antes de escribir en disco, y no de nuevo al enviar.
+func recordPrintedJobs(records []PrintRecord) {
+	if printAccounting == nil {
+		return
+	}
+	for _, r := range records {
+		payload, err := marshalReport(r)
+		if err != nil {
+			continue
+		}
+		printAccounting.Append(payload)
+	}
+}
+
+// runPrintAccountingReporter envía cada report_interval el uso acumulado. Los
+// registros solo se borran del disco cuando el servidor confirma el envío.
+func runPrintAccountingReporter(config Config) {
+	interval := defaultPrintAccountingInterval
+	if config.PrintAccounting.ReportInterval > 0 {
+		interval = time.Duration(config.PrintAccounting.ReportInterval) * time.Second
+	}
+	url := fmt.Sprintf("%s/api/%s/log/print-accounting", config.ServerURL, config.ServerVersion)
+
+	for {
+		time.Sleep(interval)
+		printAccounting.Drain(func(entries [][]byte) error {
+			records := make([]PrintRecord, 0, len(entries))
+			for _, entry := range entries {
+				var r PrintRecord
+				if err := json.Unmarshal(entry, &r); err != nil {
+					printerLog().Warn("Registro de impresión inválido, se descarta", "error", err)
+					continue
+				}
+				records = append(records, r)
+			}
+			if len(records) == 0 {
+				return nil
+			}
+
+			payload, err := json.Marshal(buildPrintUsageReport(records, time.Now()))
+			if err != nil {
+				return err
+			}
+			if err := postJSON(url, payload); err != nil {
+				printerLog().Warn("Error al enviar contabilidad de impresión", "error", err)
+				return err
+			}
+			printerLog().Info("Contabilidad de impresión enviada", "jobs", len(records))
+			return nil
+		})
+	}
+}
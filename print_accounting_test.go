@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPrintAccountantObserve(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	job := func(id, status, total, printed uint32) PrintJob {
+		return PrintJob{PrinterName: "HP", JobID: id, Status: status, TotalPages: total, PagesPrinted: printed}
+	}
+	const printing, printed, deleting = 0x00000010, 0x00000080, 0x00000004
+
+	a := newPrintAccountant()
+	steps := []struct {
+		name string
+		jobs []PrintJob
+		want []uint32
+	}{
+		{"en cola", []PrintJob{
+			job(1, printing, 3, 1), job(2, printing, 2, 2), job(3, 0, 0, 0),
+			job(4, printing, 5, 0), job(5, printing, 4, 4), job(6, printing, 1, 0),
+		}, nil},
+		// 1 queda retenido como impreso, 4 se está borrando y 6 lo borra el
+		// agente.
+		{"impreso retenido", []PrintJob{
+			job(1, printed, 3, 3), job(2, printing, 2, 2), job(3, 0, 0, 0),
+			job(4, deleting, 5, 0), job(5, printing, 4, 4), job(6, printing, 1, 0),
+		}, []uint32{1}},
+		// 2 y 5 salen con todas las páginas; 3 sale sin páginas informadas
+		// y 4 salió borrado.
+		{"salen de la cola", []PrintJob{job(1, printed, 3, 3), job(6, printing, 1, 0)}, []uint32{2, 5}},
+		{"borrado por el agente", []PrintJob{job(1, printed, 3, 3)}, nil},
+		{"retenido sale", nil, nil},
+	}
+	for i, s := range steps {
+		if i == 1 {
+			a.MarkDeleted("HP", 6)
+		}
+		records := a.Observe("HP", s.jobs, now)
+		got := map[uint32]bool{}
+		for _, r := range records {
+			got[r.JobID] = true
+			if r.Completed != now || r.PrinterName != "HP" {
+				t.Errorf("%s: registro %+v", s.name, r)
+			}
+		}
+		if len(got) != len(s.want) || len(records) != len(s.want) {
+			t.Errorf("%s: registros %v, want %v", s.name, got, s.want)
+			continue
+		}
+		for _, id := range s.want {
+			if !got[id] {
+				t.Errorf("%s: falta el trabajo %d (hay %v)", s.name, id, got)
+			}
+		}
+	}
+	if len(a.deleted["HP"]) != 0 {
+		t.Errorf("quedaron marcas de borrado %v", a.deleted["HP"])
+	}
+}
+
+func TestUncappedSpool(t *testing.T) {
+	config := Config{DataDir: t.TempDir()}
+	capped, uncapped := newSpool(config, "capped"), newUncappedSpool(config, "uncapped")
+	capped.maxEntries = 3
+	for i := 0; i < 5; i++ {
+		capped.Append([]byte{'a' + byte(i)})
+		uncapped.Append([]byte{'a' + byte(i)})
+	}
+	if got := capped.Peek(); len(got) != 3 || string(got[0]) != "c" {
+		t.Errorf("spool con límite: %q", got)
+	}
+	if got := uncapped.Peek(); len(got) != 5 || string(got[0]) != "a" {
+		t.Errorf("spool sin límite: %q", got)
+	}
+}
+
+// Append no espera a un envío en curso, y lo agregado durante el envío se
+// conserva al borrar lo enviado.
+func TestSpoolSendsWithoutLock(t *testing.T) {
+	s := newUncappedSpool(Config{DataDir: t.TempDir()}, "accounting")
+	s.Append([]byte("a"))
+	s.Append([]byte("b"))
+
+	appendDuringSend := func() {
+		done := make(chan struct{})
+		go func() {
+			s.Append([]byte("c"))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Append quedó bloqueado durante el envío")
+		}
+	}
+
+	s.Drain(func(entries [][]byte) error {
+		appendDuringSend()
+		return nil
+	})
+	if got := s.Peek(); len(got) != 1 || string(got[0]) != "c" {
+		t.Fatalf("después de Drain: %q", got)
+	}
+
+	s.Append([]byte("d"))
+	s.Flush(func(payload []byte) error {
+		if string(payload) == "d" {
+			return errors.New("servidor caído")
+		}
+		appendDuringSend()
+		return nil
+	})
+	if got := s.Peek(); len(got) != 2 || string(got[0]) != "d" || string(got[1]) != "c" {
+		t.Fatalf("después de Flush: %q", got)
+	}
+}
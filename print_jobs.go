@@ -0,0 +1,218 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PrintJob es un trabajo de la cola de impresión leído con JOB_INFO_2.
+type PrintJob struct {
+	PrinterName  string
+	JobID        uint32
+	Document     string
+	User         string
+	Status       uint32
+	StatusText   string
+	Position     uint32
+	TotalPages   uint32
+	PagesPrinted uint32
+	Color        string // color | mono, vacío si el driver no lo informa
+	Duplex       string // simplex | long_edge | short_edge
+	Submitted    time.Time
+}
+
+type StuckJobsConfig struct {
+	MaxAge        uint   `yaml:"max_age"`         // Segundos, 0 = sin límite
+	NoProgressFor uint   `yaml:"no_progress_for"` // Segundos, 0 = sin límite
+	Remediation   string `yaml:"remediation"`     // none | restart_job | delete_job | restart_spooler
+}
+
+type StuckJobReport struct {
+	AgentID      string  `json:"agent_id"`
+	Event        string  `json:"event"` // stuck_job
+	PrinterName  string  `json:"printer_name"`
+	JobID        uint32  `json:"job_id"`
+	Document     string  `json:"document"`
+	User         string  `json:"user"`
+	Position     uint32  `json:"position"`
+	TotalPages   uint32  `json:"total_pages"`
+	PagesPrinted uint32  `json:"pages_printed"`
+	Submitted    string  `json:"submitted"`
+	AgeSeconds   int64   `json:"age_seconds"`
+	Reason       string  `json:"reason"` // max_age | no_progress
+	Action       string  `json:"action"`
+	ActionResult string  `json:"action_result"` // ok | error | skipped
+	ActionError  string  `json:"action_error,omitempty"`
+	ActionReason *Reason `json:"action_reason,omitempty"`
+	Timestamp    string  `json:"timestamp"`
+}
+
+type jobProgress struct {
+	pages        uint32
+	lastProgress time.Time
+	reported     bool
+}
+
+// stuckJobDetector sigue el avance de cada trabajo entre sondeos. Un trabajo
+// se considera trabado una sola vez, para no repetir la remediación.
+type stuckJobDetector struct {
+	mu       sync.Mutex
+	printers map[string]map[uint32]*jobProgress
+}
+
+func newStuckJobDetector() *stuckJobDetector {
+	return &stuckJobDetector{printers: make(map[string]map[uint32]*jobProgress)}
+}
+
+// Observe registra los trabajos actuales de una impresora y devuelve los que
+// pasan a estar trabados, con el motivo.
+func (d *stuckJobDetector) Observe(printerName string, jobs []PrintJob, config StuckJobsConfig, now time.Time) map[uint32]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stuck := make(map[uint32]string)
+	prev := d.printers[printerName]
+	current := make(map[uint32]*jobProgress, len(jobs))
+
+	for _, job := range jobs {
+		p, ok := prev[job.JobID]
+		if !ok {
+			p = &jobProgress{pages: job.PagesPrinted, lastProgress: now}
+		} else if job.PagesPrinted != p.pages {
+			p.pages = job.PagesPrinted
+			p.lastProgress = now
+		}
+		current[job.JobID] = p
+		// Un trabajo ya impreso que sigue en la cola no está trabado.
+		if p.reported || job.Status&jobPrintedMask != 0 {
+			continue
+		}
+
+		switch {
+		case config.MaxAge > 0 && !job.Submitted.IsZero() && now.Sub(job.Submitted) > time.Duration(config.MaxAge)*time.Second:
+			stuck[job.JobID] = "max_age"
+		case config.NoProgressFor > 0 && job.Position == 1 && now.Sub(p.lastProgress) > time.Duration(config.NoProgressFor)*time.Second:
+			stuck[job.JobID] = "no_progress"
+		default:
+			continue
+		}
+		p.reported = true
+	}
+
+	// Los trabajos que ya no están en la cola se olvidan.
+	d.printers[printerName] = current
+	return stuck
+}
+
+// jobErrorMask agrupa los bits JOB_STATUS_* que indican un problema. Spooling,
+// printing y similares son estados normales y no se reportan.
+const jobErrorMask = 0x00000001 | 0x00000002 | 0x00000020 | 0x00000040 | 0x00000200 | 0x00000400
+
+// jobGoneDeletedMask indica que un trabajo salió de la cola porque se borró.
+const jobGoneDeletedMask = 0x00000004 | 0x00000100
+
+type jobErrorState struct {
+	job        PrintJob
+	errorBits  uint32
+	errorSince time.Time
+}
+
+// jobTransition es un cambio en el estado de error de un trabajo.
+type jobTransition struct {
+	Event      string // job_error | job_error_changed | job_cleared | job_completed | job_deleted
+	Job        PrintJob
+	Previous   uint32
+	ErrorSince time.Time
+}
+
+// jobErrorTracker recuerda qué trabajos están en error para reportar solo las
+// transiciones en lugar de repetir el mismo reporte en cada sondeo.
+type jobErrorTracker struct {
+	mu       sync.Mutex
+	printers map[string]map[uint32]*jobErrorState
+}
+
+func newJobErrorTracker() *jobErrorTracker {
+	return &jobErrorTracker{printers: make(map[string]map[uint32]*jobErrorState)}
+}
+
+// Observe compara los trabajos actuales de una impresora con el sondeo
+// anterior y devuelve las transiciones.
+func (t *jobErrorTracker) Observe(printerName string, jobs []PrintJob, now time.Time) []jobTransition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var transitions []jobTransition
+	prev := t.printers[printerName]
+	current := make(map[uint32]*jobErrorState)
+
+	for _, job := range jobs {
+		bits := job.Status & jobErrorMask
+		st, known := prev[job.JobID]
+
+		switch {
+		case !known && bits != 0:
+			st = &jobErrorState{errorSince: now}
+			transitions = append(transitions, jobTransition{Event: "job_error", Job: job, ErrorSince: now})
+		case known && bits == 0:
+			transitions = append(transitions, jobTransition{Event: "job_cleared", Job: job, Previous: st.job.Status, ErrorSince: st.errorSince})
+			continue
+		case known && bits != st.errorBits:
+			transitions = append(transitions, jobTransition{Event: "job_error_changed", Job: job, Previous: st.job.Status, ErrorSince: st.errorSince})
+		case !known:
+			continue
+		}
+		st.job = job
+		st.errorBits = bits
+		current[job.JobID] = st
+	}
+
+	for id, st := range prev {
+		if _, still := current[id]; still || jobInList(jobs, id) {
+			continue
+		}
+		event := "job_completed"
+		if st.job.Status&jobGoneDeletedMask != 0 {
+			event = "job_deleted"
+		}
+		transitions = append(transitions, jobTransition{Event: event, Job: st.job, Previous: st.job.Status, ErrorSince: st.errorSince})
+	}
+
+	t.printers[printerName] = current
+	return transitions
+}
+
+// Forget descarta el estado de impresoras que ya no existen.
+func (t *jobErrorTracker) Forget(present []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	forgetPrinters(t.printers, present)
+}
+
+// Forget descarta el estado de impresoras que ya no existen.
+func (d *stuckJobDetector) Forget(present []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	forgetPrinters(d.printers, present)
+}
+
+func forgetPrinters[T any](printers map[string]T, present []string) {
+	keep := make(map[string]bool, len(present))
+	for _, name := range present {
+		keep[name] = true
+	}
+	for name := range printers {
+		if !keep[name] {
+			delete(printers, name)
+		}
+	}
+}
+
+func jobInList(jobs []PrintJob, id uint32) bool {
+	for _, job := range jobs {
+		if job.JobID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStuckJobDetectorObserve(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := StuckJobsConfig{MaxAge: 3600, NoProgressFor: 60}
+	job := func(id, position, printed uint32, submitted time.Time) PrintJob {
+		return PrintJob{PrinterName: "HP", JobID: id, Position: position, PagesPrinted: printed, Submitted: submitted}
+	}
+	old := t0.Add(-2 * time.Hour)
+	const printed = 0x00000080
+
+	d := newStuckJobDetector()
+	steps := []struct {
+		name string
+		at   time.Duration
+		jobs []PrintJob
+		want map[uint32]string
+	}{
+		{"primer sondeo", 0, []PrintJob{job(1, 1, 0, t0), job(2, 2, 0, t0)}, map[uint32]string{}},
+		{"sin avance todavía", 30 * time.Second, []PrintJob{job(1, 1, 0, t0), job(2, 2, 0, t0)}, map[uint32]string{}},
+		{"sin avance", 90 * time.Second, []PrintJob{job(1, 1, 0, t0), job(2, 2, 0, t0)}, map[uint32]string{1: "no_progress"}},
+		{"se reporta una vez", 200 * time.Second, []PrintJob{job(1, 1, 0, t0), job(2, 2, 0, t0)}, map[uint32]string{}},
+		{"el segundo no está primero", 300 * time.Second, []PrintJob{job(2, 2, 0, t0)}, map[uint32]string{}},
+		{"primero y avanzando", 330 * time.Second, []PrintJob{job(2, 1, 1, t0)}, map[uint32]string{}},
+		{"sigue avanzando", 380 * time.Second, []PrintJob{job(2, 1, 2, t0)}, map[uint32]string{}},
+		{"demasiado antiguo", 400 * time.Second, []PrintJob{job(2, 1, 3, t0), job(3, 2, 0, old)}, map[uint32]string{3: "max_age"}},
+		{"impreso en la cola", 500 * time.Second, []PrintJob{{PrinterName: "HP", JobID: 4, Position: 1, Status: printed, Submitted: old}}, map[uint32]string{}},
+		{"vuelve con el mismo ID", 600 * time.Second, []PrintJob{job(1, 1, 0, t0)}, map[uint32]string{}},
+		{"y se vuelve a reportar", 700 * time.Second, []PrintJob{job(1, 1, 0, t0)}, map[uint32]string{1: "no_progress"}},
+	}
+	for _, step := range steps {
+		got := d.Observe("HP", step.jobs, policy, t0.Add(step.at))
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: Observe = %v, se esperaba %v", step.name, got, step.want)
+		}
+	}
+
+	d.Forget(nil)
+	if len(d.printers) != 0 {
+		t.Errorf("Forget dejó %d impresoras", len(d.printers))
+	}
+}
+
+// fakeBackend registra las acciones de remediación.
+type fakeBackend struct {
+	PrinterBackend
+	mu         sync.Mutex
+	controls   [][2]uint32 // trabajo y comando
+	restarts   int
+	controlErr error
+}
+
+func (b *fakeBackend) ControlJob(printerName string, jobID uint32, command uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.controls = append(b.controls, [2]uint32{jobID, command})
+	return b.controlErr
+}
+
+func (b *fakeBackend) RestartService() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.restarts++
+	return nil
+}
+
+// useFakeBackend reemplaza el backend y los rastreadores globales durante
+// la prueba.
+func useFakeBackend(t *testing.T, b *fakeBackend) {
+	printerBackendMu.Lock()
+	prev := printerBackend
+	printerBackend = b
+	printerBackendMu.Unlock()
+	prevStuck, prevAccountant := stuckJobs, accountant
+	stuckJobs, accountant = newStuckJobDetector(), newPrintAccountant()
+	t.Cleanup(func() {
+		printerBackendMu.Lock()
+		printerBackend = prev
+		printerBackendMu.Unlock()
+		stuckJobs, accountant = prevStuck, prevAccountant
+	})
+}
+
+func TestCheckStuckJobsRemediation(t *testing.T) {
+	var mu sync.Mutex
+	var reports []StuckJobReport
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report StuckJobReport
+		json.NewDecoder(r.Body).Decode(&report)
+		mu.Lock()
+		reports = append(reports, report)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	config := Config{ServerURL: srv.URL, ServerVersion: "v1"}
+
+	old := time.Now().Add(-2 * time.Hour)
+	jobs := []PrintJob{
+		{PrinterName: "HP", JobID: 7, Position: 1, Submitted: old},
+		{PrinterName: "HP", JobID: 8, Position: 2, Submitted: old},
+	}
+
+	tests := []struct {
+		remediation  string
+		controlErr   error
+		wantControls [][2]uint32
+		wantRestarts int
+		wantAction   string
+		wantResult   string
+	}{
+		{"", nil, nil, 0, "none", "skipped"},
+		{"restart_job", nil, [][2]uint32{{7, jobControlRestart}, {8, jobControlRestart}}, 0, "restart_job", "ok"},
+		{"delete_job", nil, [][2]uint32{{7, jobControlDelete}, {8, jobControlDelete}}, 0, "delete_job", "ok"},
+		{"restart_spooler", nil, nil, 1, "restart_spooler", "ok"},
+		{"restart_job", errors.New("acceso denegado"), [][2]uint32{{7, jobControlRestart}, {8, jobControlRestart}}, 0, "restart_job", "error"},
+	}
+	for _, tt := range tests {
+		b := &fakeBackend{controlErr: tt.controlErr}
+		useFakeBackend(t, b)
+		mu.Lock()
+		reports = nil
+		mu.Unlock()
+
+		policy := StuckJobsConfig{MaxAge: 3600, Remediation: tt.remediation}
+		checkStuckJobs(config, "HP", jobs, policy)
+		// Un trabajo ya reportado no se vuelve a remediar.
+		checkStuckJobs(config, "HP", jobs, policy)
+
+		if !reflect.DeepEqual(b.controls, tt.wantControls) {
+			t.Errorf("%s: ControlJob = %v, se esperaba %v", tt.wantAction, b.controls, tt.wantControls)
+		}
+		if b.restarts != tt.wantRestarts {
+			t.Errorf("%s: RestartService llamado %d veces, se esperaba %d", tt.wantAction, b.restarts, tt.wantRestarts)
+		}
+		mu.Lock()
+		if len(reports) != len(jobs) {
+			t.Errorf("%s: %d reportes, se esperaban %d", tt.wantAction, len(reports), len(jobs))
+		}
+		for _, r := range reports {
+			if r.Event != "stuck_job" || r.Reason != "max_age" || r.Action != tt.wantAction || r.ActionResult != tt.wantResult {
+				t.Errorf("%s: reporte %+v", tt.wantAction, r)
+			}
+			if (tt.controlErr != nil) != (r.ActionReason != nil) {
+				t.Errorf("%s: action_reason = %v", tt.wantAction, r.ActionReason)
+			}
+		}
+		mu.Unlock()
+		if tt.remediation == "delete_job" && !(accountant.deleted["HP"][7] && accountant.deleted["HP"][8]) {
+			t.Errorf("delete_job no marcó los trabajos como borrados: %v", accountant.deleted["HP"])
+		}
+	}
+}
+
+func TestJobErrorTrackerObserve(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const (
+		paused     = 0x00000001
+		jobError   = 0x00000002
+		deleting   = 0x00000004
+		printing   = 0x00000010
+		offline    = 0x00000020
+		userInterv = 0x00000400
+	)
+	job := func(id, status uint32) PrintJob {
+		return PrintJob{PrinterName: "HP", JobID: id, Status: status}
+	}
+	type want struct {
+		event    string
+		id       uint32
+		previous uint32
+		since    time.Duration
+	}
+
+	tr := newJobErrorTracker()
+	steps := []struct {
+		name string
+		at   time.Duration
+		jobs []PrintJob
+		want []want
+	}{
+		{"sin errores", 0, []PrintJob{job(1, printing), job(2, 0), job(3, 0)}, nil},
+		{"entra en error", time.Minute, []PrintJob{job(1, printing|offline), job(2, paused), job(3, 0)},
+			[]want{{"job_error", 1, 0, time.Minute}, {"job_error", 2, 0, time.Minute}}},
+		{"mismo error", 2 * time.Minute, []PrintJob{job(1, offline), job(2, paused), job(3, 0)}, nil},
+		{"cambia el error", 3 * time.Minute, []PrintJob{job(1, offline|userInterv), job(2, paused), job(3, jobError)},
+			[]want{{"job_error_changed", 1, offline, time.Minute}, {"job_error", 3, 0, 3 * time.Minute}}},
+		{"se recupera", 4 * time.Minute, []PrintJob{job(1, printing), job(2, paused|deleting), job(3, jobError)},
+			[]want{{"job_cleared", 1, offline | userInterv, time.Minute}}},
+		{"recuperado no se repite", 5 * time.Minute, []PrintJob{job(2, paused|deleting), job(3, jobError)}, nil},
+		{"sale de la cola", 6 * time.Minute, nil,
+			[]want{{"job_deleted", 2, paused | deleting, time.Minute}, {"job_completed", 3, jobError, 3 * time.Minute}}},
+		{"cola vacía", 7 * time.Minute, nil, nil},
+	}
+	for _, step := range steps {
+		var got []want
+		for _, tr := range tr.Observe("HP", step.jobs, t0.Add(step.at)) {
+			got = append(got, want{tr.Event, tr.Job.JobID, tr.Previous, tr.ErrorSince.Sub(t0)})
+		}
+		// Las salidas de cola se recorren sobre un mapa.
+		sort.Slice(got, func(i, j int) bool { return got[i].id < got[j].id })
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: Observe = %+v, se esperaba %+v", step.name, got, step.want)
+		}
+	}
+
+	tr.Forget([]string{"Otra"})
+	if len(tr.printers) != 0 {
+		t.Errorf("Forget dejó %d impresoras", len(tr.printers))
+	}
+}
@@ -1,209 +1,256 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
-	"syscall"
 	"time"
-	"unsafe"
-
-	"github.com/alexbrainman/printer"
-	"golang.org/x/sys/windows"
 )
 
-var (
-	winspool         = windows.NewLazySystemDLL("winspool.drv")
-	procOpenPrinter  = winspool.NewProc("OpenPrinterW")
-	procEnumJobs     = winspool.NewProc("EnumJobsW")
-	procClosePrinter = winspool.NewProc("ClosePrinter")
+const (
+	jobControlPause   = 1
+	jobControlResume  = 2
+	jobControlCancel  = 3
+	jobControlRestart = 4
+	jobControlDelete  = 5
 )
 
-type JobInfo1 struct {
-	JobID        uint32
-	pPrinterName *uint16
-	pMachineName *uint16
-	pUserName    *uint16
-	pDocument    *uint16
-	pDatatype    *uint16
-	pStatus      *uint16
-	Status       uint32
-	Priority     uint32
-	Position     uint32
-	TotalPages   uint32
-	PagesPrinted uint32
-	Submitted    windows.Systemtime
-}
+// printerStates guarda el último estado de cada impresora entre sondeos.
+var printerStates = newPrinterStatusTracker()
+
+// jobErrors guarda qué trabajos están en error entre sondeos.
+var jobErrors = newJobErrorTracker()
 
 type PrinterIssueReport struct {
-	PrinterName string `json:"printer_name"`
-	Document    string `json:"document"`
-	User        string `json:"user"`
-	StatusCode  uint32 `json:"status_code"`
-	Timestamp   string `json:"timestamp"`
+	AgentID       string   `json:"agent_id"`
+	Event         string   `json:"event"` // job_error | job_error_changed | job_cleared | job_completed | job_deleted
+	PrinterName   string   `json:"printer_name"`
+	JobID         uint32   `json:"job_id"`
+	Document      string   `json:"document"`
+	User          string   `json:"user"`
+	StatusCode    uint32   `json:"status_code"`
+	StatusFlags   []string `json:"status_flags"`
+	StatusText    string   `json:"status_text,omitempty"` // pStatus del driver
+	PreviousFlags []string `json:"previous_flags,omitempty"`
+	ErrorSince    string   `json:"error_since"`
+	ErrorSeconds  int64    `json:"error_duration_seconds"`
+	Timestamp     string   `json:"timestamp"`
 }
 
-func utf16Ptr(s string) *uint16 {
-	ptr, _ := syscall.UTF16PtrFromString(s)
-	return ptr
+func sendPrinterIssueReport(config Config, report PrinterIssueReport) {
+	sendPrinterPayload(config, report)
 }
 
-func sendPrinterIssueReport(config Config, report PrinterIssueReport) {
+// sendPrinterPayload envía cualquier evento de impresora a /log/printer.
+func sendPrinterPayload(config Config, report interface{}) {
 	if config.ServerURL == "" || config.ServerVersion == "" {
-		log.Println("⚠️ Configuración incompleta: faltan ServerURL o ServerVersion.")
+		printerLog().Warn("Configuración incompleta: faltan ServerURL o ServerVersion.")
 		return
 	}
 
-	jsonData, err := json.Marshal(report)
+	jsonData, err := marshalReport(report)
 	if err != nil {
-		log.Printf("❌ Error serializando reporte de impresora: %v", err)
+		printerLog().Error("Error serializando reporte de impresora", "error", err)
 		return
 	}
 
-	resp, err := http.Post(fmt.Sprintf("%s/api/%s/log/printer", config.ServerURL, config.ServerVersion), "application/json", bytes.NewBuffer(jsonData))
+	if err := postJSON(fmt.Sprintf("%s/api/%s/log/printer", config.ServerURL, config.ServerVersion), jsonData); err != nil {
+		logSendError(printerLog(), "printer", err, jsonData)
+	}
+}
+
+// checkPrinterStatus reporta los cambios de estado de la impresora aunque su
+// cola esté vacía (sin papel, atascada, fuera de línea).
+func checkPrinterStatus(config Config, printerName string) {
+	info, err := currentPrinterBackend().PrinterInfo(printerName)
 	if err != nil {
-		log.Printf("❌ Error enviando reporte de impresora: %v", err)
+		printerLog().Warn("Error al leer estado de impresora", "printer", printerName, "error", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("⚠️ El servidor respondió con código: %d", resp.StatusCode)
+	if report := printerStates.Update(info, time.Now()); report != nil {
+		printerLog().Info("Cambio de estado", "printer", printerName, "flags", report.StatusFlags, "previous", report.PreviousFlags)
+		sendPrinterPayload(config, report)
 	}
 }
 
-func checkPrinterQueue(config Config, printerName string) []PrinterIssueReport {
+func checkPrinterQueue(config Config, printerName string, settings printerSettings) []PrinterIssueReport {
 	var reports []PrinterIssueReport
 
-	var hPrinter uintptr
-	ret, _, _ := procOpenPrinter.Call(
-		uintptr(unsafe.Pointer(utf16Ptr(printerName))),
-		uintptr(unsafe.Pointer(&hPrinter)),
-		0,
-	)
-	if ret == 0 || hPrinter == 0 {
-		log.Printf("❌ No se pudo abrir la impresora '%s'", printerName)
+	jobs, err := currentPrinterBackend().Jobs(printerName)
+	if err != nil {
+		printerLog().Error("Error al leer la cola", "printer", printerName, "error", err)
 		return reports
 	}
-	defer procClosePrinter.Call(hPrinter)
-
-	var needed, returned uint32
-	procEnumJobs.Call(hPrinter, 0, 10, 1, 0, 0, uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
-
-	if needed == 0 {
-		return reports
+	if !settings.CaptureDocumentNames {
+		for i := range jobs {
+			jobs[i].Document = ""
+		}
 	}
 
-	buf := make([]byte, needed)
-	ret, _, _ = procEnumJobs.Call(
-		hPrinter, 0, 10, 1,
-		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed),
-		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)),
-	)
-
-	if ret == 0 {
-		log.Printf("⚠️ No se pudieron leer trabajos para '%s'", printerName)
-		return reports
+	for _, job := range jobs {
+		fmt.Printf(tr("🖨️ Impresora: %s\n"), printerName)
+		fmt.Printf(tr("   📄 Documento: %s\n"), redactValue("document", job.Document))
+		fmt.Printf(tr("   👤 Usuario: %s\n"), redactValue("user", job.User))
+		fmt.Printf(tr("   🛑 Estado: 0x%X %v %s\n"), job.Status, decodeFlags(job.Status, jobStatusFlags), job.StatusText)
+		if job.Status&jobErrorMask != 0 {
+			fmt.Println(tr("   🚨 Hay un problema con el trabajo de impresión."))
+		}
 	}
 
-	entrySize := int(unsafe.Sizeof(JobInfo1{}))
-	count := int(returned)
-
-	for i := 0; i < count; i++ {
-		job := (*JobInfo1)(unsafe.Pointer(&buf[i*entrySize]))
-		document := windows.UTF16PtrToString(job.pDocument)
-		user := windows.UTF16PtrToString(job.pUserName)
-		status := job.Status
-
-		fmt.Printf("🖨️ Impresora: %s\n", printerName)
-		fmt.Printf("   📄 Documento: %s\n", document)
-		fmt.Printf("   👤 Usuario: %s\n", user)
-		fmt.Printf("   🛑 Estado: 0x%X\n", status)
-
-		if status != 0 {
-			fmt.Printf("   🚨 Hay un problema con el trabajo de impresión.\n")
-			report := PrinterIssueReport{
-				PrinterName: printerName,
-				Document:    document,
-				User:        user,
-				StatusCode:  status,
-				Timestamp:   time.Now().Format(time.RFC3339),
-			}
-			reports = append(reports, report)
-			sendPrinterIssueReport(config, report)
+	// Solo se envían los cambios: el trabajo entra en error, cambia de error,
+	// se recupera o sale de la cola.
+	now := time.Now()
+	for _, tr := range jobErrors.Observe(printerName, jobs, now) {
+		report := PrinterIssueReport{
+			AgentID:      agentID,
+			Event:        tr.Event,
+			PrinterName:  printerName,
+			JobID:        tr.Job.JobID,
+			Document:     tr.Job.Document,
+			User:         tr.Job.User,
+			StatusCode:   tr.Job.Status,
+			StatusFlags:  decodeFlags(tr.Job.Status, jobStatusFlags),
+			StatusText:   tr.Job.StatusText,
+			ErrorSince:   tr.ErrorSince.Format(time.RFC3339),
+			ErrorSeconds: int64(now.Sub(tr.ErrorSince).Seconds()),
+			Timestamp:    now.Format(time.RFC3339),
 		}
+		if tr.Event != "job_error" {
+			report.PreviousFlags = decodeFlags(tr.Previous, jobStatusFlags)
+		}
+		reports = append(reports, report)
+		sendPrinterIssueReport(config, report)
 	}
 
+	checkStuckJobs(config, printerName, jobs, settings.StuckJobs)
+	recordPrintedJobs(accountant.Observe(printerName, jobs, now))
 	return reports
 }
 
-func ensureSpoolerRunning() {
-	m, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
-	if err != nil {
-		log.Printf("❌ No se pudo abrir el administrador de servicios: %v", err)
-		return
-	}
-	defer windows.CloseServiceHandle(m)
+// stuckJobs sigue el avance de los trabajos entre sondeos.
+var stuckJobs = newStuckJobDetector()
 
-	serviceName := syscall.StringToUTF16Ptr("Spooler")
-	h, err := windows.OpenService(m, serviceName, windows.SERVICE_QUERY_STATUS|windows.SERVICE_START)
-	if err != nil {
-		log.Printf("❌ No se pudo abrir el servicio 'Spooler': %v", err)
+// accountant detecta los trabajos terminados para la contabilidad.
+var accountant = newPrintAccountant()
+
+// pollSchedule respeta el poll_interval de cada impresora.
+var pollSchedule = newPrinterSchedule()
+
+// checkStuckJobs aplica la remediación configurada a los trabajos que
+// quedaron trabados y reporta cada acción.
+func checkStuckJobs(config Config, printerName string, jobs []PrintJob, policy StuckJobsConfig) {
+	now := time.Now()
+	stuck := stuckJobs.Observe(printerName, jobs, policy, now)
+	if len(stuck) == 0 {
 		return
 	}
-	defer windows.CloseServiceHandle(h)
 
-	var status windows.SERVICE_STATUS
-	err = windows.QueryServiceStatus(h, &status)
-	if err != nil {
-		log.Printf("❌ No se pudo consultar el estado del servicio 'Spooler': %v", err)
-		return
+	action := policy.Remediation
+	if action == "" {
+		action = "none"
 	}
+	backend := currentPrinterBackend()
+	var spoolerErr error
+	spoolerRestarted := false
 
-	if status.CurrentState != windows.SERVICE_RUNNING {
-		log.Println("🛠️ El servicio 'Spooler' está detenido. Intentando iniciarlo...")
-		err = windows.StartService(h, 0, nil)
-		if err != nil {
-			log.Printf("❌ No se pudo iniciar el servicio 'Spooler': %v", err)
-			return
+	for _, job := range jobs {
+		reason, ok := stuck[job.JobID]
+		if !ok {
+			continue
 		}
 
-		// Esperar hasta que el servicio esté corriendo
-		for i := 0; i < 10; i++ {
-			err = windows.QueryServiceStatus(h, &status)
-			if err != nil {
-				log.Printf("⚠️ Error al consultar estado del servicio: %v", err)
-				break
-			}
-			if status.CurrentState == windows.SERVICE_RUNNING {
-				log.Println("✅ Servicio 'Spooler' está corriendo.")
-				break
+		var err error
+		result := "ok"
+		switch action {
+		case "restart_job":
+			err = backend.ControlJob(printerName, job.JobID, jobControlRestart)
+		case "delete_job":
+			accountant.MarkDeleted(printerName, job.JobID)
+			err = backend.ControlJob(printerName, job.JobID, jobControlDelete)
+		case "restart_spooler":
+			// Un reinicio alcanza para todos los trabajos de este sondeo.
+			if !spoolerRestarted {
+				spoolerErr = backend.RestartService()
+				spoolerRestarted = true
 			}
-			time.Sleep(500 * time.Millisecond)
+			err = spoolerErr
+		default:
+			result = "skipped"
+		}
+		if err != nil {
+			result = "error"
+		}
+
+		printerLog().Warn("Trabajo trabado", "printer", printerName, "job_id", job.JobID, "reason", reason, "action", action, "result", result)
+		report := StuckJobReport{
+			AgentID:      agentID,
+			Event:        "stuck_job",
+			PrinterName:  printerName,
+			JobID:        job.JobID,
+			Document:     job.Document,
+			User:         job.User,
+			Position:     job.Position,
+			TotalPages:   job.TotalPages,
+			PagesPrinted: job.PagesPrinted,
+			Submitted:    job.Submitted.Format(time.RFC3339),
+			AgeSeconds:   int64(now.Sub(job.Submitted).Seconds()),
+			Reason:       reason,
+			Action:       action,
+			ActionResult: result,
+			Timestamp:    now.Format(time.RFC3339),
 		}
+		if err != nil {
+			reason := reasonOf(err, reasonPrinterActionFailed)
+			report.ActionError = reason.Error()
+			report.ActionReason = &reason
+		}
+		sendPrinterPayload(config, report)
 	}
 }
 
 func InitializePrinterDetection(config Config) []PrinterIssueReport {
 	var reports []PrinterIssueReport
 
-	ensureSpoolerRunning()
+	now := time.Now()
+	for _, name := range listMonitoredPrinters(config) {
+		settings := config.printerSettings(name)
+		if !pollSchedule.Due(name, settings.PollInterval, now) {
+			continue
+		}
+		reports = append(reports, checkPrinter(config, name, settings)...)
+	}
+
+	return reports
+}
+
+// listMonitoredPrinters devuelve las impresoras que pasan los filtros y
+// descarta el estado de las que ya no están.
+func listMonitoredPrinters(config Config) []string {
+	backend := currentPrinterBackend()
+	if err := backend.EnsureRunning(); err != nil {
+		printerLog().Error("El servicio de impresión no está corriendo", "backend", backend.Name(), "error", err)
+		return nil
+	}
 
-	names, err := printer.ReadNames()
+	names, err := backend.ListPrinters()
 	if err != nil {
-		log.Printf("❌ Error al leer nombres de impresoras: %v", err)
-		return reports
+		printerLog().Error("Error al leer nombres de impresoras", "error", err)
+		return nil
 	}
 	if len(names) == 0 {
-		log.Println("⚠️ No se encontraron impresoras instaladas.")
-		return reports
+		printerLog().Warn("No se encontraron impresoras instaladas.")
 	}
 
-	for _, name := range names {
-		reports = append(reports, checkPrinterQueue(config, name)...)
-	}
+	// Las impresoras excluidas se tratan como si no existieran.
+	names = config.Printers.filter(names)
+	printerStates.Forget(names)
+	jobErrors.Forget(names)
+	stuckJobs.Forget(names)
+	accountant.Forget(names)
+	pollSchedule.Forget(names)
+	return names
+}
 
-	return reports
+// checkPrinter revisa el estado y la cola de una impresora.
+func checkPrinter(config Config, printerName string, settings printerSettings) []PrinterIssueReport {
+	checkPrinterStatus(config, printerName)
+	return checkPrinterQueue(config, printerName, settings)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+)
+
+// PrinterBackend abstrae el sistema de impresión del equipo. Los backends
+// traducen sus estados a los bits PRINTER_STATUS_* y JOB_STATUS_* de
+// winspool, así los trackers y los reportes son los mismos para todos.
+type PrinterBackend interface {
+	Name() string
+	// EnsureRunning intenta levantar el servicio de impresión si está caído y
+	// devuelve error si no lo logra.
+	EnsureRunning() error
+	ListPrinters() ([]string, error)
+	PrinterInfo(printerName string) (PrinterInfo, error)
+	Jobs(printerName string) ([]PrintJob, error)
+	// ControlJob aplica un comando jobControl* a un trabajo.
+	ControlJob(printerName string, jobID uint32, command uint32) error
+	RestartService() error
+}
+
+var (
+	printerBackendMu sync.Mutex
+	printerBackend   = defaultPrinterBackend(Config{})
+	// printerBackendSet guarda la configuración del backend activo para no
+	// reemplazarlo cuando se vuelve a configurar con los mismos valores.
+	printerBackendSet *printerBackendConfig
+)
+
+type printerBackendConfig struct {
+	name string
+	ipp  IPPConfig
+}
+
+// configurePrinterBackend elige el backend según printer_backend. Si el valor
+// es desconocido o no existe en este sistema se usa el predeterminado:
+// winspool en Windows e ipp en Linux.
+func configurePrinterBackend(config Config) {
+	set := &printerBackendConfig{name: config.PrinterBackend, ipp: config.IPP}
+	printerBackendMu.Lock()
+	unchanged := printerBackendSet != nil && *printerBackendSet == *set
+	printerBackendMu.Unlock()
+	if unchanged {
+		return
+	}
+
+	var backend PrinterBackend
+	switch config.PrinterBackend {
+	case "":
+		backend = defaultPrinterBackend(config)
+	case "winspool":
+		var err error
+		if backend, err = newWinspoolBackend(); err != nil {
+			backend = defaultPrinterBackend(config)
+			printerLog().Warn("printer_backend no disponible, se usa el predeterminado", "backend", config.PrinterBackend, "error", err, "used", backend.Name())
+		}
+	case "ipp", "cups":
+		backend = newIPPBackend(config.IPP)
+	default:
+		backend = defaultPrinterBackend(config)
+		printerLog().Warn("printer_backend desconocido, se usa el predeterminado", "backend", config.PrinterBackend, "used", backend.Name())
+	}
+
+	printerBackendMu.Lock()
+	printerBackend = backend
+	printerBackendSet = set
+	printerBackendMu.Unlock()
+	printerLog().Info("Backend de impresión", "backend", backend.Name())
+}
+
+func currentPrinterBackend() PrinterBackend {
+	printerBackendMu.Lock()
+	defer printerBackendMu.Unlock()
+	return printerBackend
+}
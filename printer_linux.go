@@ -0,0 +1,12 @@
+package main
+
+import "errors"
+
+func newWinspoolBackend() (PrinterBackend, error) {
+	return nil, errors.New("winspool solo existe en Windows")
+}
+
+// defaultPrinterBackend usa CUPS por IPP, con la dirección de ipp.
+func defaultPrinterBackend(config Config) PrinterBackend {
+	return newIPPBackend(config.IPP)
+}
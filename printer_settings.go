@@ -0,0 +1,171 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultPrinterPollInterval = 30 * time.Second
+
+// PrintersConfig define qué impresoras locales se monitorean y con qué
+// parámetros. Los patrones usan la sintaxis de filepath.Match sin distinguir
+// mayúsculas.
+type PrintersConfig struct {
+	Include              []string          `yaml:"include"` // Vacío = todas
+	Exclude              []string          `yaml:"exclude"`
+	Mode                 string            `yaml:"mode"`                 // notify | poll
+	NotifyPollInterval   uint              `yaml:"notify_poll_interval"` // Segundos entre sondeos completos en modo notify
+	PollInterval         uint              `yaml:"poll_interval"`        // Segundos
+	CaptureDocumentNames *bool             `yaml:"capture_document_names"`
+	Overrides            []PrinterOverride `yaml:"overrides"`
+}
+
+// PrinterOverride cambia los parámetros de las impresoras que coinciden con
+// Match. Se aplica solo el primero que coincide.
+type PrinterOverride struct {
+	Match                string             `yaml:"match"`
+	PollInterval         uint               `yaml:"poll_interval"` // Segundos
+	StuckJobs            *StuckJobsOverride `yaml:"stuck_jobs"`
+	CaptureDocumentNames *bool              `yaml:"capture_document_names"`
+}
+
+// StuckJobsOverride cambia solo los campos de stuck_jobs que están presentes;
+// los demás se heredan de la configuración global. Un 0 explícito desactiva
+// el límite.
+type StuckJobsOverride struct {
+	MaxAge        *uint   `yaml:"max_age"`
+	NoProgressFor *uint   `yaml:"no_progress_for"`
+	Remediation   *string `yaml:"remediation"`
+}
+
+func (o StuckJobsOverride) apply(base StuckJobsConfig) StuckJobsConfig {
+	if o.MaxAge != nil {
+		base.MaxAge = *o.MaxAge
+	}
+	if o.NoProgressFor != nil {
+		base.NoProgressFor = *o.NoProgressFor
+	}
+	if o.Remediation != nil {
+		base.Remediation = *o.Remediation
+	}
+	return base
+}
+
+// printerSettings son los parámetros efectivos de una impresora.
+type printerSettings struct {
+	PollInterval         time.Duration
+	StuckJobs            StuckJobsConfig
+	CaptureDocumentNames bool
+}
+
+func matchPrinterPattern(pattern, name string) bool {
+	ok, err := filepath.Match(strings.ToLower(pattern), strings.ToLower(name))
+	if err != nil {
+		printerLog().Warn("Patrón de impresora inválido", "pattern", pattern, "error", err)
+		return false
+	}
+	return ok
+}
+
+func matchAnyPrinterPattern(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchPrinterPattern(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// monitored indica si la impresora pasa los filtros include y exclude.
+func (c PrintersConfig) monitored(name string) bool {
+	if len(c.Include) > 0 && !matchAnyPrinterPattern(c.Include, name) {
+		return false
+	}
+	return !matchAnyPrinterPattern(c.Exclude, name)
+}
+
+// filter devuelve las impresoras monitoreadas, en el mismo orden.
+func (c PrintersConfig) filter(names []string) []string {
+	var out []string
+	for _, name := range names {
+		if c.monitored(name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// printerSettings combina los valores globales con el override que coincide.
+func (c Config) printerSettings(name string) printerSettings {
+	s := printerSettings{
+		PollInterval:         defaultPrinterPollInterval,
+		StuckJobs:            c.StuckJobs,
+		CaptureDocumentNames: true,
+	}
+	if c.Printers.PollInterval > 0 {
+		s.PollInterval = time.Duration(c.Printers.PollInterval) * time.Second
+	}
+	if c.Printers.CaptureDocumentNames != nil {
+		s.CaptureDocumentNames = *c.Printers.CaptureDocumentNames
+	}
+
+	for _, o := range c.Printers.Overrides {
+		if !matchPrinterPattern(o.Match, name) {
+			continue
+		}
+		if o.PollInterval > 0 {
+			s.PollInterval = time.Duration(o.PollInterval) * time.Second
+		}
+		if o.StuckJobs != nil {
+			s.StuckJobs = o.StuckJobs.apply(s.StuckJobs)
+		}
+		if o.CaptureDocumentNames != nil {
+			s.CaptureDocumentNames = *o.CaptureDocumentNames
+		}
+		break
+	}
+	return s
+}
+
+// tick es cada cuánto revisa el monitor de impresoras si alguna debe
+// sondearse: el menor de los intervalos configurados.
+func (c PrintersConfig) tick() time.Duration {
+	tick := defaultPrinterPollInterval
+	if c.PollInterval > 0 {
+		tick = time.Duration(c.PollInterval) * time.Second
+	}
+	for _, o := range c.Overrides {
+		if d := time.Duration(o.PollInterval) * time.Second; o.PollInterval > 0 && d < tick {
+			tick = d
+		}
+	}
+	return tick
+}
+
+// printerSchedule recuerda el último sondeo de cada impresora para respetar
+// los intervalos por impresora.
+type printerSchedule struct {
+	last map[string]time.Time
+}
+
+func newPrinterSchedule() *printerSchedule {
+	return &printerSchedule{last: make(map[string]time.Time)}
+}
+
+// Due indica si corresponde sondear la impresora y, si es así, registra el
+// sondeo.
+func (s *printerSchedule) Due(name string, interval time.Duration, now time.Time) bool {
+	// Se descuenta un segundo para que un tick que llega apenas antes del
+	// intervalo no saltee la impresora hasta el siguiente.
+	if last, ok := s.last[name]; ok && now.Sub(last) < interval-time.Second {
+		return false
+	}
+	s.last[name] = now
+	return true
+}
+
+// Forget descarta el estado de impresoras que ya no existen.
+func (s *printerSchedule) Forget(present []string) {
+	forgetPrinters(s.last, present)
+}
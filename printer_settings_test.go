@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestPrinterSettingsStuckJobsMerge(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+stuck_jobs:
+  max_age: 3600
+  no_progress_for: 600
+  remediation: "restart_job"
+printers:
+  poll_interval: 20
+  overrides:
+    - match: "HP*"
+      stuck_jobs:
+        remediation: "delete_job"
+    - match: "Zebra*"
+      poll_interval: 5
+      stuck_jobs:
+        max_age: 0
+    - match: "*"
+      capture_document_names: false
+`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		printer string
+		want    printerSettings
+	}{
+		{"HP LaserJet", printerSettings{20 * time.Second, StuckJobsConfig{3600, 600, "delete_job"}, true}},
+		{"Zebra ZD420", printerSettings{5 * time.Second, StuckJobsConfig{0, 600, "restart_job"}, true}},
+		{"Brother", printerSettings{20 * time.Second, StuckJobsConfig{3600, 600, "restart_job"}, false}},
+	}
+	for _, tt := range tests {
+		if got := config.printerSettings(tt.printer); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.printer, got, tt.want)
+		}
+	}
+}
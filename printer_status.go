@@ -0,0 +1,173 @@
+package main
+
+import "time"
+
+// printerFlag asocia un bit de una máscara de Windows con su nombre en los
+// payloads.
+type printerFlag struct {
+	bit  uint32
+	name string
+}
+
+// PRINTER_STATUS_* de winspool.h.
+var printerStatusFlags = []printerFlag{
+	{0x00000001, "paused"},
+	{0x00000002, "error"},
+	{0x00000004, "pending_deletion"},
+	{0x00000008, "paper_jam"},
+	{0x00000010, "paper_out"},
+	{0x00000020, "manual_feed"},
+	{0x00000040, "paper_problem"},
+	{0x00000080, "offline"},
+	{0x00000100, "io_active"},
+	{0x00000200, "busy"},
+	{0x00000400, "printing"},
+	{0x00000800, "output_bin_full"},
+	{0x00001000, "not_available"},
+	{0x00002000, "waiting"},
+	{0x00004000, "processing"},
+	{0x00008000, "initializing"},
+	{0x00010000, "warming_up"},
+	{0x00020000, "toner_low"},
+	{0x00040000, "no_toner"},
+	{0x00080000, "page_punt"},
+	{0x00100000, "user_intervention"},
+	{0x00200000, "out_of_memory"},
+	{0x00400000, "door_open"},
+	{0x00800000, "server_unknown"},
+	{0x01000000, "power_save"},
+	{0x02000000, "server_offline"},
+	{0x04000000, "driver_update_needed"},
+}
+
+// PRINTER_ATTRIBUTE_* de winspool.h.
+var printerAttributeFlags = []printerFlag{
+	{0x00000001, "queued"},
+	{0x00000002, "direct"},
+	{0x00000004, "default"},
+	{0x00000008, "shared"},
+	{0x00000010, "network"},
+	{0x00000020, "hidden"},
+	{0x00000040, "local"},
+	{0x00000080, "enable_devq"},
+	{0x00000100, "keep_printed_jobs"},
+	{0x00000200, "do_complete_first"},
+	{0x00000400, "work_offline"},
+	{0x00000800, "enable_bidi"},
+	{0x00001000, "raw_only"},
+	{0x00002000, "published"},
+	{0x00004000, "fax"},
+	{0x00008000, "ts"},
+}
+
+// JOB_STATUS_* de winspool.h.
+var jobStatusFlags = []printerFlag{
+	{0x00000001, "paused"},
+	{0x00000002, "error"},
+	{0x00000004, "deleting"},
+	{0x00000008, "spooling"},
+	{0x00000010, "printing"},
+	{0x00000020, "offline"},
+	{0x00000040, "paper_out"},
+	{0x00000080, "printed"},
+	{0x00000100, "deleted"},
+	{0x00000200, "blocked_devq"},
+	{0x00000400, "user_intervention"},
+	{0x00000800, "restart"},
+	{0x00001000, "complete"},
+	{0x00002000, "retained"},
+	{0x00004000, "rendering_locally"},
+}
+
+const printerAttributeWorkOffline = 0x00000400
+
+// printerActivityMask agrupa los bits que solo indican actividad normal y no
+// deben generar un evento al cambiar.
+const printerActivityMask = 0x00000100 | 0x00000200 | 0x00000400 | 0x00002000 | 0x00004000 | 0x00008000 | 0x00010000 | 0x01000000
+
+// decodeFlags devuelve los nombres de los bits activos. Los bits desconocidos
+// se ignoran.
+func decodeFlags(value uint32, flags []printerFlag) []string {
+	names := []string{}
+	for _, f := range flags {
+		if value&f.bit != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// PrinterInfo es el estado de una impresora leído con PRINTER_INFO_2.
+type PrinterInfo struct {
+	Name       string
+	Port       string
+	Driver     string
+	Status     uint32
+	Attributes uint32
+	QueuedJobs uint32
+}
+
+// effectiveStatus agrega "offline" cuando el usuario marcó la impresora para
+// trabajar sin conexión, que Windows indica en los atributos y no en el
+// estado.
+func (p PrinterInfo) effectiveStatus() uint32 {
+	status := p.Status
+	if p.Attributes&printerAttributeWorkOffline != 0 {
+		status |= 0x00000080
+	}
+	return status &^ printerActivityMask
+}
+
+type PrinterStatusReport struct {
+	AgentID        string   `json:"agent_id"`
+	Event          string   `json:"event"` // printer_status
+	PrinterName    string   `json:"printer_name"`
+	Port           string   `json:"port"`
+	Driver         string   `json:"driver"`
+	StatusCode     uint32   `json:"status_code"`
+	StatusFlags    []string `json:"status_flags"`
+	PreviousFlags  []string `json:"previous_flags"`
+	AttributeFlags []string `json:"attribute_flags"`
+	QueuedJobs     uint32   `json:"queued_jobs"`
+	Timestamp      string   `json:"timestamp"`
+}
+
+// printerStatusTracker recuerda el último estado de cada impresora para
+// reportar solo cambios.
+type printerStatusTracker struct {
+	last map[string]uint32
+}
+
+func newPrinterStatusTracker() *printerStatusTracker {
+	return &printerStatusTracker{last: make(map[string]uint32)}
+}
+
+// Update registra el estado actual y devuelve un reporte si cambió. La
+// primera vez que se ve una impresora solo se reporta si tiene algún problema.
+func (t *printerStatusTracker) Update(info PrinterInfo, now time.Time) *PrinterStatusReport {
+	status := info.effectiveStatus()
+	prev, seen := t.last[info.Name]
+	t.last[info.Name] = status
+	if (seen && prev == status) || (!seen && status == 0) {
+		return nil
+	}
+
+	return &PrinterStatusReport{
+		AgentID:        agentID,
+		Event:          "printer_status",
+		PrinterName:    info.Name,
+		Port:           info.Port,
+		Driver:         info.Driver,
+		StatusCode:     info.Status,
+		StatusFlags:    decodeFlags(status, printerStatusFlags),
+		PreviousFlags:  decodeFlags(prev, printerStatusFlags),
+		AttributeFlags: decodeFlags(info.Attributes, printerAttributeFlags),
+		QueuedJobs:     info.QueuedJobs,
+		Timestamp:      now.Format(time.RFC3339),
+	}
+}
+
+// Forget descarta el estado de impresoras que ya no existen.
+func (t *printerStatusTracker) Forget(present []string) {
+	forgetPrinters(t.last, present)
+}
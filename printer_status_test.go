@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value uint32
+		flags []printerFlag
+		want  []string
+	}{
+		{"sin bits", 0, printerStatusFlags, []string{}},
+		{"atasco y sin papel", 0x00000008 | 0x00000010, printerStatusFlags, []string{"paper_jam", "paper_out"}},
+		{"bits desconocidos", 0x80000000 | 0x00000002, printerStatusFlags, []string{"error"}},
+		{"atributos", 0x00000004 | 0x00000008 | 0x00000400, printerAttributeFlags, []string{"default", "shared", "work_offline"}},
+		{"trabajo impreso", 0x00000080 | 0x00001000, jobStatusFlags, []string{"printed", "complete"}},
+		{"trabajo bloqueado", 0x00000200 | 0x00000400, jobStatusFlags, []string{"blocked_devq", "user_intervention"}},
+	}
+	for _, tt := range tests {
+		if got := decodeFlags(tt.value, tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decodeFlags(0x%08X) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+// Cada tabla debe tener bits únicos de un solo bit, en orden creciente.
+func TestFlagTables(t *testing.T) {
+	tables := map[string][]printerFlag{
+		"printerStatusFlags":    printerStatusFlags,
+		"printerAttributeFlags": printerAttributeFlags,
+		"jobStatusFlags":        jobStatusFlags,
+	}
+	for name, flags := range tables {
+		var prev uint32
+		for _, f := range flags {
+			if f.bit == 0 || f.bit&(f.bit-1) != 0 {
+				t.Errorf("%s: %s tiene más de un bit (0x%08X)", name, f.name, f.bit)
+			}
+			if f.bit <= prev {
+				t.Errorf("%s: %s fuera de orden", name, f.name)
+			}
+			prev = f.bit
+		}
+	}
+}
+
+func TestEffectiveStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		info PrinterInfo
+		want uint32
+	}{
+		{"inactiva", PrinterInfo{}, 0},
+		{"solo actividad", PrinterInfo{Status: 0x00000400 | 0x00000200 | 0x00004000}, 0},
+		{"imprimiendo sin papel", PrinterInfo{Status: 0x00000400 | 0x00000010}, 0x00000010},
+		{"trabajar sin conexión", PrinterInfo{Attributes: printerAttributeWorkOffline}, 0x00000080},
+		{"pausada y sin conexión", PrinterInfo{Status: 0x00000001, Attributes: printerAttributeWorkOffline | 0x00000004}, 0x00000081},
+	}
+	for _, tt := range tests {
+		if got := tt.info.effectiveStatus(); got != tt.want {
+			t.Errorf("%s: effectiveStatus = 0x%08X, want 0x%08X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrinterStatusTracker(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newPrinterStatusTracker()
+
+	steps := []struct {
+		name      string
+		info      PrinterInfo
+		wantFlags []string // nil si no se espera reporte
+		wantPrev  []string
+	}{
+		{"primera vez sin problemas", PrinterInfo{Name: "HP", Status: 0x00000400}, nil, nil},
+		{"atasco", PrinterInfo{Name: "HP", Status: 0x00000008}, []string{"paper_jam"}, []string{}},
+		{"mismo estado", PrinterInfo{Name: "HP", Status: 0x00000008 | 0x00000200}, nil, nil},
+		{"resuelto", PrinterInfo{Name: "HP"}, []string{}, []string{"paper_jam"}},
+		{"primera vez con problema", PrinterInfo{Name: "Zebra", Attributes: printerAttributeWorkOffline}, []string{"offline"}, []string{}},
+	}
+	for _, s := range steps {
+		report := tracker.Update(s.info, now)
+		if s.wantFlags == nil {
+			if report != nil {
+				t.Errorf("%s: reporte inesperado %+v", s.name, report)
+			}
+			continue
+		}
+		if report == nil {
+			t.Errorf("%s: no hubo reporte", s.name)
+			continue
+		}
+		if !reflect.DeepEqual(report.StatusFlags, s.wantFlags) || !reflect.DeepEqual(report.PreviousFlags, s.wantPrev) {
+			t.Errorf("%s: flags %v (antes %v), want %v (antes %v)", s.name, report.StatusFlags, report.PreviousFlags, s.wantFlags, s.wantPrev)
+		}
+		if report.StatusCode != s.info.Status || report.Timestamp != "2024-05-01T12:00:00Z" {
+			t.Errorf("%s: reporte %+v", s.name, report)
+		}
+	}
+
+	tracker.Forget([]string{"Zebra"})
+	if report := tracker.Update(PrinterInfo{Name: "HP"}, now); report != nil {
+		t.Errorf("impresora olvidada sin problemas no debe reportarse: %+v", report)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	defaultNotifyPollInterval = 5 * time.Minute
+	printerChangeDebounce     = 500 * time.Millisecond
+	maxDebouncedChanges       = 256
+)
+
+// printerChange es un aviso del sistema de impresión. Printer vacío indica
+// que cambió la lista de impresoras.
+type printerChange struct {
+	Printer string
+	Flags   uint32
+}
+
+// printerNotifier abstrae las notificaciones de cambios en las colas, para
+// que el watcher no dependa de winspool.
+type printerNotifier interface {
+	// Watch reemplaza el conjunto de impresoras observadas.
+	Watch(printers []string) error
+	// Next espera hasta timeout. Sin cambios devuelve una lista vacía.
+	Next(timeout time.Duration) ([]printerChange, error)
+	Close()
+}
+
+// printerWatcher revisa solo las impresoras que avisan cambios y hace un
+// sondeo completo cada fallback como red de seguridad (trabajos trabados,
+// avisos perdidos).
+type printerWatcher struct {
+	notifier printerNotifier
+	list     func() []string
+	check    func(printerName string)
+	fallback time.Duration
+	debounce time.Duration
+}
+
+// refresh revisa todas las impresoras y vuelve a registrar las
+// notificaciones con la lista actual.
+func (w *printerWatcher) refresh() error {
+	names := w.list()
+	for _, name := range names {
+		w.check(name)
+	}
+	return w.notifier.Watch(names)
+}
+
+// run atiende notificaciones hasta que el notifier falla y devuelve el error
+// para que el llamador pase a sondeo.
+func (w *printerWatcher) run() error {
+	if err := w.refresh(); err != nil {
+		return err
+	}
+	lastFull := time.Now()
+
+	for {
+		wait := w.fallback - time.Since(lastFull)
+		if wait < 0 {
+			wait = 0
+		}
+		changes, err := w.notifier.Next(wait)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			if err := w.refresh(); err != nil {
+				return err
+			}
+			lastFull = time.Now()
+			continue
+		}
+
+		// Un trabajo genera varios avisos seguidos; se agrupan antes de
+		// revisar la cola.
+		for len(changes) < maxDebouncedChanges {
+			more, err := w.notifier.Next(w.debounce)
+			if err != nil {
+				return err
+			}
+			if len(more) == 0 {
+				break
+			}
+			changes = append(changes, more...)
+		}
+
+		affected := make(map[string]bool)
+		listChanged := false
+		for _, c := range changes {
+			if c.Printer == "" {
+				listChanged = true
+			} else {
+				affected[c.Printer] = true
+			}
+		}
+		if listChanged {
+			if err := w.refresh(); err != nil {
+				return err
+			}
+			lastFull = time.Now()
+			continue
+		}
+		for name := range affected {
+			w.check(name)
+		}
+	}
+}
+
+// warnNotifyPollOverrides avisa de los overrides con poll_interval: con
+// notificaciones las impresoras se revisan al cambiar y cada
+// notify_poll_interval, y el poll_interval solo se usa si se cae a sondeo.
+func warnNotifyPollOverrides(c PrintersConfig) {
+	for _, o := range c.Overrides {
+		if o.PollInterval > 0 {
+			printerLog().Warn("poll_interval del override no se usa mientras haya notificaciones", "match", o.Match, "poll_interval", o.PollInterval)
+		}
+	}
+}
+
+// runPrinterMonitor usa notificaciones si printers.mode lo permite y cae a
+// sondeo cuando no están disponibles, reintentando después de un intervalo.
+func runPrinterMonitor(config Config, onReports func([]PrinterIssueReport)) {
+	fallback := defaultNotifyPollInterval
+	if config.Printers.NotifyPollInterval > 0 {
+		fallback = time.Duration(config.Printers.NotifyPollInterval) * time.Second
+	}
+
+	warned := false
+	for {
+		if config.Printers.Mode != "poll" {
+			if notifier, err := newPrinterNotifier(); err != nil {
+				printerLog().Warn("Notificaciones de impresora no disponibles", "error", err)
+			} else {
+				if !warned {
+					warnNotifyPollOverrides(config.Printers)
+					warned = true
+				}
+				w := &printerWatcher{
+					notifier: notifier,
+					list:     func() []string { return listMonitoredPrinters(config) },
+					check: func(name string) {
+						onReports(checkPrinter(config, name, config.printerSettings(name)))
+					},
+					fallback: fallback,
+					debounce: printerChangeDebounce,
+				}
+				err := w.run()
+				notifier.Close()
+				printerLog().Warn("Se pasa a sondeo de impresoras", "error", err)
+			}
+		}
+
+		// Sondeo: indefinido en modo poll, o hasta reintentar notificaciones.
+		deadline := time.Now().Add(fallback)
+		for config.Printers.Mode == "poll" || time.Now().Before(deadline) {
+			onReports(InitializePrinterDetection(config))
+			time.Sleep(config.Printers.tick())
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "errors"
+
+// newPrinterNotifier falla en Linux: CUPS solo avisa cambios por
+// suscripciones IPP con pull, así que se usa el sondeo.
+func newPrinterNotifier() (printerNotifier, error) {
+	return nil, errors.New("no hay notificaciones de impresora en este sistema")
+}
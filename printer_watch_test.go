@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var errNotifierDone = errors.New("fin del guion")
+
+// fakeNotifier devuelve un lote de cambios por cada llamada a Next, en el
+// orden del guion. Cuando el guion se termina devuelve errNotifierDone para
+// que run salga.
+type fakeNotifier struct {
+	script   [][]printerChange
+	timeouts []time.Duration
+	watched  [][]string
+}
+
+func (n *fakeNotifier) Watch(printers []string) error {
+	n.watched = append(n.watched, append([]string(nil), printers...))
+	return nil
+}
+
+func (n *fakeNotifier) Next(timeout time.Duration) ([]printerChange, error) {
+	n.timeouts = append(n.timeouts, timeout)
+	if len(n.script) == 0 {
+		return nil, errNotifierDone
+	}
+	changes := n.script[0]
+	n.script = n.script[1:]
+	return changes, nil
+}
+
+func (n *fakeNotifier) Close() {}
+
+// runWatcher ejecuta el guion y devuelve las impresoras revisadas después de
+// la revisión inicial, ordenadas.
+func runWatcher(t *testing.T, notifier *fakeNotifier, printers []string) []string {
+	t.Helper()
+	var checked []string
+	w := &printerWatcher{
+		notifier: notifier,
+		list:     func() []string { return printers },
+		check:    func(name string) { checked = append(checked, name) },
+		fallback: time.Hour,
+		debounce: 10 * time.Millisecond,
+	}
+	if err := w.run(); err != errNotifierDone {
+		t.Fatalf("run() = %v, se esperaba errNotifierDone", err)
+	}
+	if len(checked) < len(printers) || !reflect.DeepEqual(checked[:len(printers)], printers) {
+		t.Fatalf("la revisión inicial no revisó todas las impresoras: %v", checked)
+	}
+	checked = checked[len(printers):]
+	sort.Strings(checked)
+	return checked
+}
+
+func TestPrinterWatcherDebouncesBursts(t *testing.T) {
+	notifier := &fakeNotifier{script: [][]printerChange{
+		{{Printer: "A"}},
+		{{Printer: "A"}, {Printer: "B"}},
+		{{Printer: "A"}},
+		{}, // Vence el debounce: se revisan A y B una vez cada una.
+	}}
+	checked := runWatcher(t, notifier, []string{"A", "B", "C"})
+
+	if want := []string{"A", "B"}; !reflect.DeepEqual(checked, want) {
+		t.Fatalf("revisadas %v, se esperaba %v", checked, want)
+	}
+	// El primer Next espera el fallback; los siguientes, el debounce.
+	if notifier.timeouts[0] <= time.Minute {
+		t.Errorf("primera espera %v, se esperaba cerca del fallback", notifier.timeouts[0])
+	}
+	for _, d := range notifier.timeouts[1:4] {
+		if d != 10*time.Millisecond {
+			t.Errorf("espera durante la ráfaga %v, se esperaba el debounce", d)
+		}
+	}
+	if len(notifier.watched) != 1 {
+		t.Errorf("Watch llamado %d veces, se esperaba solo la inicial", len(notifier.watched))
+	}
+}
+
+func TestPrinterWatcherListChangeRefreshes(t *testing.T) {
+	notifier := &fakeNotifier{script: [][]printerChange{
+		{{Printer: "A"}},
+		{{Printer: ""}}, // Alta o baja de una impresora.
+		{},
+	}}
+	checked := runWatcher(t, notifier, []string{"A", "B"})
+
+	// El refresh revisa todas y reemplaza la revisión puntual de A.
+	if want := []string{"A", "B"}; !reflect.DeepEqual(checked, want) {
+		t.Fatalf("revisadas %v, se esperaba %v", checked, want)
+	}
+	if len(notifier.watched) != 2 {
+		t.Fatalf("Watch llamado %d veces, se esperaba 2", len(notifier.watched))
+	}
+}
+
+func TestPrinterWatcherFallbackOnTimeout(t *testing.T) {
+	notifier := &fakeNotifier{script: [][]printerChange{
+		{}, // Vence el fallback sin avisos.
+	}}
+	checked := runWatcher(t, notifier, []string{"A", "B"})
+
+	if want := []string{"A", "B"}; !reflect.DeepEqual(checked, want) {
+		t.Fatalf("revisadas %v, se esperaba %v", checked, want)
+	}
+	if len(notifier.watched) != 2 {
+		t.Fatalf("Watch llamado %d veces, se esperaba 2", len(notifier.watched))
+	}
+	// Después del sondeo completo el plazo vuelve a empezar.
+	if last := notifier.timeouts[len(notifier.timeouts)-1]; last <= time.Minute {
+		t.Errorf("espera después del fallback %v, se esperaba un fallback completo", last)
+	}
+}
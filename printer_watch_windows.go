@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procFindFirstPrinterChangeNotification = winspool.NewProc("FindFirstPrinterChangeNotification")
+	procFindNextPrinterChangeNotification  = winspool.NewProc("FindNextPrinterChangeNotification")
+	procFindClosePrinterChangeNotification = winspool.NewProc("FindClosePrinterChangeNotification")
+)
+
+// PRINTER_CHANGE_* de winspool.h.
+const (
+	printerChangeAddPrinter    = 0x00000001
+	printerChangeSetPrinter    = 0x00000002
+	printerChangeDeletePrinter = 0x00000004
+	printerChangeJob           = 0x0000FF00
+)
+
+// WaitForMultipleObjects admite hasta 64 handles; uno es el del servidor.
+const maxWatchedPrinters = 63
+
+type watchedPrinter struct {
+	name    string
+	printer uintptr
+	change  windows.Handle
+}
+
+// winspoolNotifier usa FindFirstPrinterChangeNotification: un handle del
+// servidor para altas y bajas de impresoras y uno por impresora para
+// trabajos y cambios de estado.
+type winspoolNotifier struct {
+	server       uintptr
+	serverChange windows.Handle
+	printers     []watchedPrinter
+}
+
+func newPrinterNotifier() (printerNotifier, error) {
+	if name := currentPrinterBackend().Name(); name != "winspool" {
+		return nil, fmt.Errorf("el backend %s no tiene notificaciones", name)
+	}
+	n := &winspoolNotifier{}
+	var err error
+	n.server, n.serverChange, err = openPrinterChange(nil, printerChangeAddPrinter|printerChangeDeletePrinter)
+	if err != nil {
+		return nil, fmt.Errorf("servidor de impresión: %v", err)
+	}
+	return n, nil
+}
+
+// openPrinterChange abre la impresora (nil = servidor local) y registra la
+// notificación.
+func openPrinterChange(name *uint16, filter uint32) (uintptr, windows.Handle, error) {
+	var hPrinter uintptr
+	ret, _, err := procOpenPrinter.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&hPrinter)), 0)
+	if ret == 0 || hPrinter == 0 {
+		return 0, 0, fmt.Errorf("OpenPrinter: %v", err)
+	}
+	h, _, err := procFindFirstPrinterChangeNotification.Call(hPrinter, uintptr(filter), 0, 0)
+	if windows.Handle(h) == windows.InvalidHandle || h == 0 {
+		procClosePrinter.Call(hPrinter)
+		return 0, 0, fmt.Errorf("FindFirstPrinterChangeNotification: %v", err)
+	}
+	return hPrinter, windows.Handle(h), nil
+}
+
+func closePrinterChange(printer uintptr, change windows.Handle) {
+	procFindClosePrinterChangeNotification.Call(uintptr(change))
+	procClosePrinter.Call(printer)
+}
+
+func (n *winspoolNotifier) Watch(printers []string) error {
+	for _, p := range n.printers {
+		closePrinterChange(p.printer, p.change)
+	}
+	n.printers = nil
+
+	if len(printers) > maxWatchedPrinters {
+		printerLog().Warn("Demasiadas impresoras para notificaciones, el resto se revisa en el sondeo completo", "printers", len(printers), "watched", maxWatchedPrinters)
+		printers = printers[:maxWatchedPrinters]
+	}
+	for _, name := range printers {
+		hPrinter, change, err := openPrinterChange(utf16Ptr(name), printerChangeJob|printerChangeSetPrinter)
+		if err != nil {
+			printerLog().Warn("Sin notificaciones para la impresora", "printer", name, "error", err)
+			continue
+		}
+		n.printers = append(n.printers, watchedPrinter{name: name, printer: hPrinter, change: change})
+	}
+	return nil
+}
+
+func (n *winspoolNotifier) Next(timeout time.Duration) ([]printerChange, error) {
+	handles := []windows.Handle{n.serverChange}
+	for _, p := range n.printers {
+		handles = append(handles, p.change)
+	}
+
+	ms := uint32(timeout / time.Millisecond)
+	event, err := windows.WaitForMultipleObjects(handles, false, ms)
+	if err != nil {
+		return nil, err
+	}
+	if event == uint32(windows.WAIT_TIMEOUT) {
+		return nil, nil
+	}
+	index := int(event - windows.WAIT_OBJECT_0)
+	if index < 0 || index >= len(handles) {
+		return nil, fmt.Errorf("WaitForMultipleObjects devolvió %d", event)
+	}
+
+	// FindNextPrinterChangeNotification rearma el handle y devuelve qué
+	// cambió.
+	var flags uint32
+	ret, _, err := procFindNextPrinterChangeNotification.Call(uintptr(handles[index]), uintptr(unsafe.Pointer(&flags)), 0, 0)
+	if ret == 0 {
+		return nil, fmt.Errorf("FindNextPrinterChangeNotification: %v", err)
+	}
+	if index == 0 {
+		return []printerChange{{Flags: flags}}, nil
+	}
+	return []printerChange{{Printer: n.printers[index-1].name, Flags: flags}}, nil
+}
+
+func (n *winspoolNotifier) Close() {
+	for _, p := range n.printers {
+		closePrinterChange(p.printer, p.change)
+	}
+	n.printers = nil
+	closePrinterChange(n.server, n.serverChange)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/alexbrainman/printer"
+	"golang.org/x/sys/windows"
+)
+
+// winspoolBackend implementa PrinterBackend con winspool.drv.
+type winspoolBackend struct{}
+
+func newWinspoolBackend() (PrinterBackend, error) { return winspoolBackend{}, nil }
+
+func defaultPrinterBackend(config Config) PrinterBackend { return winspoolBackend{} }
+
+func (winspoolBackend) Name() string                    { return "winspool" }
+func (winspoolBackend) EnsureRunning() error            { return ensureSpoolerRunning() }
+func (winspoolBackend) ListPrinters() ([]string, error) { return printer.ReadNames() }
+func (winspoolBackend) RestartService() error           { return restartSpooler() }
+
+func (winspoolBackend) PrinterInfo(printerName string) (PrinterInfo, error) {
+	return getPrinterInfo(printerName)
+}
+
+func (winspoolBackend) Jobs(printerName string) ([]PrintJob, error) {
+	return enumPrintJobs(printerName)
+}
+
+func (winspoolBackend) ControlJob(printerName string, jobID uint32, command uint32) error {
+	return controlPrintJob(printerName, jobID, command)
+}
+
+var (
+	winspool         = windows.NewLazySystemDLL("winspool.drv")
+	procOpenPrinter  = winspool.NewProc("OpenPrinterW")
+	procEnumJobs     = winspool.NewProc("EnumJobsW")
+	procClosePrinter = winspool.NewProc("ClosePrinter")
+	procSetJob       = winspool.NewProc("SetJobW")
+	procGetPrinter   = winspool.NewProc("GetPrinterW")
+)
+
+// maxEnumJobs es la cantidad máxima de trabajos que se leen por impresora.
+const maxEnumJobs = 255
+
+const printerAccessAdminister = 0x00000004
+
+// printerDefaults corresponde a PRINTER_DEFAULTSW.
+type printerDefaults struct {
+	pDatatype     *uint16
+	pDevMode      *devMode
+	DesiredAccess uint32
+}
+
+// JobInfo2 corresponde a JOB_INFO_2W. Se usa en lugar de JOB_INFO_1 para
+// obtener el DEVMODE del trabajo (color y dúplex).
+type JobInfo2 struct {
+	JobID               uint32
+	pPrinterName        *uint16
+	pMachineName        *uint16
+	pUserName           *uint16
+	pDocument           *uint16
+	pNotifyName         *uint16
+	pDatatype           *uint16
+	pPrintProcessor     *uint16
+	pParameters         *uint16
+	pDriverName         *uint16
+	pDevMode            *devMode
+	pStatus             *uint16
+	pSecurityDescriptor uintptr
+	Status              uint32
+	Priority            uint32
+	Position            uint32
+	StartTime           uint32
+	UntilTime           uint32
+	TotalPages          uint32
+	Size                uint32
+	Submitted           windows.Systemtime
+	Time                uint32
+	PagesPrinted        uint32
+}
+
+// devMode contiene los campos de DEVMODEW hasta dmDuplex.
+type devMode struct {
+	dmDeviceName    [32]uint16
+	dmSpecVersion   uint16
+	dmDriverVersion uint16
+	dmSize          uint16
+	dmDriverExtra   uint16
+	dmFields        uint32
+	dmUnion         [16]byte
+	dmColor         int16
+	dmDuplex        int16
+}
+
+const (
+	dmFieldColor  = 0x00000800
+	dmFieldDuplex = 0x00001000
+)
+
+// colorAndDuplex decodifica dmColor y dmDuplex cuando el driver los informa.
+func (dm *devMode) colorAndDuplex() (color, duplex string) {
+	if dm == nil {
+		return "", ""
+	}
+	if dm.dmFields&dmFieldColor != 0 {
+		switch dm.dmColor {
+		case 1:
+			color = "mono"
+		case 2:
+			color = "color"
+		}
+	}
+	if dm.dmFields&dmFieldDuplex != 0 {
+		switch dm.dmDuplex {
+		case 1:
+			duplex = "simplex"
+		case 2:
+			duplex = "long_edge"
+		case 3:
+			duplex = "short_edge"
+		}
+	}
+	return color, duplex
+}
+
+// PrinterInfo2 corresponde a PRINTER_INFO_2W.
+type PrinterInfo2 struct {
+	pServerName         *uint16
+	pPrinterName        *uint16
+	pShareName          *uint16
+	pPortName           *uint16
+	pDriverName         *uint16
+	pComment            *uint16
+	pLocation           *uint16
+	pDevMode            uintptr
+	pSepFile            *uint16
+	pPrintProcessor     *uint16
+	pDatatype           *uint16
+	pParameters         *uint16
+	pSecurityDescriptor uintptr
+	Attributes          uint32
+	Priority            uint32
+	DefaultPriority     uint32
+	StartTime           uint32
+	UntilTime           uint32
+	Status              uint32
+	cJobs               uint32
+	AveragePPM          uint32
+}
+
+func utf16Ptr(s string) *uint16 {
+	ptr, _ := syscall.UTF16PtrFromString(s)
+	return ptr
+}
+
+// getPrinterInfo lee estado, atributos, puerto, driver y cantidad de trabajos
+// con GetPrinter nivel 2.
+func getPrinterInfo(printerName string) (PrinterInfo, error) {
+	info := PrinterInfo{Name: printerName}
+
+	var hPrinter uintptr
+	ret, _, err := procOpenPrinter.Call(
+		uintptr(unsafe.Pointer(utf16Ptr(printerName))),
+		uintptr(unsafe.Pointer(&hPrinter)),
+		0,
+	)
+	if ret == 0 || hPrinter == 0 {
+		return info, fmt.Errorf("no se pudo abrir la impresora '%s': %v", printerName, err)
+	}
+	defer procClosePrinter.Call(hPrinter)
+
+	var needed uint32
+	procGetPrinter.Call(hPrinter, 2, 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if needed == 0 {
+		return info, fmt.Errorf("GetPrinter '%s' no devolvió datos", printerName)
+	}
+
+	buf := make([]byte, needed)
+	ret, _, err = procGetPrinter.Call(hPrinter, 2, uintptr(unsafe.Pointer(&buf[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)))
+	if ret == 0 {
+		return info, fmt.Errorf("GetPrinter '%s': %v", printerName, err)
+	}
+
+	pi := (*PrinterInfo2)(unsafe.Pointer(&buf[0]))
+	info.Port = windows.UTF16PtrToString(pi.pPortName)
+	info.Driver = windows.UTF16PtrToString(pi.pDriverName)
+	info.Status = pi.Status
+	info.Attributes = pi.Attributes
+	info.QueuedJobs = pi.cJobs
+	return info, nil
+}
+
+// enumPrintJobs lee hasta maxEnumJobs trabajos de la cola con EnumJobs
+// nivel 2.
+func enumPrintJobs(printerName string) ([]PrintJob, error) {
+	var hPrinter uintptr
+	ret, _, _ := procOpenPrinter.Call(
+		uintptr(unsafe.Pointer(utf16Ptr(printerName))),
+		uintptr(unsafe.Pointer(&hPrinter)),
+		0,
+	)
+	if ret == 0 || hPrinter == 0 {
+		return nil, fmt.Errorf("no se pudo abrir la impresora '%s'", printerName)
+	}
+	defer procClosePrinter.Call(hPrinter)
+
+	var needed, returned uint32
+	procEnumJobs.Call(hPrinter, 0, maxEnumJobs, 2, 0, 0, uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
+
+	if needed == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, needed)
+	ret, _, _ = procEnumJobs.Call(
+		hPrinter, 0, maxEnumJobs, 2,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed),
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)),
+	)
+
+	if ret == 0 {
+		return nil, fmt.Errorf("no se pudieron leer trabajos para '%s'", printerName)
+	}
+
+	entrySize := int(unsafe.Sizeof(JobInfo2{}))
+	count := int(returned)
+	jobs := make([]PrintJob, 0, count)
+
+	for i := 0; i < count; i++ {
+		job := (*JobInfo2)(unsafe.Pointer(&buf[i*entrySize]))
+		st := job.Submitted
+		color, duplex := job.pDevMode.colorAndDuplex()
+		jobs = append(jobs, PrintJob{
+			PrinterName:  printerName,
+			JobID:        job.JobID,
+			Document:     windows.UTF16PtrToString(job.pDocument),
+			User:         windows.UTF16PtrToString(job.pUserName),
+			Status:       job.Status,
+			StatusText:   windows.UTF16PtrToString(job.pStatus),
+			Position:     job.Position,
+			TotalPages:   job.TotalPages,
+			PagesPrinted: job.PagesPrinted,
+			Color:        color,
+			Duplex:       duplex,
+			Submitted: time.Date(int(st.Year), time.Month(st.Month), int(st.Day),
+				int(st.Hour), int(st.Minute), int(st.Second), int(st.Milliseconds)*int(time.Millisecond), time.UTC),
+		})
+	}
+
+	return jobs, nil
+}
+
+// restartSpooler detiene el servicio Spooler, lo vuelve a iniciar y espera a
+// que esté corriendo.
+func restartSpooler() error {
+	printerLog().Info("Reiniciando el servicio 'Spooler'...")
+	state, err := queryServiceState("Spooler")
+	if err != nil {
+		return err
+	}
+	if state != serviceStopped {
+		if err := stopService("Spooler"); err != nil {
+			return err
+		}
+		if err := waitServiceState("Spooler", serviceStopped); err != nil {
+			return err
+		}
+	}
+	if err := startService("Spooler"); err != nil {
+		return err
+	}
+	return waitServiceState("Spooler", serviceRunning)
+}
+
+// controlPrintJob aplica un comando JOB_CONTROL_* a un trabajo de la cola.
+// Abre la impresora con PRINTER_ACCESS_ADMINISTER: con el acceso por defecto
+// SetJob solo puede controlar los trabajos del propio usuario.
+func controlPrintJob(printerName string, jobID uint32, command uint32) error {
+	var hPrinter uintptr
+	defaults := printerDefaults{DesiredAccess: printerAccessAdminister}
+	ret, _, err := procOpenPrinter.Call(
+		uintptr(unsafe.Pointer(utf16Ptr(printerName))),
+		uintptr(unsafe.Pointer(&hPrinter)),
+		uintptr(unsafe.Pointer(&defaults)),
+	)
+	if ret == 0 || hPrinter == 0 {
+		return fmt.Errorf("no se pudo abrir la impresora '%s': %v", printerName, err)
+	}
+	defer procClosePrinter.Call(hPrinter)
+
+	ret, _, err = procSetJob.Call(hPrinter, uintptr(jobID), 0, 0, uintptr(command))
+	if ret == 0 {
+		return fmt.Errorf("SetJob %d en '%s': %v", jobID, printerName, err)
+	}
+	return nil
+}
+
+// ensureSpoolerRunning inicia el Spooler si está detenido y espera a que esté
+// corriendo.
+func ensureSpoolerRunning() error {
+	state, err := queryServiceState("Spooler")
+	if err != nil {
+		return err
+	}
+	if state == serviceRunning {
+		return nil
+	}
+	// Un servicio que ya está arrancando solo hay que esperarlo.
+	if state == serviceStopped {
+		printerLog().Warn("El servicio 'Spooler' está detenido. Intentando iniciarlo...")
+		if err := startService("Spooler"); err != nil {
+			return err
+		}
+	}
+	if err := waitServiceState("Spooler", serviceRunning); err != nil {
+		return err
+	}
+	printerLog().Info("Servicio 'Spooler' está corriendo.")
+	return nil
+}
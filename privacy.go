@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// PrivacyConfig define cómo se redactan los campos sensibles antes de que un
+// payload salga del equipo.
+type PrivacyConfig struct {
+	Salt  string          `yaml:"salt"` // Clave para hash, distinta por cliente
+	Rules []RedactionRule `yaml:"rules"`
+}
+
+// RedactionRule se aplica a toda clave JSON llamada Field, en cualquier nivel
+// del payload. Las reglas de un mismo campo se aplican en orden.
+type RedactionRule struct {
+	Field       string `yaml:"field"`  // document | user | message | cmdline | ...
+	Action      string `yaml:"action"` // drop | hash | truncate | mask
+	MaxLength   int    `yaml:"max_length"`
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+}
+
+type redactionRule struct {
+	RedactionRule
+	re *regexp.Regexp
+}
+
+type redactor struct {
+	salt  []byte
+	rules map[string][]redactionRule
+}
+
+var (
+	privacyMu sync.RWMutex
+	privacy   *redactor
+)
+
+// configurePrivacy compila las reglas. Las reglas inválidas se ignoran con
+// un aviso en el log.
+func configurePrivacy(config Config) {
+	r := &redactor{salt: []byte(config.Privacy.Salt), rules: make(map[string][]redactionRule)}
+	for _, rule := range config.Privacy.Rules {
+		compiled := redactionRule{RedactionRule: rule}
+		switch rule.Action {
+		case "drop", "truncate":
+		case "hash":
+			if config.Privacy.Salt == "" {
+				slog.Warn("Regla de privacidad: hash sin salt", "field", rule.Field)
+			}
+		case "mask":
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				slog.Warn("Regla de privacidad: patrón inválido", "field", rule.Field, "error", err)
+				continue
+			}
+			compiled.re = re
+		default:
+			slog.Warn("Regla de privacidad: acción desconocida", "field", rule.Field, "action", rule.Action)
+			continue
+		}
+		r.rules[rule.Field] = append(r.rules[rule.Field], compiled)
+	}
+
+	privacyMu.Lock()
+	privacy = r
+	privacyMu.Unlock()
+}
+
+func currentRedactor() *redactor {
+	privacyMu.RLock()
+	defer privacyMu.RUnlock()
+	return privacy
+}
+
+// redactValue aplica las reglas de un campo a un valor suelto, por ejemplo
+// para la salida por consola. Un campo descartado queda vacío.
+func redactValue(field, value string) string {
+	r := currentRedactor()
+	if r == nil {
+		return value
+	}
+	value, _ = r.apply(field, value)
+	return value
+}
+
+// apply devuelve el valor redactado y false si el campo debe descartarse.
+func (r *redactor) apply(field, value string) (string, bool) {
+	for _, rule := range r.rules[field] {
+		switch rule.Action {
+		case "drop":
+			return "", false
+		case "hash":
+			if value != "" {
+				mac := hmac.New(sha256.New, r.salt)
+				mac.Write([]byte(value))
+				value = hex.EncodeToString(mac.Sum(nil))[:32]
+			}
+		case "truncate":
+			value = truncateRunes(value, rule.MaxLength)
+		case "mask":
+			value = rule.re.ReplaceAllString(value, rule.Replacement)
+		}
+	}
+	return value, true
+}
+
+func truncateRunes(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max])
+}
+
+// drops indica si alguna regla del campo lo descarta.
+func (r *redactor) drops(field string) bool {
+	for _, rule := range r.rules[field] {
+		if rule.Action == "drop" {
+			return true
+		}
+	}
+	return false
+}
+
+// walk recorre el JSON decodificado y redacta los strings de los campos con
+// reglas. Un campo con drop se borra sea cual sea su tipo, incluidos objetos
+// y arrays; las demás acciones solo se aplican a strings.
+func (r *redactor) walk(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if len(r.rules[key]) > 0 {
+				if s, ok := child.(string); ok {
+					redacted, keep := r.apply(key, s)
+					if !keep {
+						delete(v, key)
+						continue
+					}
+					v[key] = redacted
+					continue
+				}
+				if r.drops(key) {
+					delete(v, key)
+					continue
+				}
+			}
+			v[key] = r.walk(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.walk(child)
+		}
+	}
+	return v
+}
+
+// marshalReport serializa un payload que sale del equipo aplicando las reglas
+// de privacidad. Todo envío al servidor pasa por acá.
+func marshalReport(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	r := currentRedactor()
+	if r == nil || len(r.rules) == 0 {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.walk(tree))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalReportRedaction(t *testing.T) {
+	defer configurePrivacy(Config{})
+	configurePrivacy(Config{Privacy: PrivacyConfig{
+		Salt: "s",
+		Rules: []RedactionRule{
+			{Field: "user", Action: "drop"},
+			{Field: "args", Action: "drop"},
+			{Field: "env", Action: "drop"},
+			{Field: "pages", Action: "drop"},
+			{Field: "document", Action: "truncate", MaxLength: 4},
+			{Field: "message", Action: "mask", Pattern: `\d+`, Replacement: "#"},
+			{Field: "tags", Action: "truncate", MaxLength: 1},
+		},
+	}})
+
+	payload := map[string]interface{}{
+		"user":     "ana",
+		"args":     []string{"--token", "secreto"},
+		"env":      map[string]string{"PASSWORD": "x"},
+		"pages":    12,
+		"document": "Informe anual.pdf",
+		"tags":     []string{"ab", "cd"},
+		"jobs": []map[string]interface{}{
+			{"user": "luis", "message": "pin 1234", "args": map[string]int{"n": 1}},
+		},
+	}
+	data, err := marshalReport(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"document":"Info","jobs":[{"message":"pin #"}],"tags":["ab","cd"]}`
+	if string(data) != want {
+		t.Fatalf("marshalReport = %s, want %s", data, want)
+	}
+}
+
+func TestConfigurePrivacyReplacesRules(t *testing.T) {
+	defer configurePrivacy(Config{})
+	configurePrivacy(Config{Privacy: PrivacyConfig{Rules: []RedactionRule{{Field: "user", Action: "drop"}}}})
+	configurePrivacy(Config{Privacy: PrivacyConfig{Rules: []RedactionRule{{Field: "document", Action: "drop"}}}})
+
+	data, err := marshalReport(map[string]string{"user": "ana", "document": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	json.Unmarshal(data, &got)
+	if got["user"] != "ana" || len(got) != 1 {
+		t.Fatalf("marshalReport = %s", data)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func init() {
+	registerCollector("top_processes", CollectorConfig{Enabled: false, Interval: 30}, func(config Config) Collector {
+		return newProcessCollector(config.Processes)
+	})
+}
+
+type ProcessConfig struct {
+	Count            int `yaml:"count"`
+	CmdlineMaxLength int `yaml:"cmdline_max_length"` // Caracteres
+
+	// Enabled e Interval son las claves anteriores a collectors; se aceptan
+	// como alias de collectors.top_processes.
+	Enabled  *bool `yaml:"enabled"`
+	Interval uint  `yaml:"interval"` // Segundos
+}
+
+// legacyCollectorConfig arma la configuración del collector con las claves
+// obsoletas. ok es false si no hay ninguna.
+func (c ProcessConfig) legacyCollectorConfig(defaults CollectorConfig) (cfg CollectorConfig, ok bool) {
+	if c.Enabled == nil && c.Interval == 0 {
+		return defaults, false
+	}
+	cfg = defaults
+	if c.Enabled != nil {
+		cfg.Enabled = *c.Enabled
+	}
+	if c.Interval > 0 {
+		cfg.Interval = c.Interval
+	}
+	return cfg, true
+}
+
+type ProcessInfo struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	User       string  `json:"user,omitempty"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSS        uint64  `json:"rss"`
+	Cmdline    string  `json:"cmdline,omitempty"`
+}
+
+type ProcessSnapshot struct {
+	Timestamp time.Time     `json:"timestamp"`
+	ByCPU     []ProcessInfo `json:"by_cpu"`
+	ByMemory  []ProcessInfo `json:"by_memory"`
+}
+
+// processCollector mantiene el tiempo de CPU de cada proceso entre muestras
+// para calcular el porcentaje de uso en el intervalo.
+type processCollector struct {
+	config   ProcessConfig
+	prevCPU  map[int32]float64
+	prevTime time.Time
+}
+
+func newProcessCollector(config ProcessConfig) *processCollector {
+	if config.Count <= 0 {
+		config.Count = 5
+	}
+	if config.CmdlineMaxLength <= 0 {
+		config.CmdlineMaxLength = 256
+	}
+	return &processCollector{config: config, prevCPU: make(map[int32]float64)}
+}
+
+func (s *processCollector) Name() string { return "top_processes" }
+
+// Collect devuelve los N procesos con más CPU y más memoria. El porcentaje de
+// CPU se normaliza por número de núcleos para ser comparable con cpu_percent.
+// La primera llamada solo toma la línea base de tiempos de CPU y devuelve nil.
+func (s *processCollector) Collect() (interface{}, error) {
+	now := time.Now()
+
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	type sample struct {
+		proc *process.Process
+		cpu  float64
+		rss  uint64
+	}
+
+	elapsed := now.Sub(s.prevTime).Seconds()
+	numCPU := float64(runtime.NumCPU())
+	current := make(map[int32]float64, len(procs))
+	samples := make([]sample, 0, len(procs))
+
+	for _, p := range procs {
+		smp := sample{proc: p}
+		if times, err := p.Times(); err == nil {
+			total := times.User + times.System
+			current[p.Pid] = total
+			if prev, ok := s.prevCPU[p.Pid]; ok && elapsed > 0 && total >= prev {
+				smp.cpu = (total - prev) / elapsed / numCPU * 100
+			}
+		}
+		if mi, err := p.MemoryInfo(); err == nil {
+			smp.rss = mi.RSS
+		}
+		samples = append(samples, smp)
+	}
+
+	primed := !s.prevTime.IsZero()
+	s.prevCPU = current
+	s.prevTime = now
+	if !primed {
+		return nil, nil
+	}
+
+	snapshot := &ProcessSnapshot{Timestamp: now}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].cpu > samples[j].cpu })
+	for i := 0; i < len(samples) && i < s.config.Count; i++ {
+		snapshot.ByCPU = append(snapshot.ByCPU, s.describe(samples[i].proc, samples[i].cpu, samples[i].rss))
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].rss > samples[j].rss })
+	for i := 0; i < len(samples) && i < s.config.Count; i++ {
+		snapshot.ByMemory = append(snapshot.ByMemory, s.describe(samples[i].proc, samples[i].cpu, samples[i].rss))
+	}
+
+	return snapshot, nil
+}
+
+// describe completa los datos descriptivos solo para los procesos que se van a
+// reportar, ya que obtener usuario y línea de comandos es costoso.
+func (s *processCollector) describe(p *process.Process, cpuPercent float64, rss uint64) ProcessInfo {
+	info := ProcessInfo{PID: p.Pid, CPUPercent: cpuPercent, RSS: rss}
+	info.Name, _ = p.Name()
+	info.User, _ = p.Username()
+	cmdline, _ := p.Cmdline()
+	info.Cmdline = truncate(cmdline, s.config.CmdlineMaxLength)
+	return info
+}
+
+// truncate recorta s a max runas.
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+)
+
+// Estados de servicio que comparan checkServices y los comandos. Cada
+// sistema traduce los suyos a estos nombres; los que no tienen equivalente
+// se informan tal cual.
+const (
+	serviceRunning = "running"
+	serviceStopped = "stopped"
+)
+
+const serviceControlTimeout = 30 * time.Second
+
+// restartService detiene el servicio si está corriendo y lo vuelve a
+// iniciar, esperando cada estado.
+func restartService(name string) error {
+	state, err := queryServiceState(name)
+	if err != nil {
+		return err
+	}
+	// Una unidad failed de systemd ya está detenida y StopUnit no la
+	// saca de ese estado.
+	if state != serviceStopped && state != "failed" {
+		if err := stopService(name); err != nil {
+			return err
+		}
+		if err := waitServiceState(name, serviceStopped); err != nil {
+			return err
+		}
+	}
+	if err := startService(name); err != nil {
+		return err
+	}
+	return waitServiceState(name, serviceRunning)
+}
+
+// waitServiceState consulta el estado hasta que sea want o venza
+// serviceControlTimeout.
+func waitServiceState(name, want string) error {
+	deadline := time.Now().Add(serviceControlTimeout)
+	for time.Now().Before(deadline) {
+		state, err := queryServiceState(name)
+		if err != nil {
+			return err
+		}
+		if state == want {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return newReason(reasonCommandServiceStateTimeout, "state", want, "timeout", serviceControlTimeout.String())
+}
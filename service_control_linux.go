@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+)
+
+// queryServiceState devuelve el ActiveState de la unidad en systemd,
+// traducido con systemdStates. Los errores son Reason con
+// service_not_found o service_query_failed.
+func queryServiceState(name string) (string, error) {
+	bus, err := dialSystemBus()
+	if err != nil {
+		return "", newReason(reasonServiceQueryFailed, "error", err.Error())
+	}
+	defer bus.Close()
+
+	unit := systemdUnitName(name)
+	path := systemdObjectPath(unit)
+	load, err := getUnitProperty(bus, path, "LoadState")
+	if err != nil {
+		return "", newReason(reasonServiceQueryFailed, "error", err.Error())
+	}
+	if load == "not-found" {
+		return "", newReason(reasonServiceNotFound, "error", fmt.Sprintf("unidad '%s' inexistente", unit))
+	}
+	active, err := getUnitProperty(bus, path, "ActiveState")
+	if err != nil {
+		return "", newReason(reasonServiceQueryFailed, "error", err.Error())
+	}
+	return systemdState(active), nil
+}
+
+func startService(name string) error {
+	return systemdUnitCall("StartUnit", name)
+}
+
+func stopService(name string) error {
+	return systemdUnitCall("StopUnit", name)
+}
+
+// systemdUnitCall encola el job en systemd con modo "replace"; el resultado
+// se ve con waitServiceState.
+func systemdUnitCall(method, name string) error {
+	bus, err := dialSystemBus()
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	_, err = bus.Call(systemdDest, systemdPath, systemdManager, method, systemdUnitName(name), "replace")
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// queryServiceState devuelve el estado del servicio en el SCM. Los errores
+// son Reason con service_not_found o service_query_failed.
+func queryServiceState(name string) (string, error) {
+	var state string
+	err := withService(name, func(s *mgr.Service) error {
+		status, err := s.Query()
+		if err != nil {
+			return newReason(reasonServiceQueryFailed, "error", err.Error())
+		}
+		switch status.State {
+		case svc.Stopped:
+			state = serviceStopped
+		case svc.Running:
+			state = serviceRunning
+		default:
+			state = fmt.Sprintf("state_%d", status.State)
+		}
+		return nil
+	})
+	return state, err
+}
+
+func startService(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		return s.Start()
+	})
+}
+
+func stopService(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		_, err := s.Control(svc.Stop)
+		return err
+	})
+}
+
+func withService(name string, fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return newReason(reasonServiceQueryFailed, "error", err.Error())
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return newReason(reasonServiceNotFound, "error", err.Error())
+	}
+	defer s.Close()
+	return fn(s)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// journalEntry son los campos de journalctl -o json que se usan.
+type journalEntry struct {
+	RealtimeTimestamp string          `json:"__REALTIME_TIMESTAMP"` // Microsegundos
+	Message           json.RawMessage `json:"MESSAGE"`
+	Priority          string          `json:"PRIORITY"`
+}
+
+// getServiceEventLogs obtiene los mensajes recientes de la unidad en el
+// journal. El nivel usa los nombres del Visor de eventos para que el
+// servidor reciba lo mismo que en Windows.
+func getServiceEventLogs(serviceName string, minutes int) ([]ServiceEventLog, error) {
+	cmd := exec.Command("journalctl", "--no-pager", "-o", "json",
+		"-u", systemdUnitName(serviceName),
+		"--since", fmt.Sprintf("-%dmin", minutes))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
+
+	var events []ServiceEventLog
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		message := journalMessage(entry.Message)
+		if message == "" {
+			continue
+		}
+		event := ServiceEventLog{
+			ServiceName: serviceName,
+			Message:     message,
+			Level:       journalLevel(entry.Priority),
+			AgentID:     agentID,
+			Hostname:    hostname,
+			IP:          ip,
+		}
+		if usec, err := strconv.ParseInt(entry.RealtimeTimestamp, 10, 64); err == nil {
+			event.Timestamp = time.UnixMicro(usec)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// journalMessage acepta MESSAGE como texto o, si no es UTF-8 válido, como
+// el arreglo de bytes con que lo exporta journalctl.
+func journalMessage(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var b []byte
+	var ints []int
+	if err := json.Unmarshal(raw, &ints); err == nil {
+		for _, v := range ints {
+			b = append(b, byte(v))
+		}
+	}
+	return string(b)
+}
+
+func journalLevel(priority string) string {
+	switch priority {
+	case "0", "1", "2", "3":
+		return "Error"
+	case "4":
+		return "Warning"
+	default:
+		return "Information"
+	}
+}
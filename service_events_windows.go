@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// getServiceEventLogs obtiene los logs recientes de eventos del servicio usando PowerShell
+func getServiceEventLogs(serviceName string, minutes int) ([]ServiceEventLog, error) {
+	psCommand := fmt.Sprintf(
+		`Get-WinEvent -LogName Application -FilterXPath "*[System[Provider[@Name='%s'] and TimeCreated[timediff(@SystemTime) <= %d]]]" | Format-List TimeCreated,Message,LevelDisplayName`,
+		serviceName, minutes*60000)
+
+	cmd := exec.Command("powershell", "-Command", psCommand)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	rawOutput := out.String()
+	eventsRaw := strings.Split(rawOutput, "\n\n")
+
+	hostname, _ := os.Hostname()
+	ip, _ := GetOutboundIP()
+
+	var events []ServiceEventLog
+	var currentEvent ServiceEventLog
+	for _, ev := range eventsRaw {
+		lines := strings.Split(ev, "\n")
+		currentEvent = ServiceEventLog{
+			ServiceName: serviceName,
+			AgentID:     agentID,
+			Hostname:    hostname,
+			IP:          ip,
+		}
+
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "TimeCreated") {
+				parts := strings.SplitN(line, ":", 2)
+				if len(parts) == 2 {
+					timestampStr := strings.TrimSpace(parts[1])
+					t, err := time.Parse("1/2/2006 3:04:05 PM", timestampStr)
+					if err == nil {
+						currentEvent.Timestamp = t
+					} else {
+						// Intentar otro formato común
+						t2, err2 := time.Parse(time.RFC3339, timestampStr)
+						if err2 == nil {
+							currentEvent.Timestamp = t2
+						}
+					}
+				}
+			} else if strings.HasPrefix(line, "Message") {
+				parts := strings.SplitN(line, ":", 2)
+				if len(parts) == 2 {
+					currentEvent.Message = strings.TrimSpace(parts[1])
+				}
+			} else if strings.HasPrefix(line, "LevelDisplayName") {
+				parts := strings.SplitN(line, ":", 2)
+				if len(parts) == 2 {
+					currentEvent.Level = strings.TrimSpace(parts[1])
+				}
+			}
+		}
+
+		if currentEvent.Message != "" {
+			events = append(events, currentEvent)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import "os"
+
+// detectService indica si el proceso lo inició systemd, que define
+// INVOCATION_ID para cada ejecución de una unidad.
+func detectService() (bool, error) {
+	return os.Getenv("INVOCATION_ID") != "", nil
+}
+
+// runService ejecuta el ciclo del cliente en primer plano; systemd se
+// encarga de detenerlo con SIGTERM.
+func runService(name string, isDebug bool) {
+	runClientLoop()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ServiceTransition es un cambio de estado de un servicio con la hora exacta
+// en que lo informó el sistema.
+type ServiceTransition struct {
+	AgentID     string    `json:"agent_id"`
+	ServiceName string    `json:"service_name"`
+	From        string    `json:"from,omitempty"`
+	To          string    `json:"to"`
+	Detail      string    `json:"detail,omitempty"` // Código de salida en Windows, SubState en systemd
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// serviceTransitions guarda las transiciones hasta que se envían en el
+// próximo reporte.
+var serviceTransitions *spool
+
+// serviceStateRecorder descarta los avisos repetidos y encola el resto.
+type serviceStateRecorder struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func newServiceStateRecorder() *serviceStateRecorder {
+	return &serviceStateRecorder{last: make(map[string]string)}
+}
+
+func (r *serviceStateRecorder) record(name, state, detail string, at time.Time) {
+	r.mu.Lock()
+	prev := r.last[name]
+	r.last[name] = state
+	r.mu.Unlock()
+	if prev == state {
+		return
+	}
+
+	servicesLog().Info("Cambio de estado de servicio", "service", name, "from", prev, "to", state, "detail", detail)
+	payload, err := marshalReport(ServiceTransition{
+		AgentID:     agentID,
+		ServiceName: name,
+		From:        prev,
+		To:          state,
+		Detail:      detail,
+		Timestamp:   at,
+	})
+	if err != nil {
+		return
+	}
+	serviceTransitions.Append(payload)
+}
+
+// serviceWatchStable es cuánto tiene que durar una suscripción para que el
+// siguiente error vuelva a empezar el backoff desde cero.
+const serviceWatchStable = time.Minute
+
+// runServiceWatcher se suscribe a los cambios de estado de los servicios
+// configurados. watchServices bloquea mientras la suscripción funciona; si
+// falla se reintenta con backoff.
+func runServiceWatcher(config Config) {
+	var names []string
+	for _, s := range config.Services {
+		names = append(names, s.Name)
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	recorder := newServiceStateRecorder()
+	attempt := 0
+	for {
+		started := time.Now()
+		err := watchServices(names, recorder.record)
+		if time.Since(started) > serviceWatchStable {
+			attempt = 0
+		}
+		delay := backoffDelay(attempt)
+		attempt++
+		servicesLog().Warn("Suscripción a servicios interrumpida", "error", err, "retry_in", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	systemdDest     = "org.freedesktop.systemd1"
+	systemdPath     = "/org/freedesktop/systemd1"
+	systemdManager  = "org.freedesktop.systemd1.Manager"
+	systemdUnit     = "org.freedesktop.systemd1.Unit"
+	dbusProperties  = "org.freedesktop.DBus.Properties"
+	systemdUnitPath = systemdPath + "/unit/"
+)
+
+// systemdStates traduce ActiveState a los nombres que usa el agente en
+// Windows; el SubState va en Detail.
+var systemdStates = map[string]string{
+	"active":       "running",
+	"inactive":     "stopped",
+	"failed":       "failed",
+	"activating":   "start_pending",
+	"deactivating": "stop_pending",
+	"reloading":    "reloading",
+}
+
+// systemdUnitName agrega ".service" si el nombre configurado no tiene tipo.
+func systemdUnitName(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return name + ".service"
+}
+
+// systemdObjectPath codifica el nombre de la unidad como lo hace
+// sd_bus_path_encode: todo lo que no es alfanumérico pasa a _xx.
+func systemdObjectPath(unit string) string {
+	var b strings.Builder
+	b.WriteString(systemdUnitPath)
+	for i := 0; i < len(unit); i++ {
+		c := unit[i]
+		alnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !alnum || (i == 0 && c >= '0' && c <= '9') {
+			fmt.Fprintf(&b, "_%02x", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// watchServices se suscribe a PropertiesChanged de cada unidad en systemd.
+// Manager.Subscribe hace que systemd emita las señales para esta conexión.
+func watchServices(names []string, record func(name, state, detail string, at time.Time)) error {
+	bus, err := dialSystemBus()
+	if err != nil {
+		return fmt.Errorf("bus del sistema: %v", err)
+	}
+	defer bus.Close()
+
+	byPath := make(map[string]string, len(names))
+	for _, name := range names {
+		path := systemdObjectPath(systemdUnitName(name))
+		byPath[path] = name
+		rule := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='PropertiesChanged',path='%s'", systemdDest, dbusProperties, path)
+		if _, err := bus.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", rule); err != nil {
+			return fmt.Errorf("AddMatch '%s': %v", name, err)
+		}
+	}
+	if _, err := bus.Call(systemdDest, systemdPath, systemdManager, "Subscribe"); err != nil {
+		return fmt.Errorf("Subscribe: %v", err)
+	}
+
+	// Estado inicial, para que la primera transición tenga origen.
+	for path, name := range byPath {
+		active, err := getUnitProperty(bus, path, "ActiveState")
+		if err != nil {
+			continue
+		}
+		sub, _ := getUnitProperty(bus, path, "SubState")
+		record(name, systemdState(active), sub, time.Now())
+	}
+
+	for {
+		msg, err := bus.Read()
+		if err != nil {
+			return err
+		}
+		at := time.Now()
+		name, ok := byPath[msg.Path]
+		if !ok || msg.Type != dbusSignal || msg.Member != "PropertiesChanged" || len(msg.Body) < 2 {
+			continue
+		}
+		if iface, _ := msg.Body[0].(string); iface != systemdUnit {
+			continue
+		}
+		changed, _ := msg.Body[1].(map[string]interface{})
+		active, ok := changed["ActiveState"].(string)
+		if !ok {
+			continue
+		}
+		sub, _ := changed["SubState"].(string)
+		record(name, systemdState(active), sub, at)
+	}
+}
+
+func getUnitProperty(bus *dbusConn, path, property string) (string, error) {
+	reply, err := bus.Call(systemdDest, path, dbusProperties, "Get", systemdUnit, property)
+	if err != nil {
+		return "", err
+	}
+	if len(reply.Body) == 0 {
+		return "", fmt.Errorf("respuesta vacía")
+	}
+	value, _ := reply.Body[0].(string)
+	return value, nil
+}
+
+func systemdState(active string) string {
+	if state, ok := systemdStates[active]; ok {
+		return state
+	}
+	return active
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const serviceNotifyMask = windows.SERVICE_NOTIFY_STOPPED |
+	windows.SERVICE_NOTIFY_START_PENDING |
+	windows.SERVICE_NOTIFY_STOP_PENDING |
+	windows.SERVICE_NOTIFY_RUNNING |
+	windows.SERVICE_NOTIFY_CONTINUE_PENDING |
+	windows.SERVICE_NOTIFY_PAUSE_PENDING |
+	windows.SERVICE_NOTIFY_PAUSED |
+	windows.SERVICE_NOTIFY_DELETE_PENDING
+
+// serviceOpenRetry es cada cuánto se buscan los servicios configurados que
+// no existían.
+const serviceOpenRetry = time.Minute
+
+var windowsServiceStates = map[uint32]string{
+	windows.SERVICE_STOPPED:          "stopped",
+	windows.SERVICE_START_PENDING:    "start_pending",
+	windows.SERVICE_STOP_PENDING:     "stop_pending",
+	windows.SERVICE_RUNNING:          "running",
+	windows.SERVICE_CONTINUE_PENDING: "continue_pending",
+	windows.SERVICE_PAUSE_PENDING:    "pause_pending",
+	windows.SERVICE_PAUSED:           "paused",
+}
+
+type serviceSubscription struct {
+	name   string
+	handle windows.Handle
+	notify windows.SERVICE_NOTIFY
+}
+
+// serviceNotifyCallback se crea una sola vez: syscall.NewCallback tiene un
+// límite de callbacks por proceso. Windows completa SERVICE_NOTIFY antes de
+// llamarla, así que no necesita hacer nada: el bucle revisa
+// NotificationTriggered cuando SleepEx vuelve.
+var serviceNotifyCallback = syscall.NewCallback(func(p uintptr) uintptr {
+	return 0
+})
+
+// watchServices registra NotifyServiceStatusChange para cada servicio y
+// espera los avisos en un hilo fijo, porque las APC solo se entregan al hilo
+// que las pidió mientras está en una espera alertable.
+func watchServices(names []string, record func(name, state, detail string, at time.Time)) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return fmt.Errorf("OpenSCManager: %v", err)
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	var subs []*serviceSubscription
+	defer func() {
+		for _, sub := range subs {
+			windows.CloseServiceHandle(sub.handle)
+		}
+	}()
+
+	// Los servicios que todavía no existen se vuelven a buscar cada
+	// serviceOpenRetry, por si se instalan después de arrancar el agente.
+	missing := names
+	var lastOpen time.Time
+	for {
+		if len(missing) > 0 && time.Since(lastOpen) >= serviceOpenRetry {
+			var still []string
+			for _, name := range missing {
+				h, err := windows.OpenService(scm, windows.StringToUTF16Ptr(name), windows.SERVICE_QUERY_STATUS)
+				if err != nil {
+					still = append(still, name)
+					continue
+				}
+				sub := &serviceSubscription{name: name, handle: h}
+				// Con la máscara completa el primer aviso llega enseguida con
+				// el estado actual.
+				if err := sub.register(serviceNotifyMask); err != nil {
+					windows.CloseServiceHandle(h)
+					return fmt.Errorf("NotifyServiceStatusChange '%s': %v", name, err)
+				}
+				subs = append(subs, sub)
+			}
+			missing = still
+			lastOpen = time.Now()
+		}
+
+		timeout := uint32(windows.INFINITE)
+		if len(missing) > 0 {
+			timeout = uint32(serviceOpenRetry / time.Millisecond)
+		}
+		windows.SleepEx(timeout, true)
+		// La APC corre justo antes de que SleepEx vuelva.
+		at := time.Now()
+		for _, sub := range subs {
+			if sub.notify.NotificationTriggered == 0 {
+				continue
+			}
+			if sub.notify.NotificationStatus != uint32(windows.ERROR_SUCCESS) {
+				return fmt.Errorf("notificación de '%s' con error %d", sub.name, sub.notify.NotificationStatus)
+			}
+
+			status := sub.notify.ServiceStatus
+			if sub.notify.NotificationTriggered&windows.SERVICE_NOTIFY_DELETE_PENDING != 0 {
+				record(sub.name, "deleted", "", at)
+				return fmt.Errorf("el servicio '%s' fue eliminado", sub.name)
+			}
+			state, ok := windowsServiceStates[status.CurrentState]
+			if !ok {
+				state = fmt.Sprintf("state_%d", status.CurrentState)
+			}
+			detail := ""
+			if status.CurrentState == windows.SERVICE_STOPPED {
+				switch {
+				case status.Win32ExitCode == uint32(windows.ERROR_SERVICE_SPECIFIC_ERROR):
+					detail = fmt.Sprintf("exit_code=%d", status.ServiceSpecificExitCode)
+				case status.Win32ExitCode != 0:
+					detail = fmt.Sprintf("exit_code=%d", status.Win32ExitCode)
+				}
+			}
+			record(sub.name, state, detail, at)
+
+			// La notificación es de un solo uso: se vuelve a registrar fuera
+			// del callback, sin el estado recién informado. Si siguiera en la
+			// máscara, Windows avisaría de nuevo enseguida y el bucle no
+			// pararía de girar.
+			if err := sub.register(serviceNotifyMask &^ serviceNotifyBit(status.CurrentState)); err != nil {
+				return fmt.Errorf("NotifyServiceStatusChange '%s': %v", sub.name, err)
+			}
+		}
+	}
+}
+
+func (sub *serviceSubscription) register(mask uint32) error {
+	sub.notify = windows.SERVICE_NOTIFY{
+		Version:        windows.SERVICE_NOTIFY_STATUS_CHANGE,
+		NotifyCallback: serviceNotifyCallback,
+	}
+	return windows.NotifyServiceStatusChange(sub.handle, mask, &sub.notify)
+}
+
+// serviceNotifyBit devuelve el SERVICE_NOTIFY_* de un SERVICE_*: los estados
+// van de 1 a 7 y cada aviso es el bit state-1.
+func serviceNotifyBit(state uint32) uint32 {
+	if state < windows.SERVICE_STOPPED || state > windows.SERVICE_PAUSED {
+		return 0
+	}
+	return 1 << (state - 1)
+}
@@ -24,6 +24,11 @@ func (m *pirmonService) Execute(args []string, r <-chan svc.ChangeRequest, s cha
 	return false, 0
 }
 
+// detectService indica si el proceso lo inició el SCM.
+func detectService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
 func runService(name string, isDebug bool) {
 	err := svc.Run(name, &pirmonService{})
 	if err != nil {
@@ -0,0 +1,912 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos BER y PDUs de SNMP (RFC 3416).
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	snmpIPAddress  = 0x40
+	snmpCounter32  = 0x41
+	snmpGauge32    = 0x42
+	snmpTimeTicks  = 0x43
+	snmpCounter64  = 0x46
+	snmpNoSuchObj  = 0x80
+	snmpNoSuchInst = 0x81
+	snmpEndOfMIB   = 0x82
+	pduGetRequest  = 0xA0
+	pduGetNext     = 0xA1
+	pduResponse    = 0xA2
+	pduGetBulk     = 0xA5
+	pduReport      = 0xA8
+)
+
+const (
+	snmpVersion2c        = 1
+	snmpVersion3         = 3
+	snmpMaxRepetitions   = 20
+	snmpMaxWalk          = 1000
+	snmpMsgMaxSize       = 65507
+	snmpSecurityModelUSM = 3
+	snmpFlagAuth         = 0x01
+	snmpFlagPriv         = 0x02
+	snmpFlagReportable   = 0x04
+	snmpAuthParamsLength = 12
+)
+
+// snmpVar es un valor leído de un agente SNMP. Value es int64, uint64,
+// string u OID (string con puntos) según el tipo.
+type snmpVar struct {
+	OID   string
+	Type  byte
+	Value interface{}
+}
+
+func (v snmpVar) Int() int64 {
+	switch n := v.Value.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+func (v snmpVar) String() string {
+	if s, ok := v.Value.(string); ok {
+		return strings.TrimRight(s, "\x00")
+	}
+	return ""
+}
+
+// --- Codificación BER ---
+
+func berTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	n := len(content)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xFF:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xFFFF:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+func berInt(v int64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return berTLV(berInteger, b)
+}
+
+func berOctets(b []byte) []byte { return berTLV(berOctetString, b) }
+
+func berSeq(items ...[]byte) []byte { return berTLV(berSequence, bytes.Join(items, nil)) }
+
+func berEncodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("OID inválido '%s'", oid)
+	}
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("OID inválido '%s'", oid)
+		}
+		nums[i] = n
+	}
+	content := encodeBase128(nums[0]*40 + nums[1])
+	for _, n := range nums[2:] {
+		content = append(content, encodeBase128(n)...)
+	}
+	return berTLV(berOID, content), nil
+}
+
+func encodeBase128(n uint64) []byte {
+	b := []byte{byte(n & 0x7F)}
+	for n >>= 7; n > 0; n >>= 7 {
+		b = append([]byte{byte(n&0x7F) | 0x80}, b...)
+	}
+	return b
+}
+
+// --- Decodificación BER ---
+
+// berRead separa el primer TLV de data.
+func berRead(data []byte) (tag byte, content, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("BER truncado")
+	}
+	tag = data[0]
+	n := int(data[1])
+	pos := 2
+	if n&0x80 != 0 {
+		size := n & 0x7F
+		if size == 0 || size > 3 || len(data) < pos+size {
+			return 0, nil, nil, fmt.Errorf("longitud BER inválida")
+		}
+		n = 0
+		for _, b := range data[pos : pos+size] {
+			n = n<<8 | int(b)
+		}
+		pos += size
+	}
+	if len(data) < pos+n {
+		return 0, nil, nil, fmt.Errorf("BER truncado")
+	}
+	return tag, data[pos : pos+n], data[pos+n:], nil
+}
+
+// berExpect lee un TLV y verifica la etiqueta.
+func berExpect(data []byte, want byte) (content, rest []byte, err error) {
+	tag, content, rest, err := berRead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tag != want {
+		return nil, nil, fmt.Errorf("se esperaba BER 0x%02X y llegó 0x%02X", want, tag)
+	}
+	return content, rest, nil
+}
+
+func berParseInt(content []byte) int64 {
+	var v int64
+	if len(content) > 0 && content[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, b := range content {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+func berParseUint(content []byte) uint64 {
+	var v uint64
+	for _, b := range content {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func berParseOID(content []byte) string {
+	var parts []string
+	var n uint64
+	first := true
+	for _, b := range content {
+		n = n<<7 | uint64(b&0x7F)
+		if b&0x80 != 0 {
+			continue
+		}
+		if first {
+			a := n / 40
+			if a > 2 {
+				a = 2
+			}
+			parts = append(parts, strconv.FormatUint(a, 10), strconv.FormatUint(n-a*40, 10))
+			first = false
+		} else {
+			parts = append(parts, strconv.FormatUint(n, 10))
+		}
+		n = 0
+	}
+	return strings.Join(parts, ".")
+}
+
+func berReadInt(data []byte) (int64, []byte, error) {
+	content, rest, err := berExpect(data, berInteger)
+	if err != nil {
+		return 0, nil, err
+	}
+	return berParseInt(content), rest, nil
+}
+
+func berReadOctets(data []byte) ([]byte, []byte, error) {
+	return berExpect(data, berOctetString)
+}
+
+// --- PDU ---
+
+type snmpPDU struct {
+	tag         byte
+	requestID   int32
+	errorStatus int64
+	errorIndex  int64
+	vars        []snmpVar
+}
+
+func encodePDU(tag byte, requestID int32, a, b int64, oids []string) ([]byte, error) {
+	var binds [][]byte
+	for _, oid := range oids {
+		encoded, err := berEncodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		binds = append(binds, berSeq(encoded, berTLV(berNull, nil)))
+	}
+	return berTLV(tag, bytes.Join([][]byte{
+		berInt(int64(requestID)), berInt(a), berInt(b), berSeq(binds...),
+	}, nil)), nil
+}
+
+func decodePDU(data []byte) (snmpPDU, error) {
+	var pdu snmpPDU
+	tag, content, _, err := berRead(data)
+	if err != nil {
+		return pdu, err
+	}
+	pdu.tag = tag
+	id, content, err := berReadInt(content)
+	if err != nil {
+		return pdu, err
+	}
+	pdu.requestID = int32(id)
+	if pdu.errorStatus, content, err = berReadInt(content); err != nil {
+		return pdu, err
+	}
+	if pdu.errorIndex, content, err = berReadInt(content); err != nil {
+		return pdu, err
+	}
+	binds, _, err := berExpect(content, berSequence)
+	if err != nil {
+		return pdu, err
+	}
+	for len(binds) > 0 {
+		var bind []byte
+		if bind, binds, err = berExpect(binds, berSequence); err != nil {
+			return pdu, err
+		}
+		oidContent, valueData, err := berExpect(bind, berOID)
+		if err != nil {
+			return pdu, err
+		}
+		vtag, value, _, err := berRead(valueData)
+		if err != nil {
+			return pdu, err
+		}
+		v := snmpVar{OID: berParseOID(oidContent), Type: vtag}
+		switch vtag {
+		case berInteger:
+			v.Value = berParseInt(value)
+		case snmpCounter32, snmpGauge32, snmpTimeTicks, snmpCounter64:
+			v.Value = berParseUint(value)
+		case berOctetString:
+			v.Value = string(value)
+		case berOID:
+			v.Value = berParseOID(value)
+		case snmpIPAddress:
+			if len(value) == 4 {
+				v.Value = net.IP(value).String()
+			}
+		}
+		pdu.vars = append(pdu.vars, v)
+	}
+	return pdu, nil
+}
+
+// --- Cliente ---
+
+// snmpClient habla SNMP v2c o v3 (USM) con un agente por UDP.
+type snmpClient struct {
+	config    NetworkPrinterConfig
+	addr      string
+	conn      net.Conn
+	timeout   time.Duration
+	requestID int32
+	usm       *snmpUSM
+	// engineStale indica que el agente rechazó el engine guardado y hay que
+	// descubrirlo de nuevo en el próximo sondeo.
+	engineStale bool
+}
+
+// snmpEngine es lo que se descubre de un agente v3. Se guarda por dirección
+// entre sondeos: el descubrimiento cuesta un viaje más y localizar las
+// claves, dos hashes de 1 MB.
+type snmpEngine struct {
+	id         []byte
+	boots      int64
+	time       int64
+	discovered time.Time
+}
+
+var snmpEngines = struct {
+	sync.Mutex
+	m map[string]snmpEngine
+}{m: make(map[string]snmpEngine)}
+
+// snmpKeys guarda las claves localizadas por protocolo, password y engine ID.
+var snmpKeys = struct {
+	sync.Mutex
+	m map[string][]byte
+}{m: make(map[string][]byte)}
+
+func dialSNMP(config NetworkPrinterConfig, timeout time.Duration) (*snmpClient, error) {
+	addr := config.Address
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "161")
+	}
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &snmpClient{config: config, addr: addr, conn: conn, timeout: timeout}
+
+	switch config.Version {
+	case "", "2c":
+	case "3":
+		usm, err := newSNMPUSM(config)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.usm = usm
+		if c.loadEngine() {
+			err = usm.localizeKeys()
+		} else {
+			err = c.discoverEngine()
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("descubrimiento SNMPv3: %w", err)
+		}
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("versión SNMP no soportada '%s'", config.Version)
+	}
+	return c, nil
+}
+
+// Close guarda el engine del agente para el próximo sondeo, con el tiempo
+// que informó en la última respuesta.
+func (c *snmpClient) Close() error {
+	if c.usm != nil {
+		snmpEngines.Lock()
+		if c.engineStale || len(c.usm.engineID) == 0 {
+			delete(snmpEngines.m, c.addr)
+		} else {
+			snmpEngines.m[c.addr] = snmpEngine{
+				id:         c.usm.engineID,
+				boots:      c.usm.engineBoots,
+				time:       c.usm.engineTime,
+				discovered: c.usm.discovered,
+			}
+		}
+		snmpEngines.Unlock()
+	}
+	return c.conn.Close()
+}
+
+func (c *snmpClient) loadEngine() bool {
+	snmpEngines.Lock()
+	e, ok := snmpEngines.m[c.addr]
+	snmpEngines.Unlock()
+	if !ok {
+		return false
+	}
+	c.usm.engineID = e.id
+	c.usm.engineBoots = e.boots
+	c.usm.engineTime = e.time
+	c.usm.discovered = e.discovered
+	return true
+}
+
+func (c *snmpClient) nextID() int32 {
+	c.requestID++
+	return c.requestID
+}
+
+// exchange envía msg y lee respuestas hasta que accept acepte una o venza el
+// timeout. Las que accept rechaza se descartan y se sigue leyendo: respuestas
+// tardías a un pedido anterior, paquetes que no se pueden decodificar o sin
+// la autenticación esperada. Así un paquete suelto no hace fallar el pedido.
+func (c *snmpClient) exchange(msg []byte, accept func(raw []byte) (snmpPDU, error)) (snmpPDU, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return snmpPDU{}, err
+	}
+	if _, err := c.conn.Write(msg); err != nil {
+		return snmpPDU{}, err
+	}
+	buf := make([]byte, snmpMsgMaxSize)
+	var rejected error
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			if rejected != nil {
+				return snmpPDU{}, fmt.Errorf("%w (última respuesta descartada: %v)", err, rejected)
+			}
+			return snmpPDU{}, err
+		}
+		resp, err := accept(buf[:n])
+		if err == nil {
+			return resp, nil
+		}
+		rejected = err
+	}
+}
+
+// acceptV3 devuelve la función de aceptación de exchange para un mensaje
+// SNMPv3 con msgID id. Los reportes se aceptan sin mirar el request-id (el
+// agente puede no haber podido leer el PDU) pero sí el msgID. El engine solo
+// se actualiza con respuestas autenticadas, o en el descubrimiento.
+func (c *snmpClient) acceptV3(id int32, required byte) func(raw []byte) (snmpPDU, error) {
+	return func(raw []byte) (snmpPDU, error) {
+		m, err := c.usm.unwrap(raw, required)
+		if err != nil {
+			return snmpPDU{}, err
+		}
+		if m.msgID != int64(id) {
+			return snmpPDU{}, fmt.Errorf("msgID %d, se esperaba %d", m.msgID, id)
+		}
+		if m.pdu.tag != pduReport && m.pdu.requestID != id {
+			return snmpPDU{}, fmt.Errorf("request-id %d, se esperaba %d", m.pdu.requestID, id)
+		}
+		if m.flags&snmpFlagAuth != 0 || required&snmpFlagAuth == 0 {
+			c.usm.updateEngine(m)
+		}
+		return m.pdu, nil
+	}
+}
+
+// request envía un PDU y devuelve la respuesta con el mismo request-id.
+func (c *snmpClient) request(tag byte, a, b int64, oids []string) (snmpPDU, error) {
+	id := c.nextID()
+	pdu, err := encodePDU(tag, id, a, b, oids)
+	if err != nil {
+		return snmpPDU{}, err
+	}
+
+	var resp snmpPDU
+	if c.usm != nil {
+		msg, err := c.usm.wrap(id, pdu, c.usm.securityFlags())
+		if err != nil {
+			return resp, err
+		}
+		resp, err = c.exchange(msg, c.acceptV3(id, c.usm.securityFlags()&^snmpFlagReportable))
+		if err != nil {
+			return resp, err
+		}
+		if resp.tag == pduReport {
+			c.engineStale = true
+			return resp, fmt.Errorf("el agente respondió con un reporte USM %v", reportOIDs(resp))
+		}
+	} else {
+		msg := berSeq(berInt(snmpVersion2c), berOctets([]byte(c.config.Community)), pdu)
+		resp, err = c.exchange(msg, func(raw []byte) (snmpPDU, error) {
+			body, _, err := berExpect(raw, berSequence)
+			if err != nil {
+				return snmpPDU{}, err
+			}
+			if _, body, err = berReadInt(body); err != nil {
+				return snmpPDU{}, err
+			}
+			if _, body, err = berReadOctets(body); err != nil {
+				return snmpPDU{}, err
+			}
+			pdu, err := decodePDU(body)
+			if err != nil {
+				return pdu, err
+			}
+			if pdu.requestID != id {
+				return pdu, fmt.Errorf("request-id %d, se esperaba %d", pdu.requestID, id)
+			}
+			return pdu, nil
+		})
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	if resp.errorStatus != 0 {
+		return resp, fmt.Errorf("error SNMP %d en la variable %d", resp.errorStatus, resp.errorIndex)
+	}
+	return resp, nil
+}
+
+// securityFlags devuelve msgFlags según las claves configuradas.
+func (u *snmpUSM) securityFlags() byte {
+	flags := byte(snmpFlagReportable)
+	if u.authKey != nil {
+		flags |= snmpFlagAuth
+	}
+	if u.privKey != nil {
+		flags |= snmpFlagPriv
+	}
+	return flags
+}
+
+func reportOIDs(pdu snmpPDU) []string {
+	var oids []string
+	for _, v := range pdu.vars {
+		oids = append(oids, v.OID)
+	}
+	return oids
+}
+
+// Get lee variables puntuales. Las que no existen se omiten.
+func (c *snmpClient) Get(oids ...string) ([]snmpVar, error) {
+	resp, err := c.request(pduGetRequest, 0, 0, oids)
+	if err != nil {
+		return nil, err
+	}
+	var vars []snmpVar
+	for _, v := range resp.vars {
+		if v.Type != snmpNoSuchObj && v.Type != snmpNoSuchInst && v.Type != snmpEndOfMIB {
+			vars = append(vars, v)
+		}
+	}
+	return vars, nil
+}
+
+// Walk recorre un subárbol con GetBulk.
+func (c *snmpClient) Walk(root string) ([]snmpVar, error) {
+	root = strings.TrimPrefix(root, ".")
+	prefix := root + "."
+	var vars []snmpVar
+	next := root
+	for len(vars) < snmpMaxWalk {
+		resp, err := c.request(pduGetBulk, 0, snmpMaxRepetitions, []string{next})
+		if err != nil {
+			return vars, err
+		}
+		if len(resp.vars) == 0 {
+			return vars, nil
+		}
+		for _, v := range resp.vars {
+			if v.Type == snmpEndOfMIB || !strings.HasPrefix(v.OID, prefix) || v.OID == next {
+				return vars, nil
+			}
+			vars = append(vars, v)
+			next = v.OID
+		}
+	}
+	return vars, nil
+}
+
+// --- USM (RFC 3414, AES según RFC 3826) ---
+
+type snmpUSM struct {
+	user         string
+	authProtocol string
+	newHash      func() hash.Hash
+	authPassword string
+	privPassword string
+	authKey      []byte
+	privKey      []byte
+
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	discovered  time.Time
+	salt        uint64
+}
+
+func newSNMPUSM(config NetworkPrinterConfig) (*snmpUSM, error) {
+	u := &snmpUSM{
+		user:         config.User,
+		authProtocol: strings.ToUpper(config.AuthProtocol),
+		authPassword: config.AuthPassword,
+		privPassword: config.PrivPassword,
+	}
+	switch u.authProtocol {
+	case "":
+		if config.PrivProtocol != "" {
+			return nil, fmt.Errorf("priv_protocol requiere auth_protocol")
+		}
+	case "MD5":
+		u.newHash = md5.New
+	case "SHA":
+		u.newHash = sha1.New
+	default:
+		return nil, fmt.Errorf("auth_protocol no soportado '%s'", config.AuthProtocol)
+	}
+	switch strings.ToUpper(config.PrivProtocol) {
+	case "", "AES":
+	default:
+		return nil, fmt.Errorf("priv_protocol no soportado '%s'", config.PrivProtocol)
+	}
+	var seed [8]byte
+	rand.Read(seed[:])
+	u.salt = binary.BigEndian.Uint64(seed[:])
+	return u, nil
+}
+
+// passwordToKey implementa el algoritmo de localización de claves de RFC 3414
+// (A.2): se hashea 1 MB del password repetido y se combina con el engine ID.
+func passwordToKey(newHash func() hash.Hash, password string, engineID []byte) []byte {
+	h := newHash()
+	pw := []byte(password)
+	buf := make([]byte, 64)
+	for i, n := 0, 0; n < 1048576; n += 64 {
+		for j := range buf {
+			buf[j] = pw[i%len(pw)]
+			i++
+		}
+		h.Write(buf)
+	}
+	ku := h.Sum(nil)
+
+	h = newHash()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil)
+}
+
+// discoverEngine obtiene engine ID, boots y time del agente con un GetRequest
+// vacío sin autenticación, como indica RFC 3414 (4).
+func (c *snmpClient) discoverEngine() error {
+	u := c.usm
+	id := c.nextID()
+	pdu, _ := encodePDU(pduGetRequest, id, 0, 0, nil)
+	msg, err := u.wrap(id, pdu, snmpFlagReportable)
+	if err != nil {
+		return err
+	}
+	_, err = c.exchange(msg, c.acceptV3(id, 0))
+	if err != nil {
+		return err
+	}
+	if len(u.engineID) == 0 {
+		return fmt.Errorf("el agente no informó engine ID")
+	}
+	return u.localizeKeys()
+}
+
+// localizeKeys calcula las claves para el engine ID actual, o las toma de
+// snmpKeys si ya se calcularon.
+func (u *snmpUSM) localizeKeys() error {
+	if u.newHash == nil {
+		return nil
+	}
+	if u.authPassword == "" {
+		return fmt.Errorf("falta auth_password")
+	}
+	u.authKey = cachedPasswordToKey(u.authProtocol, u.newHash, u.authPassword, u.engineID)
+	if u.privPassword != "" {
+		u.privKey = cachedPasswordToKey(u.authProtocol, u.newHash, u.privPassword, u.engineID)[:16]
+	}
+	return nil
+}
+
+func cachedPasswordToKey(protocol string, newHash func() hash.Hash, password string, engineID []byte) []byte {
+	k := protocol + "\x00" + password + "\x00" + string(engineID)
+	snmpKeys.Lock()
+	key, ok := snmpKeys.m[k]
+	snmpKeys.Unlock()
+	if ok {
+		return key
+	}
+	key = passwordToKey(newHash, password, engineID)
+	snmpKeys.Lock()
+	snmpKeys.m[k] = key
+	snmpKeys.Unlock()
+	return key
+}
+
+func (u *snmpUSM) currentTime() int64 {
+	if u.discovered.IsZero() {
+		return u.engineTime
+	}
+	return u.engineTime + int64(time.Since(u.discovered).Seconds())
+}
+
+// wrap arma un mensaje SNMPv3 con el PDU, cifrándolo y firmándolo según flags.
+func (u *snmpUSM) wrap(msgID int32, pdu []byte, flags byte) ([]byte, error) {
+	scoped := berSeq(berOctets(u.engineID), berOctets(nil), pdu)
+
+	var privParams []byte
+	msgData := scoped
+	if flags&snmpFlagPriv != 0 {
+		u.salt++
+		privParams = make([]byte, 8)
+		binary.BigEndian.PutUint64(privParams, u.salt)
+		block, err := aes.NewCipher(u.privKey)
+		if err != nil {
+			return nil, err
+		}
+		encrypted := make([]byte, len(scoped))
+		cipher.NewCFBEncrypter(block, u.aesIV(u.engineBoots, u.currentTime(), privParams)).XORKeyStream(encrypted, scoped)
+		msgData = berOctets(encrypted)
+	}
+
+	authParams := []byte{}
+	if flags&snmpFlagAuth != 0 {
+		authParams = make([]byte, snmpAuthParamsLength)
+	}
+	user := u.user
+	if flags&snmpFlagAuth == 0 && len(u.engineID) == 0 {
+		user = ""
+	}
+	secParams := berSeq(
+		berOctets(u.engineID),
+		berInt(u.engineBoots),
+		berInt(u.currentTime()),
+		berOctets([]byte(user)),
+		berOctets(authParams),
+		berOctets(privParams),
+	)
+	msg := berSeq(
+		berInt(snmpVersion3),
+		berSeq(berInt(int64(msgID)), berInt(snmpMsgMaxSize), berOctets([]byte{flags}), berInt(snmpSecurityModelUSM)),
+		berOctets(secParams),
+		msgData,
+	)
+
+	if flags&snmpFlagAuth != 0 {
+		placeholder := berOctets(authParams)
+		at := bytes.Index(msg, placeholder)
+		if at < 0 {
+			return nil, fmt.Errorf("no se encontró el campo de autenticación")
+		}
+		mac := hmac.New(u.newHash, u.authKey)
+		mac.Write(msg)
+		copy(msg[at+2:], mac.Sum(nil)[:snmpAuthParamsLength])
+	}
+	return msg, nil
+}
+
+// aesIV arma el IV de AES-CFB: boots, time y salt (RFC 3826, 3.1.2.1).
+func (u *snmpUSM) aesIV(boots, engineTime int64, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv[0:], uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+// snmpV3Message es una respuesta SNMPv3 ya verificada y descifrada.
+type snmpV3Message struct {
+	msgID      int64
+	flags      byte
+	engineID   []byte
+	boots      int64
+	engineTime int64
+	pdu        snmpPDU
+}
+
+// updateEngine guarda engine ID, boots y time que informó el agente.
+func (u *snmpUSM) updateEngine(m snmpV3Message) {
+	u.engineID = append([]byte(nil), m.engineID...)
+	u.engineBoots = m.boots
+	u.engineTime = m.engineTime
+	u.discovered = time.Now()
+}
+
+// unwrap verifica y descifra un mensaje SNMPv3. required son los bits auth y
+// priv que debe traer la respuesta: con menos seguridad solo se acepta un
+// reporte, que el agente manda sin autenticar cuando no reconoce el engine o
+// el usuario. No modifica el estado del engine; eso lo decide quien llama.
+func (u *snmpUSM) unwrap(raw []byte, required byte) (snmpV3Message, error) {
+	var m snmpV3Message
+	body, _, err := berExpect(raw, berSequence)
+	if err != nil {
+		return m, err
+	}
+	if _, body, err = berReadInt(body); err != nil {
+		return m, err
+	}
+	header, body, err := berExpect(body, berSequence)
+	if err != nil {
+		return m, err
+	}
+	if m.msgID, header, err = berReadInt(header); err != nil {
+		return m, err
+	}
+	if _, header, err = berReadInt(header); err != nil {
+		return m, err
+	}
+	flagBytes, _, err := berReadOctets(header)
+	if err != nil || len(flagBytes) != 1 {
+		return m, fmt.Errorf("msgFlags inválido")
+	}
+	m.flags = flagBytes[0]
+
+	secRaw, body, err := berReadOctets(body)
+	if err != nil {
+		return m, err
+	}
+	sec, _, err := berExpect(secRaw, berSequence)
+	if err != nil {
+		return m, err
+	}
+	if m.engineID, sec, err = berReadOctets(sec); err != nil {
+		return m, err
+	}
+	if m.boots, sec, err = berReadInt(sec); err != nil {
+		return m, err
+	}
+	if m.engineTime, sec, err = berReadInt(sec); err != nil {
+		return m, err
+	}
+	if _, sec, err = berReadOctets(sec); err != nil {
+		return m, err
+	}
+	authParams, sec, err := berReadOctets(sec)
+	if err != nil {
+		return m, err
+	}
+	privParams, _, err := berReadOctets(sec)
+	if err != nil {
+		return m, err
+	}
+
+	if m.flags&snmpFlagAuth != 0 {
+		if u.authKey == nil || len(authParams) != snmpAuthParamsLength {
+			return m, fmt.Errorf("respuesta autenticada inesperada")
+		}
+		at := bytes.Index(raw, authParams)
+		check := append([]byte(nil), raw...)
+		copy(check[at:at+snmpAuthParamsLength], make([]byte, snmpAuthParamsLength))
+		mac := hmac.New(u.newHash, u.authKey)
+		mac.Write(check)
+		if !hmac.Equal(mac.Sum(nil)[:snmpAuthParamsLength], authParams) {
+			return m, fmt.Errorf("firma SNMPv3 inválida")
+		}
+	}
+
+	scoped := body
+	if m.flags&snmpFlagPriv != 0 {
+		encrypted, _, err := berReadOctets(body)
+		if err != nil {
+			return m, err
+		}
+		if u.privKey == nil || len(privParams) != 8 {
+			return m, fmt.Errorf("respuesta cifrada inesperada")
+		}
+		block, err := aes.NewCipher(u.privKey)
+		if err != nil {
+			return m, err
+		}
+		scoped = make([]byte, len(encrypted))
+		cipher.NewCFBDecrypter(block, u.aesIV(m.boots, m.engineTime, privParams)).XORKeyStream(scoped, encrypted)
+	}
+
+	content, _, err := berExpect(scoped, berSequence)
+	if err != nil {
+		return m, err
+	}
+	if _, content, err = berReadOctets(content); err != nil {
+		return m, err
+	}
+	if _, content, err = berReadOctets(content); err != nil {
+		return m, err
+	}
+	if m.pdu, err = decodePDU(content); err != nil {
+		return m, err
+	}
+
+	required &= snmpFlagAuth | snmpFlagPriv
+	if m.flags&required != required && m.pdu.tag != pduReport {
+		return m, fmt.Errorf("respuesta con msgFlags 0x%02X, se requiere 0x%02X", m.flags, required)
+	}
+	return m, nil
+}
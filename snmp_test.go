@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPasswordToKey(t *testing.T) {
+	// Vectores de RFC 3414, A.3.1 y A.3.2.
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	tests := []struct {
+		name    string
+		newHash func() hash.Hash
+		want    string
+	}{
+		{"MD5", md5.New, "526f5eed9fcce26f8964c2930787d82b"},
+		{"SHA", sha1.New, "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(passwordToKey(tt.newHash, "maplesyrup", engineID)); got != tt.want {
+			t.Errorf("%s: passwordToKey = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBEROIDRoundTrip(t *testing.T) {
+	for _, oid := range []string{"1.3.6.1.2.1.43.11.1.1.9.1.1", "2.999.3", "1.3.6.1.4.1.11.2.3.9.4.2.1.1.16.1.1.0"} {
+		encoded, err := berEncodeOID("." + oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, content, _, err := berRead(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := berParseOID(content); got != oid {
+			t.Errorf("OID %s decodificado como %s", oid, got)
+		}
+	}
+}
+
+// --- Agente de prueba ---
+
+var testMIB = map[string]snmpVar{
+	"1.3.6.1.2.1.1.1.0":             {Type: berOctetString, Value: "HP LaserJet"},
+	"1.3.6.1.2.1.25.3.5.1.1.1":      {Type: berInteger, Value: int64(3)},
+	"1.3.6.1.2.1.43.10.2.1.4.1.1":   {Type: snmpCounter32, Value: uint64(12345)},
+	"1.3.6.1.2.1.43.11.1.1.9.1.1":   {Type: berInteger, Value: int64(80)},
+	"1.3.6.1.2.1.43.11.1.1.9.1.2":   {Type: berInteger, Value: int64(-3)},
+	"1.3.6.1.2.1.43.11.1.1.9.1.10":  {Type: berInteger, Value: int64(5)},
+	"1.3.6.1.2.1.43.11.1.1.10.1.1":  {Type: berInteger, Value: int64(100)},
+	"1.3.6.1.2.1.43.18.1.1.8.1.100": {Type: snmpGauge32, Value: uint64(7)},
+}
+
+func compareOIDs(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			return na - nb
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func sortedMIB() []string {
+	oids := make([]string, 0, len(testMIB))
+	for oid := range testMIB {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool { return compareOIDs(oids[i], oids[j]) < 0 })
+	return oids
+}
+
+func berVarBind(oid string, tag byte, value interface{}) []byte {
+	encoded, _ := berEncodeOID(oid)
+	var v []byte
+	switch value := value.(type) {
+	case int64:
+		v = berInt(value)
+	case uint64:
+		b := berInt(int64(value))
+		b[0] = tag
+		v = b
+	case string:
+		v = berOctets([]byte(value))
+	default:
+		v = berTLV(tag, nil)
+	}
+	return berSeq(encoded, v)
+}
+
+// answer arma el PDU de respuesta a un GetRequest o GetBulk.
+func answer(req snmpPDU) []byte {
+	var binds [][]byte
+	switch req.tag {
+	case pduGetRequest:
+		for _, v := range req.vars {
+			if mv, ok := testMIB[v.OID]; ok {
+				binds = append(binds, berVarBind(v.OID, mv.Type, mv.Value))
+			} else {
+				binds = append(binds, berVarBind(v.OID, snmpNoSuchObj, nil))
+			}
+		}
+	case pduGetBulk:
+		// Sin non-repeaters; max-repetitions va en error-index.
+		next := req.vars[0].OID
+		oids := sortedMIB()
+		for n := int64(0); n < req.errorIndex; n++ {
+			i := sort.Search(len(oids), func(i int) bool { return compareOIDs(oids[i], next) > 0 })
+			if i == len(oids) {
+				binds = append(binds, berVarBind(next, snmpEndOfMIB, nil))
+				break
+			}
+			next = oids[i]
+			mv := testMIB[next]
+			binds = append(binds, berVarBind(next, mv.Type, mv.Value))
+		}
+	}
+	return berTLV(pduResponse, bytes.Join([][]byte{
+		berInt(int64(req.requestID)), berInt(0), berInt(0), berSeq(binds...),
+	}, nil))
+}
+
+// testAgent responde v2c con la comunidad "public" y v3 con usm. Con
+// stale, antes de cada respuesta manda otra con un request-id viejo.
+type testAgent struct {
+	pc    net.PacketConn
+	usm   *snmpUSM
+	stale bool
+	// spoof manda, antes de cada respuesta v3, basura, una respuesta falsa
+	// sin autenticar con el mismo msgID y un reporte con otro msgID.
+	spoof bool
+
+	mu          sync.Mutex
+	discoveries int
+}
+
+func startTestAgent(t *testing.T, a *testAgent) *testAgent {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.pc = pc
+	t.Cleanup(func() { pc.Close() })
+	go a.serve()
+	return a
+}
+
+func (a *testAgent) addr() string { return a.pc.LocalAddr().String() }
+
+func (a *testAgent) discoveryCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.discoveries
+}
+
+func (a *testAgent) serve() {
+	buf := make([]byte, snmpMsgMaxSize)
+	for {
+		n, from, err := a.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, out := range a.handle(append([]byte(nil), buf[:n]...)) {
+			a.pc.WriteTo(out, from)
+		}
+	}
+}
+
+func (a *testAgent) handle(raw []byte) [][]byte {
+	body, _, err := berExpect(raw, berSequence)
+	if err != nil {
+		return nil
+	}
+	version, body, err := berReadInt(body)
+	if err != nil {
+		return nil
+	}
+
+	if version == snmpVersion2c {
+		community, body, err := berReadOctets(body)
+		if err != nil || string(community) != "public" {
+			return nil
+		}
+		req, err := decodePDU(body)
+		if err != nil {
+			return nil
+		}
+		reply := func(req snmpPDU) []byte {
+			return berSeq(berInt(snmpVersion2c), berOctets(community), answer(req))
+		}
+		var out [][]byte
+		if a.stale {
+			old := req
+			old.requestID -= 100
+			out = append(out, reply(old))
+		}
+		return append(out, reply(req))
+	}
+	return a.handleV3(raw)
+}
+
+// handleV3 usa una copia del USM del agente para leer el pedido.
+func (a *testAgent) handleV3(raw []byte) [][]byte {
+	msgID, flags, engineID := parseTestV3Header(raw)
+	a.mu.Lock()
+	usm := a.usm
+	a.mu.Unlock()
+	req := *usm
+
+	if flags&snmpFlagAuth == 0 || !bytes.Equal(engineID, usm.engineID) {
+		// Descubrimiento, o un engine que el agente no reconoce: reporte
+		// sin autenticar con usmStatsUnknownEngineIDs.
+		requestID := int32(0)
+		if flags&snmpFlagAuth == 0 {
+			if m, err := req.unwrap(raw, 0); err == nil {
+				requestID = m.pdu.requestID
+			}
+			a.mu.Lock()
+			a.discoveries++
+			a.mu.Unlock()
+		}
+		report := berTLV(pduReport, bytes.Join([][]byte{
+			berInt(int64(requestID)), berInt(0), berInt(0),
+			berSeq(berVarBind("1.3.6.1.6.3.15.1.1.4.0", snmpCounter32, uint64(1))),
+		}, nil))
+		out, _ := usm.wrap(int32(msgID), report, 0)
+		return [][]byte{out}
+	}
+
+	m, err := req.unwrap(raw, 0)
+	if err != nil {
+		return nil
+	}
+	out, err := usm.wrap(int32(msgID), answer(m.pdu), flags&^snmpFlagReportable)
+	if err != nil {
+		return nil
+	}
+	if !a.spoof {
+		return [][]byte{out}
+	}
+
+	evil := *usm
+	evil.engineID = []byte("engine-falso")
+	evil.engineBoots = 99
+	var binds [][]byte
+	for _, v := range m.pdu.vars {
+		binds = append(binds, berVarBind(v.OID, berOctetString, "falso"))
+	}
+	forged, _ := evil.wrap(int32(msgID), berTLV(pduResponse, bytes.Join([][]byte{
+		berInt(int64(m.pdu.requestID)), berInt(0), berInt(0), berSeq(binds...),
+	}, nil)), 0)
+	stray, _ := evil.wrap(int32(msgID)+1000, berTLV(pduReport, bytes.Join([][]byte{
+		berInt(0), berInt(0), berInt(0),
+		berSeq(berVarBind("1.3.6.1.6.3.15.1.1.2.0", snmpCounter32, uint64(1))),
+	}, nil)), 0)
+	return [][]byte{[]byte("\x30\x03basura"), forged, stray, out}
+}
+
+func parseTestV3Header(raw []byte) (msgID int64, flags byte, engineID []byte) {
+	body, _, _ := berExpect(raw, berSequence)
+	_, body, _ = berReadInt(body)
+	header, body, _ := berExpect(body, berSequence)
+	msgID, header, _ = berReadInt(header)
+	_, header, _ = berReadInt(header)
+	flagBytes, _, _ := berReadOctets(header)
+	if len(flagBytes) == 1 {
+		flags = flagBytes[0]
+	}
+	secRaw, _, _ := berReadOctets(body)
+	sec, _, _ := berExpect(secRaw, berSequence)
+	engineID, _, _ = berReadOctets(sec)
+	return msgID, flags, engineID
+}
+
+func newTestAgentUSM(t *testing.T, config NetworkPrinterConfig, engineID string) *snmpUSM {
+	t.Helper()
+	u, err := newSNMPUSM(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.engineID = []byte(engineID)
+	u.engineBoots = 3
+	u.engineTime = 5000
+	u.discovered = time.Now()
+	if err := u.localizeKeys(); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// --- Cliente contra el agente ---
+
+func checkGetAndWalk(t *testing.T, c *snmpClient) {
+	t.Helper()
+	vars, err := c.Get("1.3.6.1.2.1.1.1.0", "1.3.6.1.2.1.1.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || vars[0].OID != "1.3.6.1.2.1.1.1.0" || vars[0].String() != "HP LaserJet" {
+		t.Fatalf("Get devolvió %+v", vars)
+	}
+
+	vars, err = c.Walk(".1.3.6.1.2.1.43.11.1.1.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range vars {
+		got = append(got, v.OID+"="+strconv.FormatInt(v.Int(), 10))
+	}
+	want := "1.3.6.1.2.1.43.11.1.1.9.1.1=80 1.3.6.1.2.1.43.11.1.1.9.1.2=-3 1.3.6.1.2.1.43.11.1.1.9.1.10=5"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("Walk devolvió %v", got)
+	}
+
+	vars, err = c.Walk("1.3.6.1.2.1.43.18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || vars[0].Type != snmpGauge32 || vars[0].Int() != 7 {
+		t.Fatalf("Walk hasta el final de la MIB devolvió %+v", vars)
+	}
+}
+
+func TestSNMPv2c(t *testing.T) {
+	agent := startTestAgent(t, &testAgent{})
+	c, err := dialSNMP(NetworkPrinterConfig{Address: agent.addr(), Community: "public"}, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkGetAndWalk(t, c)
+}
+
+func TestSNMPv2cDiscardsStaleResponses(t *testing.T) {
+	agent := startTestAgent(t, &testAgent{stale: true})
+	c, err := dialSNMP(NetworkPrinterConfig{Address: agent.addr(), Community: "public"}, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkGetAndWalk(t, c)
+}
+
+func TestSNMPv2cTimeout(t *testing.T) {
+	agent := startTestAgent(t, &testAgent{})
+	c, err := dialSNMP(NetworkPrinterConfig{Address: agent.addr(), Community: "private"}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Get("1.3.6.1.2.1.1.1.0"); err == nil {
+		t.Fatal("se esperaba timeout con una comunidad que el agente ignora")
+	}
+}
+
+func TestSNMPv3AuthPriv(t *testing.T) {
+	for _, proto := range []string{"MD5", "SHA"} {
+		t.Run(proto, func(t *testing.T) {
+			config := NetworkPrinterConfig{
+				Version: "3", User: "monitor",
+				AuthProtocol: proto, AuthPassword: "authpass1",
+				PrivProtocol: "AES", PrivPassword: "privpass1",
+			}
+			agent := startTestAgent(t, &testAgent{usm: newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-1")})
+			config.Address = agent.addr()
+
+			c, err := dialSNMP(config, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGetAndWalk(t, c)
+			c.Close()
+
+			// El segundo sondeo usa el engine guardado, sin descubrir.
+			c, err = dialSNMP(config, 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGetAndWalk(t, c)
+			c.Close()
+			if n := agent.discoveryCount(); n != 1 {
+				t.Fatalf("%d descubrimientos, se esperaba 1", n)
+			}
+		})
+	}
+}
+
+func TestSNMPv3AuthNoPriv(t *testing.T) {
+	config := NetworkPrinterConfig{Version: "3", User: "monitor", AuthProtocol: "SHA", AuthPassword: "authpass1"}
+	agent := startTestAgent(t, &testAgent{usm: newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-2")})
+	config.Address = agent.addr()
+
+	c, err := dialSNMP(config, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.usm.securityFlags() != snmpFlagAuth|snmpFlagReportable {
+		t.Fatalf("flags 0x%02X", c.usm.securityFlags())
+	}
+	checkGetAndWalk(t, c)
+}
+
+// TestSNMPv3EngineChanged simula un agente reemplazado: el engine guardado
+// ya no sirve, el reporte marca el engine y el sondeo siguiente lo vuelve
+// a descubrir.
+func TestSNMPv3EngineChanged(t *testing.T) {
+	config := NetworkPrinterConfig{
+		Version: "3", User: "monitor",
+		AuthProtocol: "SHA", AuthPassword: "authpass1",
+		PrivProtocol: "AES", PrivPassword: "privpass1",
+	}
+	agent := startTestAgent(t, &testAgent{usm: newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-3")})
+	config.Address = agent.addr()
+
+	c, err := dialSNMP(config, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	agent.mu.Lock()
+	agent.usm = newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-nuevo")
+	agent.mu.Unlock()
+
+	c, err = dialSNMP(config, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("1.3.6.1.2.1.1.1.0"); err == nil {
+		t.Fatal("se esperaba el reporte por engine desconocido")
+	}
+	c.Close()
+
+	c, err = dialSNMP(config, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkGetAndWalk(t, c)
+	if n := agent.discoveryCount(); n != 2 {
+		t.Fatalf("%d descubrimientos, se esperaba 2", n)
+	}
+}
+
+// TestSNMPv3RejectsUnauthenticatedReplies comprueba que una respuesta sin
+// autenticar no reemplaza a la autenticada ni cambia el engine, y que un
+// reporte con otro msgID tampoco hace fallar el pedido.
+func TestSNMPv3RejectsUnauthenticatedReplies(t *testing.T) {
+	config := NetworkPrinterConfig{
+		Version: "3", User: "monitor",
+		AuthProtocol: "SHA", AuthPassword: "authpass1",
+		PrivProtocol: "AES", PrivPassword: "privpass1",
+	}
+	agent := startTestAgent(t, &testAgent{usm: newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-4"), spoof: true})
+	config.Address = agent.addr()
+
+	c, err := dialSNMP(config, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	checkGetAndWalk(t, c)
+	if string(c.usm.engineID) != "\x80\x00\x1f\x88\x04agente-4" || c.usm.engineBoots != 3 || c.engineStale {
+		t.Fatalf("engine modificado por una respuesta falsa: %q boots %d stale %v", c.usm.engineID, c.usm.engineBoots, c.engineStale)
+	}
+}
+
+func TestSNMPv3UnwrapRequiresSecurityLevel(t *testing.T) {
+	config := NetworkPrinterConfig{Version: "3", User: "monitor", AuthProtocol: "SHA", AuthPassword: "authpass1"}
+	agent := newTestAgentUSM(t, config, "\x80\x00\x1f\x88\x04agente-5")
+	client := *agent
+
+	response := answer(snmpPDU{tag: pduGetRequest, requestID: 7, vars: []snmpVar{{OID: "1.3.6.1.2.1.1.1.0"}}})
+	plain, _ := agent.wrap(7, response, 0)
+	if _, err := client.unwrap(plain, snmpFlagAuth); err == nil {
+		t.Fatal("se aceptó una respuesta sin autenticar")
+	}
+	if _, err := client.unwrap(plain, 0); err != nil {
+		t.Fatalf("sin seguridad requerida: %v", err)
+	}
+
+	signed, _ := agent.wrap(7, response, snmpFlagAuth)
+	signed[len(signed)-1] ^= 0xFF
+	if _, err := client.unwrap(signed, snmpFlagAuth); err == nil {
+		t.Fatal("se aceptó una respuesta con la firma alterada")
+	}
+
+	report := berTLV(pduReport, bytes.Join([][]byte{berInt(0), berInt(0), berInt(0), berSeq()}, nil))
+	plainReport, _ := agent.wrap(7, report, 0)
+	m, err := client.unwrap(plainReport, snmpFlagAuth)
+	if err != nil || m.pdu.tag != pduReport || m.msgID != 7 {
+		t.Fatalf("reporte sin autenticar: %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type SoftwareItem struct {
+	Kind        string `json:"kind"` // program | hotfix | package
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Description string `json:"description,omitempty"`
+	InstallDate string `json:"install_date,omitempty"` // YYYY-MM-DD
+	Arch        string `json:"arch,omitempty"`
+}
+
+// key identifica un ítem entre snapshots, sin la versión: una actualización
+// de paquete se reporta como actualizado.
+func (s SoftwareItem) key() string {
+	return s.Kind + "|" + s.Name + "|" + s.Arch
+}
+
+// versionKey agrega la versión a key. Se usa para los paquetes que tienen
+// varias versiones instaladas a la vez, como los kernels en rpm.
+func (s SoftwareItem) versionKey() string {
+	return s.key() + "|" + s.Version
+}
+
+// multiVersionKeys devuelve las claves que aparecen más de una vez en alguno
+// de los snapshots.
+func multiVersionKeys(snapshots ...[]SoftwareItem) map[string]bool {
+	multi := make(map[string]bool)
+	for _, items := range snapshots {
+		count := make(map[string]int, len(items))
+		for _, item := range items {
+			count[item.key()]++
+			if count[item.key()] > 1 {
+				multi[item.key()] = true
+			}
+		}
+	}
+	return multi
+}
+
+// SoftwareInventoryReport lleva la lista completa en el primer envío (Full) y
+// después solo las diferencias respecto al último snapshot enviado.
+type SoftwareInventoryReport struct {
+	AgentID   string         `json:"agent_id"`
+	Hostname  string         `json:"hostname"`
+	Timestamp time.Time      `json:"timestamp"`
+	Full      bool           `json:"full"`
+	Items     []SoftwareItem `json:"items,omitempty"`
+	Added     []SoftwareItem `json:"added,omitempty"`
+	Removed   []SoftwareItem `json:"removed,omitempty"`
+	Updated   []SoftwareItem `json:"updated,omitempty"`
+}
+
+// diffSoftware compara dos snapshots. Un elemento con la misma clave y otra
+// versión o fecha de instalación cuenta como actualizado. Los paquetes con
+// varias versiones instaladas en alguno de los dos snapshots se comparan por
+// versión, así que para ellos una versión nueva es un alta y la anterior una
+// baja.
+func diffSoftware(prev, cur []SoftwareItem) (added, removed, updated []SoftwareItem) {
+	multi := multiVersionKeys(prev, cur)
+	keyOf := func(item SoftwareItem) string {
+		if multi[item.key()] {
+			return item.versionKey()
+		}
+		return item.key()
+	}
+
+	prevByKey := make(map[string]SoftwareItem, len(prev))
+	for _, item := range prev {
+		prevByKey[keyOf(item)] = item
+	}
+	seen := make(map[string]bool, len(cur))
+	for _, item := range cur {
+		seen[keyOf(item)] = true
+		old, ok := prevByKey[keyOf(item)]
+		switch {
+		case !ok:
+			added = append(added, item)
+		case old != item:
+			updated = append(updated, item)
+		}
+	}
+	for _, item := range prev {
+		if !seen[keyOf(item)] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed, updated
+}
+
+func loadSoftwareSnapshot(path string) ([]SoftwareItem, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var items []SoftwareItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		reportLog().Warn("Snapshot de software inválido, se enviará completo", "error", err)
+		return nil, false
+	}
+	return items, true
+}
+
+func saveSoftwareSnapshot(path string, items []SoftwareItem) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		reportLog().Error("Error al crear directorio de datos", "error", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		reportLog().Error("Error al guardar snapshot de software", "path", path, "error", err)
+	}
+}
+
+// runSoftwareInventoryReporter revisa el software instalado cada
+// inventory_interval. El snapshot solo se actualiza cuando el servidor
+// confirma el envío, así un cambio no se pierde si el servidor está caído.
+func runSoftwareInventoryReporter(config Config) {
+	interval := defaultInventoryInterval
+	if config.InventoryInterval > 0 {
+		interval = time.Duration(config.InventoryInterval) * time.Second
+	}
+	url := fmt.Sprintf("%s/api/%s/inventory/software", config.ServerURL, config.ServerVersion)
+	snapshotPath := config.dataPath("software-snapshot.json")
+
+	for {
+		items, err := listInstalledSoftware()
+		if err != nil {
+			reportLog().Error("Error al listar software instalado", "error", err)
+		} else {
+			sort.Slice(items, func(i, j int) bool { return items[i].versionKey() < items[j].versionKey() })
+			hostname, _ := os.Hostname()
+			report := SoftwareInventoryReport{AgentID: agentID, Hostname: hostname, Timestamp: time.Now()}
+
+			prev, ok := loadSoftwareSnapshot(snapshotPath)
+			if ok {
+				report.Added, report.Removed, report.Updated = diffSoftware(prev, items)
+			} else {
+				report.Full = true
+				report.Items = items
+			}
+
+			if report.Full || len(report.Added)+len(report.Removed)+len(report.Updated) > 0 {
+				payload, _ := marshalReport(report)
+				if err := postJSON(url, payload); err != nil {
+					logSendError(reportLog(), "software_inventory", err, payload)
+				} else {
+					saveSoftwareSnapshot(snapshotPath, items)
+				}
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listInstalledSoftware consulta la base de dpkg o, si no existe, la de rpm.
+func listInstalledSoftware() ([]SoftwareItem, error) {
+	if _, err := exec.LookPath("dpkg-query"); err == nil {
+		return listDpkgPackages()
+	}
+	return listRpmPackages()
+}
+
+func listDpkgPackages() ([]SoftwareItem, error) {
+	out, err := exec.Command("dpkg-query", "-W", "-f", "${db:Status-Abbrev}\t${Package}\t${Version}\t${Architecture}\t${Maintainer}\n").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []SoftwareItem
+	for _, line := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "ii") {
+			continue
+		}
+		items = append(items, SoftwareItem{
+			Kind:        "package",
+			Name:        fields[1],
+			Version:     fields[2],
+			Arch:        fields[3],
+			Publisher:   fields[4],
+			InstallDate: dpkgInstallDate(dpkgInfoDir, fields[1], fields[3]),
+		})
+	}
+	return items, nil
+}
+
+const dpkgInfoDir = "/var/lib/dpkg/info"
+
+// dpkgInstallDate toma la fecha de modificación de la lista de archivos del
+// paquete, que dpkg reescribe al instalarlo o actualizarlo. Los paquetes
+// multiarch usan <paquete>:<arquitectura>.list.
+func dpkgInstallDate(infoDir, name, arch string) string {
+	for _, file := range []string{name + ".list", name + ":" + arch + ".list"} {
+		if fi, err := os.Stat(filepath.Join(infoDir, file)); err == nil {
+			return fi.ModTime().Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+func listRpmPackages() ([]SoftwareItem, error) {
+	out, err := exec.Command("rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\t%{VENDOR}\t%{INSTALLTIME}\n").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []SoftwareItem
+	for _, line := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		item := SoftwareItem{
+			Kind:      "package",
+			Name:      fields[0],
+			Version:   fields[1],
+			Arch:      fields[2],
+			Publisher: fields[3],
+		}
+		if ts, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+			item.InstallDate = time.Unix(ts, 0).Format("2006-01-02")
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDpkgInstallDate(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(name string, when time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch("curl.list", time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	touch("libc6:amd64.list", time.Date(2024, 4, 2, 12, 0, 0, 0, time.Local))
+
+	tests := []struct{ name, arch, want string }{
+		{"curl", "amd64", "2024-03-01"},
+		{"libc6", "amd64", "2024-04-02"},
+		{"libc6", "i386", ""},
+		{"ausente", "all", ""},
+	}
+	for _, tt := range tests {
+		if got := dpkgInstallDate(dir, tt.name, tt.arch); got != tt.want {
+			t.Errorf("dpkgInstallDate(%s, %s) = %q, se esperaba %q", tt.name, tt.arch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+func TestDiffSoftware(t *testing.T) {
+	kernel := func(version string) SoftwareItem {
+		return SoftwareItem{Kind: "package", Name: "kernel", Version: version, Arch: "x86_64"}
+	}
+	curl := func(version string) SoftwareItem {
+		return SoftwareItem{Kind: "package", Name: "curl", Version: version, Arch: "amd64"}
+	}
+	office := SoftwareItem{Kind: "program", Name: "Office", Version: "16.0", Arch: "x64"}
+	officeNew := office
+	officeNew.Version = "16.1"
+
+	tests := []struct {
+		name                    string
+		prev, cur               []SoftwareItem
+		added, removed, updated []SoftwareItem
+	}{
+		{
+			name:    "actualización de paquete",
+			prev:    []SoftwareItem{curl("7.88.1-10")},
+			cur:     []SoftwareItem{curl("7.88.1-11")},
+			updated: []SoftwareItem{curl("7.88.1-11")},
+		},
+		{
+			name:    "actualización de programa",
+			prev:    []SoftwareItem{office},
+			cur:     []SoftwareItem{officeNew},
+			updated: []SoftwareItem{officeNew},
+		},
+		{
+			name:    "kernel nuevo y antiguo eliminado",
+			prev:    []SoftwareItem{kernel("5.14.0-1"), kernel("5.14.0-2")},
+			cur:     []SoftwareItem{kernel("5.14.0-2"), kernel("5.14.0-3")},
+			added:   []SoftwareItem{kernel("5.14.0-3")},
+			removed: []SoftwareItem{kernel("5.14.0-1")},
+		},
+		{
+			name:  "segundo kernel instalado",
+			prev:  []SoftwareItem{kernel("5.14.0-1")},
+			cur:   []SoftwareItem{kernel("5.14.0-1"), kernel("5.14.0-2")},
+			added: []SoftwareItem{kernel("5.14.0-2")},
+		},
+		{
+			name:    "queda un solo kernel",
+			prev:    []SoftwareItem{kernel("5.14.0-1"), kernel("5.14.0-2")},
+			cur:     []SoftwareItem{kernel("5.14.0-2")},
+			removed: []SoftwareItem{kernel("5.14.0-1")},
+		},
+		{
+			name:    "alta y baja",
+			prev:    []SoftwareItem{curl("7.88.1-10")},
+			cur:     []SoftwareItem{office},
+			added:   []SoftwareItem{office},
+			removed: []SoftwareItem{curl("7.88.1-10")},
+		},
+		{
+			name: "sin cambios",
+			prev: []SoftwareItem{curl("7.88.1-10"), kernel("5.14.0-1"), kernel("5.14.0-2")},
+			cur:  []SoftwareItem{curl("7.88.1-10"), kernel("5.14.0-1"), kernel("5.14.0-2")},
+		},
+	}
+
+	equal := func(a, b []SoftwareItem) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, updated := diffSoftware(tt.prev, tt.cur)
+			if !equal(added, tt.added) {
+				t.Errorf("added = %+v, se esperaba %+v", added, tt.added)
+			}
+			if !equal(removed, tt.removed) {
+				t.Errorf("removed = %+v, se esperaba %+v", removed, tt.removed)
+			}
+			if !equal(updated, tt.updated) {
+				t.Errorf("updated = %+v, se esperaba %+v", updated, tt.updated)
+			}
+		})
+	}
+}
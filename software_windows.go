@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const uninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+
+// listInstalledSoftware lee los programas de las claves Uninstall (vistas de
+// 64 y 32 bits) y los hotfixes con Get-HotFix.
+func listInstalledSoftware() ([]SoftwareItem, error) {
+	var items []SoftwareItem
+	for _, view := range []struct {
+		access uint32
+		arch   string
+	}{
+		{registry.WOW64_64KEY, "x64"},
+		{registry.WOW64_32KEY, "x86"},
+	} {
+		programs, err := readUninstallKey(view.access, view.arch)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, programs...)
+	}
+
+	hotfixes, err := listHotfixes()
+	if err != nil {
+		return nil, err
+	}
+	return append(items, hotfixes...), nil
+}
+
+func readUninstallKey(access uint32, arch string) ([]SoftwareItem, error) {
+	root, err := registry.OpenKey(registry.LOCAL_MACHINE, uninstallKey, registry.ENUMERATE_SUB_KEYS|access)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	names, err := root.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []SoftwareItem
+	for _, name := range names {
+		k, err := registry.OpenKey(root, name, registry.QUERY_VALUE|access)
+		if err != nil {
+			continue
+		}
+		displayName, _, _ := k.GetStringValue("DisplayName")
+		systemComponent, _, _ := k.GetIntegerValue("SystemComponent")
+		if displayName == "" || systemComponent == 1 {
+			k.Close()
+			continue
+		}
+		item := SoftwareItem{Kind: "program", Name: displayName, Arch: arch}
+		item.Version, _, _ = k.GetStringValue("DisplayVersion")
+		item.Publisher, _, _ = k.GetStringValue("Publisher")
+		installDate, _, _ := k.GetStringValue("InstallDate")
+		item.InstallDate = formatInstallDate(installDate)
+		k.Close()
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// formatInstallDate convierte el formato AAAAMMDD del registro a AAAA-MM-DD.
+func formatInstallDate(s string) string {
+	if len(s) != 8 {
+		return s
+	}
+	return s[0:4] + "-" + s[4:6] + "-" + s[6:8]
+}
+
+func listHotfixes() ([]SoftwareItem, error) {
+	psCommand := `Get-HotFix | Select-Object HotFixID,Description,@{n='InstalledOn';e={if ($_.InstalledOn) { $_.InstalledOn.ToString('yyyy-MM-dd') }}} | ConvertTo-Json -Compress`
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", psCommand)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	raw := bytes.TrimSpace(out.Bytes())
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	// ConvertTo-Json devuelve un objeto en lugar de una lista si hay uno solo.
+	if raw[0] != '[' {
+		raw = append(append([]byte{'['}, raw...), ']')
+	}
+
+	var fixes []struct {
+		HotFixID    string
+		Description string
+		InstalledOn string
+	}
+	if err := json.Unmarshal(raw, &fixes); err != nil {
+		return nil, err
+	}
+
+	items := make([]SoftwareItem, 0, len(fixes))
+	for _, f := range fixes {
+		items = append(items, SoftwareItem{
+			Kind:        "hotfix",
+			Name:        strings.TrimSpace(f.HotFixID),
+			Description: f.Description,
+			InstallDate: f.InstalledOn,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxSpoolEntries limita el tamaño de cada spool; al superarlo se descartan
+// las entradas más antiguas. Los spools sin límite (newUncappedSpool) no lo
+// aplican.
+const maxSpoolEntries = 10000
+
+// spool guarda en disco los payloads que no se pudieron enviar, uno por línea,
+// para reenviarlos cuando el servidor vuelva a estar disponible.
+type spool struct {
+	path       string
+	maxEntries int // 0 = sin límite
+	mu         sync.Mutex
+}
+
+func newSpool(config Config, name string) *spool {
+	return &spool{path: config.dataPath(filepath.Join("spool", name+".jsonl")), maxEntries: maxSpoolEntries}
+}
+
+// newUncappedSpool crea un spool que nunca descarta entradas, para datos que
+// no se pueden perder como los registros de contabilidad.
+func newUncappedSpool(config Config, name string) *spool {
+	s := newSpool(config, name)
+	s.maxEntries = 0
+	return s
+}
+
+// Append agrega un payload al final del spool.
+func (s *spool) Append(payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.read()
+	entries = append(entries, bytes.TrimSpace(payload))
+	if s.maxEntries > 0 && len(entries) > s.maxEntries {
+		slog.Warn("Spool lleno, se descartan entradas antiguas", "spool", s.path, "dropped", len(entries)-s.maxEntries)
+		entries = entries[len(entries)-s.maxEntries:]
+	}
+	s.write(entries)
+}
+
+// Flush reenvía los payloads en orden y se detiene en el primer error,
+// conservando los que no se pudieron enviar. El envío se hace sin el lock,
+// para que Append no espere a un servidor lento.
+func (s *spool) Flush(send func(payload []byte) error) {
+	entries := s.Peek()
+	if len(entries) == 0 {
+		return
+	}
+
+	sent := 0
+	for _, payload := range entries {
+		if err := send(payload); err != nil {
+			break
+		}
+		sent++
+	}
+	if sent > 0 {
+		slog.Info("Entradas de spool reenviadas", "spool", s.path, "sent", sent, "total", len(entries))
+		s.Discard(entries[:sent])
+	}
+}
+
+// Drain entrega todas las entradas juntas y las borra solo si send no
+// devuelve error. Como Flush, envía sin el lock.
+func (s *spool) Drain(send func(entries [][]byte) error) {
+	entries := s.Peek()
+	if len(entries) == 0 {
+		return
+	}
+	if err := send(entries); err == nil {
+		s.Discard(entries)
+	}
+}
+
+// Peek devuelve las entradas pendientes sin borrarlas.
+func (s *spool) Peek() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Discard borra las entradas enviadas, que son las que devolvió Peek. Se
+// buscan por contenido y no por posición: si Append descartó entradas
+// antiguas entre Peek y Discard, borrar las primeras n perdería entradas que
+// todavía no se enviaron. Las que se agregaron después de Peek se conservan.
+func (s *spool) Discard(sent [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make(map[string]int, len(sent))
+	for _, payload := range sent {
+		pending[string(payload)]++
+	}
+	entries := s.read()
+	kept := entries[:0]
+	for _, payload := range entries {
+		if pending[string(payload)] > 0 {
+			pending[string(payload)]--
+			continue
+		}
+		kept = append(kept, payload)
+	}
+	s.write(kept)
+}
+
+func (s *spool) read() [][]byte {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("Error al leer spool", "spool", s.path, "error", err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var entries [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		entries = append(entries, append([]byte(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("Error al leer spool", "spool", s.path, "error", err)
+	}
+	return entries
+}
+
+func (s *spool) write(entries [][]byte) {
+	if len(entries) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			slog.Error("Error al limpiar spool", "spool", s.path, "error", err)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		slog.Error("Error al crear directorio de spool", "error", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	data := append(bytes.Join(entries, []byte("\n")), '\n')
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("Error al escribir spool", "spool", s.path, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		slog.Error("Error al escribir spool", "spool", s.path, "error", err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SyslogConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Network  string `yaml:"network"`  // local | udp | tcp | tls
+	Address  string `yaml:"address"`  // host:puerto, no se usa con local
+	Facility string `yaml:"facility"` // Por defecto daemon
+	Level    string `yaml:"level"`    // Por defecto info
+	CAFile   string `yaml:"ca_file"`  // CA del servidor para tls; vacío usa las del sistema
+}
+
+const (
+	syslogAppName = "pirmon-client"
+	// syslogSDID identifica los atributos en STRUCTURED-DATA. 32473 es el
+	// número de empresa reservado para documentación (RFC 5612).
+	syslogSDID       = "pirmon@32473"
+	syslogQueueSize  = 1000
+	syslogTimeout    = 5 * time.Second
+	syslogRetryDelay = 10 * time.Second
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSink envía los registros en formato RFC 5424. Escribe desde su
+// propia goroutine para que un servidor lento no frene al agente; si la
+// cola se llena los registros se descartan.
+type syslogSink struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	facility int
+	hostname string
+	pid      int
+
+	queue chan logRecord
+	done  chan struct{}
+}
+
+func newSyslogSink(cfg SyslogConfig) (*syslogSink, error) {
+	s := &syslogSink{
+		network:  strings.ToLower(cfg.Network),
+		address:  cfg.Address,
+		pid:      os.Getpid(),
+		queue:    make(chan logRecord, syslogQueueSize),
+		done:     make(chan struct{}),
+		hostname: "-",
+	}
+	if s.network == "" {
+		s.network = "local"
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		s.hostname = syslogToken(h, 255)
+	}
+
+	facility := strings.ToLower(cfg.Facility)
+	if facility == "" {
+		facility = "daemon"
+	}
+	var ok bool
+	if s.facility, ok = syslogFacilities[facility]; !ok {
+		return nil, fmt.Errorf("facility desconocida '%s'", cfg.Facility)
+	}
+
+	switch s.network {
+	case "local":
+	case "udp", "tcp":
+		if s.address == "" {
+			return nil, fmt.Errorf("falta address para %s", s.network)
+		}
+	case "tls":
+		if s.address == "" {
+			return nil, fmt.Errorf("falta address para tls")
+		}
+		host, _, err := net.SplitHostPort(s.address)
+		if err != nil {
+			return nil, fmt.Errorf("address '%s': %v", s.address, err)
+		}
+		s.tls = &tls.Config{ServerName: host}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("ca_file: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_file '%s' no tiene certificados", cfg.CAFile)
+			}
+			s.tls.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("network desconocido '%s'", cfg.Network)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *syslogSink) Write(rec logRecord) {
+	select {
+	case s.queue <- rec:
+	default:
+	}
+}
+
+func (s *syslogSink) Close() {
+	close(s.done)
+}
+
+func (s *syslogSink) run() {
+	var conn net.Conn
+	var retryAt time.Time
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var rec logRecord
+		select {
+		case rec = <-s.queue:
+		case <-s.done:
+			return
+		}
+
+		if conn == nil {
+			// Sin conexión se descarta hasta el próximo intento, para no
+			// marcar en cada registro.
+			if time.Now().Before(retryAt) {
+				continue
+			}
+			var err error
+			if conn, err = s.dial(); err != nil {
+				fmt.Fprintf(os.Stderr, "syslog %s %s: %v\n", s.network, s.address, err)
+				retryAt = time.Now().Add(syslogRetryDelay)
+				continue
+			}
+		}
+
+		msg := formatSyslog(rec, s.facility, s.hostname, s.pid)
+		if s.stream() {
+			// Octet counting (RFC 6587 y RFC 5425): el largo delante de cada
+			// mensaje.
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err := conn.Write(msg); err != nil {
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+func (s *syslogSink) stream() bool {
+	return s.network == "tcp" || s.network == "tls"
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+	switch s.network {
+	case "local":
+		return dialLocalSyslog()
+	case "tls":
+		dialer := &net.Dialer{Timeout: syslogTimeout}
+		return tls.DialWithDialer(dialer, "tcp", s.address, s.tls)
+	default:
+		return net.DialTimeout(s.network, s.address, syslogTimeout)
+	}
+}
+
+// syslogSeverity traduce el nivel de slog a la severidad de syslog.
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// formatSyslog arma el mensaje RFC 5424. El componente va en MSGID y el
+// resto de los atributos en STRUCTURED-DATA.
+func formatSyslog(rec logRecord, facility int, hostname string, pid int) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d ",
+		facility*8+syslogSeverity(rec.Level),
+		rec.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname, syslogAppName, pid)
+
+	msgID := "-"
+	if rec.Component != "" {
+		msgID = syslogToken(rec.Component, 32)
+	}
+	b.WriteString(msgID + " ")
+
+	if len(rec.Attrs) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + syslogSDID)
+		for _, a := range rec.Attrs {
+			b.WriteString(" " + syslogParamName(a.Key) + `="` + syslogEscape(a.Value.String()) + `"`)
+		}
+		b.WriteString("]")
+	}
+
+	// El BOM indica que MSG está en UTF-8.
+	b.WriteString(" \xEF\xBB\xBF" + rec.Message)
+	return b.Bytes()
+}
+
+// syslogToken deja solo ASCII imprimible sin espacios, como piden HOSTNAME y
+// MSGID.
+func syslogToken(s string, max int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	if len(b) > max {
+		b = b[:max]
+	}
+	return string(b)
+}
+
+// syslogParamName además excluye '=', ']' y '"', que no pueden ir en
+// PARAM-NAME.
+func syslogParamName(s string) string {
+	s = syslogToken(s, 32)
+	return strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(s)
+}
+
+func syslogEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(s)
+}
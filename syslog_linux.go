@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// localSyslogPaths son los sockets habituales del demonio local.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// dialLocalSyslog usa el primero de localSyslogPaths que acepte la conexión.
+func dialLocalSyslog() (net.Conn, error) {
+	for _, path := range localSyslogPaths {
+		if conn, err := net.Dial("unixgram", path); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("no se encontró el socket de syslog local")
+}
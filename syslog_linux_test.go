@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyslogSinkLocal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	saved := localSyslogPaths
+	localSyslogPaths = []string{filepath.Join(t.TempDir(), "no-existe"), path}
+	defer func() { localSyslogPaths = saved }()
+
+	sink, err := newSyslogSink(SyslogConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if sink.network != "local" || sink.stream() {
+		t.Fatalf("network = %q, se esperaba local sin octet counting", sink.network)
+	}
+	sink.Write(testSyslogRecord())
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSyslogMessage(t, sink, string(buf[:n]))
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSyslogRecord tiene un atributo con los tres caracteres que hay que
+// escapar en PARAM-VALUE.
+func testSyslogRecord() logRecord {
+	return logRecord{
+		Time:      time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC),
+		Level:     slog.LevelError,
+		Message:   "falló",
+		Component: "printer",
+		Attrs: []slog.Attr{
+			slog.String("printer", `HP "1" ]\`),
+			slog.Int("job id", 7),
+		},
+	}
+}
+
+func TestFormatSyslog(t *testing.T) {
+	got := string(formatSyslog(testSyslogRecord(), 3, "host1", 42))
+	want := `<27>1 2024-03-01T12:30:45.123456Z host1 pirmon-client 42 printer ` +
+		`[pirmon@32473 printer="HP \"1\" \]\\" job_id="7"] ` + "\xEF\xBB\xBF" + "falló"
+	if got != want {
+		t.Fatalf("formatSyslog:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatSyslogWithoutAttrs(t *testing.T) {
+	rec := logRecord{
+		Time:    time.Date(2024, 3, 1, 12, 30, 45, 0, time.FixedZone("", -3*3600)),
+		Level:   slog.LevelDebug,
+		Message: "inicio",
+	}
+	got := string(formatSyslog(rec, syslogFacilities["local0"], "-", 1))
+	want := "<135>1 2024-03-01T12:30:45.000000-03:00 - pirmon-client 1 - - \xEF\xBB\xBFinicio"
+	if got != want {
+		t.Fatalf("formatSyslog:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  int
+	}{
+		{slog.LevelDebug, 7},
+		{slog.LevelInfo, 6},
+		{slog.LevelWarn, 4},
+		{slog.LevelError, 3},
+		{slog.LevelError + 4, 3},
+	}
+	for _, tt := range tests {
+		if got := syslogSeverity(tt.level); got != tt.want {
+			t.Errorf("syslogSeverity(%v) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestNewSyslogSinkErrors(t *testing.T) {
+	tests := []SyslogConfig{
+		{Network: "udp"},
+		{Network: "tls"},
+		{Network: "tls", Address: "sin-puerto"},
+		{Network: "tls", Address: "localhost:6514", CAFile: filepath.Join(t.TempDir(), "no-existe.pem")},
+		{Network: "udp", Address: "127.0.0.1:514", Facility: "nope"},
+		{Network: "sctp", Address: "127.0.0.1:514"},
+	}
+	for _, cfg := range tests {
+		if sink, err := newSyslogSink(cfg); err == nil {
+			sink.Close()
+			t.Errorf("newSyslogSink(%+v) no devolvió error", cfg)
+		}
+	}
+}
+
+// wantSyslogMessage compara lo recibido con formatSyslog usando el host y
+// el PID del sink. En los transportes de flujo got es lo que delimitó el
+// prefijo de largo, así que un largo mal calculado también falla acá.
+func wantSyslogMessage(t *testing.T, sink *syslogSink, got string) {
+	t.Helper()
+	want := string(formatSyslog(testSyslogRecord(), sink.facility, sink.hostname, sink.pid))
+	if got != want {
+		t.Fatalf("mensaje:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink, err := newSyslogSink(SyslogConfig{Network: "udp", Address: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.Write(testSyslogRecord())
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// UDP lleva un mensaje por datagrama, sin prefijo de largo.
+	wantSyslogMessage(t, sink, string(buf[:n]))
+}
+
+// readOctetCounted lee un mensaje con el prefijo "LARGO " de RFC 6587.
+func readOctetCounted(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	prefix, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+	if err != nil || n <= 0 {
+		t.Fatalf("prefijo de largo inválido %q", prefix)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	return string(msg)
+}
+
+// acceptAndRead acepta una conexión y lee dos mensajes seguidos, para ver
+// que el prefijo delimita cada uno.
+func acceptAndRead(t *testing.T, ln net.Listener) []string {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	return []string{readOctetCounted(t, r), readOctetCounted(t, r)}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	sink, err := newSyslogSink(SyslogConfig{Network: "tcp", Address: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.Write(testSyslogRecord())
+	sink.Write(testSyslogRecord())
+
+	for _, msg := range acceptAndRead(t, ln) {
+		wantSyslogMessage(t, sink, msg)
+	}
+}
+
+func TestSyslogSinkTLS(t *testing.T) {
+	cert, caFile := testCertificate(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	sink, err := newSyslogSink(SyslogConfig{Network: "tls", Address: "localhost:" + port, CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.Write(testSyslogRecord())
+	sink.Write(testSyslogRecord())
+
+	for _, msg := range acceptAndRead(t, ln) {
+		wantSyslogMessage(t, sink, msg)
+	}
+}
+
+// testCertificate genera un certificado autofirmado para localhost y lo
+// guarda como CA en un archivo temporal.
+func testCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
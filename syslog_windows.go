@@ -0,0 +1,11 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// En Windows no hay un syslog local; hay que usar udp, tcp o tls.
+func dialLocalSyslog() (net.Conn, error) {
+	return nil, fmt.Errorf("no hay syslog local en Windows, configure network y address")
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsProtocolVersion = 1
+
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingPeriod   = wsPongWait * 9 / 10
+	wsMaxMessage   = 1 << 20
+	wsBackoffBase  = time.Second
+	wsBackoffMax   = 2 * time.Minute
+	wsStableAfter  = time.Minute
+	wsOutboxLength = 32
+)
+
+// WSMessage es el sobre de todos los mensajes del WebSocket, en ambos
+// sentidos. ID permite relacionar una respuesta con su solicitud.
+type WSMessage struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsHandler procesa un mensaje recibido del servidor. Se ejecuta en la
+// goroutine de lectura, por lo que un trabajo largo debe lanzarse aparte.
+type wsHandler func(s *wsSession, msg WSMessage)
+
+var wsHandlers = map[string]wsHandler{}
+
+func registerWSHandler(msgType string, handler wsHandler) {
+	wsHandlers[msgType] = handler
+}
+
+func init() {
+	registerWSHandler("ping", func(s *wsSession, msg WSMessage) {
+		s.Reply(msg, "pong", nil)
+	})
+}
+
+// wsSession es una conexión activa. Todas las escrituras pasan por outbox
+// porque gorilla/websocket no admite escritores concurrentes.
+type wsSession struct {
+	config Config
+	conn   *websocket.Conn
+	outbox chan WSMessage
+	done   chan struct{}
+}
+
+// Send encola un mensaje para el servidor. Devuelve error si la sesión ya se
+// cerró.
+func (s *wsSession) Send(msgType, id string, payload interface{}) error {
+	msg := WSMessage{Version: wsProtocolVersion, Type: msgType, ID: id}
+	if payload != nil {
+		data, err := marshalReport(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = data
+	}
+	select {
+	case s.outbox <- msg:
+		return nil
+	case <-s.done:
+		return fmt.Errorf("sesión WebSocket cerrada")
+	}
+}
+
+// Reply responde a un mensaje del servidor usando su mismo ID.
+func (s *wsSession) Reply(req WSMessage, msgType string, payload interface{}) {
+	if err := s.Send(msgType, req.ID, payload); err != nil {
+		statsLog().Warn("No se pudo responder", "type", req.Type, "id", req.ID, "error", err)
+	}
+}
+
+// startSystemStatsWebSocket envía por el WebSocket las muestras de
+// statsSamples, que produce runStatsSampler.
+func startSystemStatsWebSocket(config Config) {
+	samples := statsSamples
+	attempt := 0
+	for {
+		url := systemStatsWebSocketURL(config)
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			delay := backoffDelay(attempt)
+			attempt++
+			statsLog().Warn("Error al conectar WebSocket", "error", err, "retry_in", delay.Round(time.Second))
+			time.Sleep(delay)
+			continue
+		}
+
+		statsLog().Info("WebSocket de stats del sistema conectado.")
+		started := time.Now()
+		err = runWSSession(config, conn, samples)
+		statsLog().Warn("WebSocket cerrado", "error", err)
+
+		// Una sesión que duró lo suficiente reinicia el backoff; si se cae
+		// apenas conecta seguimos aumentando la espera.
+		if time.Since(started) >= wsStableAfter {
+			attempt = 0
+		}
+		delay := backoffDelay(attempt)
+		attempt++
+		time.Sleep(delay)
+	}
+}
+
+func systemStatsWebSocketURL(config Config) string {
+	scheme := "ws"
+	if strings.HasPrefix(config.ServerURL, "https://") {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s/api/%s/ws/system-stats", scheme, config.ServerURLNoProtocol(), config.ServerVersion)
+}
+
+// backoffDelay calcula una espera exponencial con jitter: entre la mitad y el
+// total de base*2^attempt, acotada a wsBackoffMax.
+func backoffDelay(attempt int) time.Duration {
+	d := wsBackoffMax
+	if attempt < 16 {
+		d = wsBackoffBase << uint(attempt)
+		if d > wsBackoffMax {
+			d = wsBackoffMax
+		}
+	}
+	half := int64(d / 2)
+	return time.Duration(half + mrand.Int63n(half+1))
+}
+
+// runWSSession atiende una conexión hasta que falla la lectura o la
+// escritura. Cierra la conexión antes de volver.
+func runWSSession(config Config, conn *websocket.Conn, samples <-chan SystemStats) error {
+	s := &wsSession{
+		config: config,
+		conn:   conn,
+		outbox: make(chan WSMessage, wsOutboxLength),
+		done:   make(chan struct{}),
+	}
+	defer conn.Close()
+	defer close(s.done)
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- s.readLoop()
+	}()
+
+	hostname, _ := os.Hostname()
+	s.Send("hello", newMessageID(), map[string]interface{}{
+		"agent_id":         agentID,
+		"hostname":         hostname,
+		"protocol_version": wsProtocolVersion,
+	})
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case stats := <-samples:
+			err = s.write(WSMessage{Version: wsProtocolVersion, Type: "stats", ID: newMessageID(), Payload: marshalPayload(stats)})
+		case msg := <-s.outbox:
+			err = s.write(msg)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		case err = <-readErr:
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *wsSession) write(msg WSMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteJSON(msg)
+}
+
+// readLoop recibe mensajes del servidor y los despacha a su handler. La
+// deadline de lectura se extiende con cada pong, así una conexión medio
+// abierta se detecta en wsPongWait.
+func (s *wsSession) readLoop() error {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			statsLog().Warn("Mensaje WebSocket inválido", "error", err)
+			continue
+		}
+		if msg.Version != wsProtocolVersion {
+			s.Reply(msg, "error", map[string]string{"error": fmt.Sprintf("versión de protocolo no soportada: %d", msg.Version)})
+			continue
+		}
+
+		handler, ok := wsHandlers[msg.Type]
+		if !ok {
+			s.Reply(msg, "error", map[string]string{"error": fmt.Sprintf("tipo de mensaje desconocido: %s", msg.Type)})
+			continue
+		}
+		handler(s, msg)
+	}
+}
+
+func newMessageID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func marshalPayload(v interface{}) json.RawMessage {
+	data, err := marshalReport(v)
+	if err != nil {
+		statsLog().Error("Error al serializar mensaje", "error", err)
+		return nil
+	}
+	return data
+}